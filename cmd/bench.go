@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"edgerag/internal/vectorstore"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark brute-force vector search on this machine",
+	Long: `Benchmark exact (brute-force) vector search on synthetic embeddings.
+
+Three implementations are timed on the same random data and queries:
+- legacy:     the original map-of-pointers scan that recomputes both norms
+              per comparison and sorts every result
+- flat:       the contiguous matrix layout with cached norms and a heap-based
+              top-k, scanned on a single goroutine
+- flat+par:   the same layout scanned in parallel across all CPUs
+
+Use it to size a store for a given device before indexing real data.
+
+Examples:
+  edgerag bench
+  edgerag bench --vectors 200000 --dim 768 --queries 20`,
+	Args: cobra.NoArgs,
+	RunE: runBench,
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	benchCmd.Flags().Int("vectors", 50000, "Number of synthetic vectors to store")
+	benchCmd.Flags().Int("dim", 384, "Embedding dimension")
+	benchCmd.Flags().Int("queries", 50, "Number of queries to time")
+	benchCmd.Flags().IntP("top-k", "k", 5, "Number of results per query")
+	benchCmd.Flags().Int64("seed", 1, "Random seed for the synthetic data")
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	count, _ := cmd.Flags().GetInt("vectors")
+	dim, _ := cmd.Flags().GetInt("dim")
+	numQueries, _ := cmd.Flags().GetInt("queries")
+	topK, _ := cmd.Flags().GetInt("top-k")
+	seed, _ := cmd.Flags().GetInt64("seed")
+
+	if count <= 0 || dim <= 0 || numQueries <= 0 {
+		return fmt.Errorf("vectors, dim and queries must be positive")
+	}
+
+	fmt.Printf("⏳ Generating %d vectors of dimension %d...\n", count, dim)
+	rng := rand.New(rand.NewSource(seed))
+	randomVector := func() []float32 {
+		v := make([]float32, dim)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64())
+		}
+		return v
+	}
+
+	legacy := make(map[string]*vectorstore.Vector, count)
+	sequential := vectorstore.NewMemoryStoreWithOptions(vectorstore.Options{SearchWorkers: 1})
+	parallel := vectorstore.NewMemoryStoreWithOptions(vectorstore.Options{})
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("vec_%d", i)
+		embedding := randomVector()
+		legacy[id] = &vectorstore.Vector{ID: id, Embedding: embedding}
+		if err := sequential.Add(id, embedding, "", nil); err != nil {
+			return err
+		}
+		if err := parallel.Add(id, embedding, "", nil); err != nil {
+			return err
+		}
+	}
+
+	queries := make([][]float32, numQueries)
+	for i := range queries {
+		queries[i] = randomVector()
+	}
+
+	fmt.Printf("🔍 Running %d queries (top-k %d, %d CPUs)\n\n", numQueries, topK, runtime.NumCPU())
+
+	legacyTime, legacyTop := timeSearches(queries, func(q []float32) string {
+		return legacyMapSearch(legacy, q, topK)
+	})
+	flatTime, flatTop := timeSearches(queries, func(q []float32) string {
+		return firstResultID(sequential.Search(q, topK, -1))
+	})
+	parallelTime, parallelTop := timeSearches(queries, func(q []float32) string {
+		return firstResultID(parallel.Search(q, topK, -1))
+	})
+
+	fmt.Printf("%-10s %14s %10s\n", "layout", "per query", "speedup")
+	fmt.Printf("%-10s %14s %10s\n", "legacy", legacyTime, "1.00x")
+	fmt.Printf("%-10s %14s %9.2fx\n", "flat", flatTime, float64(legacyTime)/float64(flatTime))
+	fmt.Printf("%-10s %14s %9.2fx\n", "flat+par", parallelTime, float64(legacyTime)/float64(parallelTime))
+
+	if !equalStrings(legacyTop, flatTop) || !equalStrings(legacyTop, parallelTop) {
+		fmt.Printf("\n⚠️  Top results differ between implementations\n")
+	} else {
+		fmt.Printf("\n✅ All implementations returned the same top results\n")
+	}
+
+	return nil
+}
+
+// timeSearches runs search for every query and returns the mean latency and
+// the top result ID of each query
+func timeSearches(queries [][]float32, search func([]float32) string) (time.Duration, []string) {
+	tops := make([]string, len(queries))
+	start := time.Now()
+	for i, q := range queries {
+		tops[i] = search(q)
+	}
+	return time.Since(start) / time.Duration(len(queries)), tops
+}
+
+// legacyMapSearch reproduces the original MemoryStore search: walk a map of
+// pointers, compute cosine similarity from scratch and sort every result
+func legacyMapSearch(vectors map[string]*vectorstore.Vector, query []float32, topK int) string {
+	type hit struct {
+		id    string
+		score float32
+	}
+	hits := make([]hit, 0)
+	for id, vector := range vectors {
+		var dot, normA, normB float32
+		for i := range query {
+			dot += query[i] * vector.Embedding[i]
+			normA += query[i] * query[i]
+			normB += vector.Embedding[i] * vector.Embedding[i]
+		}
+		score := dot / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
+		hits = append(hits, hit{id: id, score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].score > hits[j].score
+	})
+	if len(hits) > topK {
+		hits = hits[:topK]
+	}
+	if len(hits) == 0 {
+		return ""
+	}
+	return hits[0].id
+}
+
+func firstResultID(results []*vectorstore.SearchResult, err error) string {
+	if err != nil || len(results) == 0 {
+		return ""
+	}
+	return results[0].ID
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,626 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"edgerag/internal/embedding"
+	"edgerag/internal/eval"
+	"edgerag/internal/llm"
+	"edgerag/internal/rag"
+	"edgerag/internal/vectorstore"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval [golden-set.jsonl]",
+	Short: "Measure retrieval quality against a golden set of questions",
+	Long: `Evaluate retrieval against a golden set: a JSONL file with one question per
+line and the source files or chunk IDs that should be retrieved for it:
+
+  {"id": "deploy-1", "question": "How do I roll back?", "expected_files": ["docs/deploy.md"]}
+  {"id": "api-3", "question": "Which port does the API use?", "expected_chunks": ["3f2a..._semantic_4"]}
+
+Each question is run through retrieval (with the same query options as
+'edgerag query', but without generating an answer) and scored at k = --top-k:
+- recall@k:    fraction of the expected files and chunks found
+- precision@k: fraction of the retrieved chunks that are expected
+- MRR:         reciprocal rank of the first expected chunk
+- nDCG@k:      ranking quality, rewarding expected chunks near the top
+
+--compare runs a second configuration, the same as the first except for the
+given settings, and shows both side by side. Settings are named like the
+flags, and data-dir points at another index, e.g. one built with a different
+chunk size:
+
+  edgerag eval golden.jsonl --compare hyde=true
+  edgerag eval golden.jsonl --compare data-dir=/tmp/vectors-800 --compare name=chunks-800
+
+--answers also generates an answer to every question, including those without
+expected sources, and judges it:
+- faithfulness: fraction of the answer's sentences supported by the
+                retrieved chunks
+- relevance:    how well the answer addresses the question
+The LLM is the judge by default (--judge-model picks another Ollama model);
+--judge embedding compares embeddings instead, which is faster but cruder.
+Claims the LLM judge leaves unscored fall back to embeddings.
+
+--json prints the full report, with per-question results, as JSON for
+tracking over time; progress then goes to stderr.
+
+Examples:
+  edgerag eval golden.jsonl
+  edgerag eval golden.jsonl --top-k 10 --hierarchical
+  edgerag eval golden.jsonl --compare multi-query=3 --json > eval.json
+  edgerag eval golden.jsonl --store-backend sqlite --compare hybrid=true
+  edgerag eval golden.jsonl --answers --judge-model llama3.1:8b
+  edgerag eval golden.jsonl --answers --compare prompt-name=cite-sources`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEval,
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+
+	evalCmd.Flags().String("name", "baseline", "Name of the configuration in the report")
+	evalCmd.Flags().String("data-dir", "", "Vector store directory to evaluate (default: the one 'edgerag index' writes)")
+	evalCmd.Flags().IntP("top-k", "k", 5, "Number of chunks to retrieve, and the cutoff k of the metrics")
+	evalCmd.Flags().Float32P("threshold", "t", 0.3, "Minimum similarity score for retrieval (scale depends on the store metric)")
+	evalCmd.Flags().Int("nprobe", 8, "Number of IVF-PQ lists to scan when an index has been trained")
+	evalCmd.Flags().Bool("exact", false, "Ignore any trained IVF-PQ index and search all vectors exactly")
+	evalCmd.Flags().StringArray("filter", nil, "Only retrieve chunks whose metadata matches key=value (repeatable)")
+	evalCmd.Flags().Bool("hierarchical", false, "Match fine chunks and retrieve their coarse chunks (index built with --hierarchical)")
+	evalCmd.Flags().Bool("hybrid", false, "Also search the full-text index for each question's keywords and fuse the rankings (sqlite backend)")
+	evalCmd.Flags().Bool("rewrite", false, "Have the LLM rewrite each question as a search query before retrieval")
+	evalCmd.Flags().Bool("expand-keywords", false, "Have the LLM add related keywords and synonyms to each query")
+	evalCmd.Flags().Bool("hyde", false, "Search with a hypothetical answer drafted by the LLM as well as the query (HyDE)")
+	evalCmd.Flags().Int("multi-query", 0, "Also search for this many LLM paraphrases of each question and fuse the rankings")
+	evalCmd.Flags().StringArray("compare", nil, "Evaluate a second configuration differing by setting=value, e.g. top-k=10 or data-dir=DIR (repeatable)")
+	evalCmd.Flags().String("prompt-name", "", "Answer with a named prompt template from the prompt library")
+	evalCmd.Flags().String("prompt-template-file", "", "Answer with the prompt template in this file")
+	evalCmd.Flags().Bool("answers", false, "Also generate answers and score their faithfulness and relevance")
+	evalCmd.Flags().String("judge", "llm", "How answers are judged: llm or embedding")
+	evalCmd.Flags().String("judge-model", "", "Ollama model that judges answers (default: the answering model)")
+	evalCmd.Flags().Float64("support-threshold", rag.DefaultSupportThreshold, "Similarity to a chunk above which the embedding judge counts a sentence as supported")
+	evalCmd.Flags().Bool("json", false, "Print the report as JSON")
+}
+
+// evalSettings is one retrieval configuration under evaluation. Fields are
+// named after the eval flags, which --compare overrides use too.
+type evalSettings struct {
+	Name         string   `json:"name"`
+	DataDir      string   `json:"data_dir"`
+	Model        string   `json:"model"`
+	TopK         int      `json:"top_k"`
+	Threshold    float32  `json:"threshold"`
+	NProbe       int      `json:"nprobe"`
+	Exact        bool     `json:"exact"`
+	Filter       []string `json:"filter,omitempty"`
+	Hierarchical bool     `json:"hierarchical"`
+	Hybrid       bool     `json:"hybrid"`
+	Rewrite      bool     `json:"rewrite"`
+	Keywords     bool     `json:"expand_keywords"`
+	HyDE         bool     `json:"hyde"`
+	MultiQuery   int      `json:"multi_query"`
+	PromptName   string   `json:"prompt_name,omitempty"`
+	PromptFile   string   `json:"prompt_template_file,omitempty"`
+}
+
+// set applies a --compare override
+func (s *evalSettings) set(key, value string) error {
+	var err error
+	switch key {
+	case "name":
+		s.Name = value
+	case "data-dir":
+		s.DataDir = value
+	case "model":
+		s.Model = value
+	case "top-k":
+		s.TopK, err = strconv.Atoi(value)
+	case "threshold":
+		var threshold float64
+		threshold, err = strconv.ParseFloat(value, 32)
+		s.Threshold = float32(threshold)
+	case "nprobe":
+		s.NProbe, err = strconv.Atoi(value)
+	case "exact":
+		s.Exact, err = strconv.ParseBool(value)
+	case "filter":
+		s.Filter = append(s.Filter, value)
+	case "hierarchical":
+		s.Hierarchical, err = strconv.ParseBool(value)
+	case "hybrid":
+		s.Hybrid, err = strconv.ParseBool(value)
+	case "rewrite":
+		s.Rewrite, err = strconv.ParseBool(value)
+	case "expand-keywords":
+		s.Keywords, err = strconv.ParseBool(value)
+	case "hyde":
+		s.HyDE, err = strconv.ParseBool(value)
+	case "multi-query":
+		s.MultiQuery, err = strconv.Atoi(value)
+	case "prompt-name":
+		s.PromptName, s.PromptFile = value, ""
+	case "prompt-template-file":
+		s.PromptFile, s.PromptName = value, ""
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", value, key, err)
+	}
+	return nil
+}
+
+// usesLLM reports whether retrieval needs the LLM for query transformations
+func (s *evalSettings) usesLLM() bool {
+	return s.Rewrite || s.Keywords || s.HyDE || s.MultiQuery > 0
+}
+
+// answerEval configures answer-quality scoring, which is off when nil
+type answerEval struct {
+	judge            rag.JudgeMode
+	judgeModel       string
+	supportThreshold float64
+}
+
+func runEval(cmd *cobra.Command, args []string) error {
+	goldenPath := args[0]
+	compare, _ := cmd.Flags().GetStringArray("compare")
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	scoreAnswers, _ := cmd.Flags().GetBool("answers")
+
+	baseline := evalSettings{Model: viper.GetString("model")}
+	baseline.Name, _ = cmd.Flags().GetString("name")
+	baseline.DataDir, _ = cmd.Flags().GetString("data-dir")
+	baseline.TopK, _ = cmd.Flags().GetInt("top-k")
+	baseline.Threshold, _ = cmd.Flags().GetFloat32("threshold")
+	baseline.NProbe, _ = cmd.Flags().GetInt("nprobe")
+	baseline.Exact, _ = cmd.Flags().GetBool("exact")
+	baseline.Filter, _ = cmd.Flags().GetStringArray("filter")
+	baseline.Hierarchical, _ = cmd.Flags().GetBool("hierarchical")
+	baseline.Hybrid, _ = cmd.Flags().GetBool("hybrid")
+	baseline.Rewrite, _ = cmd.Flags().GetBool("rewrite")
+	baseline.Keywords, _ = cmd.Flags().GetBool("expand-keywords")
+	baseline.HyDE, _ = cmd.Flags().GetBool("hyde")
+	baseline.MultiQuery, _ = cmd.Flags().GetInt("multi-query")
+	baseline.PromptName, _ = cmd.Flags().GetString("prompt-name")
+	baseline.PromptFile, _ = cmd.Flags().GetString("prompt-template-file")
+	if baseline.DataDir == "" {
+		baseline.DataDir = vectorDataDir()
+	}
+
+	configurations := []evalSettings{baseline}
+	if len(compare) > 0 {
+		candidate := baseline
+		candidate.Name = "candidate"
+		candidate.Filter = append([]string(nil), baseline.Filter...)
+		for _, override := range compare {
+			key, value, ok := strings.Cut(override, "=")
+			if !ok {
+				return fmt.Errorf("invalid --compare %q, expected setting=value", override)
+			}
+			if err := candidate.set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+				return fmt.Errorf("invalid --compare %q: %w", override, err)
+			}
+		}
+		configurations = append(configurations, candidate)
+	}
+	for _, settings := range configurations {
+		if settings.TopK <= 0 {
+			return fmt.Errorf("top-k must be positive")
+		}
+	}
+
+	var answers *answerEval
+	if scoreAnswers {
+		judgeName, _ := cmd.Flags().GetString("judge")
+		judge, err := rag.ParseJudgeMode(judgeName)
+		if err != nil {
+			return err
+		}
+		answers = &answerEval{judge: judge}
+		answers.judgeModel, _ = cmd.Flags().GetString("judge-model")
+		answers.supportThreshold, _ = cmd.Flags().GetFloat64("support-threshold")
+		if answers.judgeModel == "" {
+			answers.judgeModel = viper.GetString("ollama_model")
+		}
+	}
+
+	questions, err := eval.LoadGoldenSet(goldenPath)
+	if err != nil {
+		return err
+	}
+
+	// Progress goes to stderr when stdout carries the JSON report
+	var progress io.Writer = os.Stdout
+	if jsonOutput {
+		progress = os.Stderr
+	}
+
+	var scored []eval.Question
+	for _, q := range questions {
+		if q.HasExpectations() {
+			scored = append(scored, q)
+		}
+	}
+	fmt.Fprintf(progress, "📋 Loaded %d questions from %s\n", len(questions), goldenPath)
+	loaded, skipped := len(questions), len(questions)-len(scored)
+	if skipped > 0 {
+		fmt.Fprintf(progress, "⚠️  Skipping retrieval scoring of %d questions without expected_files or expected_chunks\n", skipped)
+	}
+	if len(scored) == 0 && answers == nil {
+		return fmt.Errorf("no questions with expected sources to evaluate")
+	}
+	if answers == nil {
+		questions = scored
+	}
+
+	report := &eval.Report{
+		GoldenSet: goldenPath,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Questions: loaded,
+		Skipped:   skipped,
+	}
+	for _, settings := range configurations {
+		retrieval, answered, err := evaluateConfiguration(settings, questions, answers, progress)
+		if err != nil {
+			return fmt.Errorf("configuration %s: %w", settings.Name, err)
+		}
+		report.Configurations = append(report.Configurations, retrieval)
+		if answered != nil {
+			report.Answers = append(report.Answers, answered)
+		}
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	if len(scored) > 0 {
+		printRetrievalReport(report)
+	}
+	if len(report.Answers) > 0 {
+		printAnswerReport(report.Answers)
+	}
+	return nil
+}
+
+// evaluateConfiguration runs every question through retrieval under settings
+// and scores the results of those with expected sources. With answers set,
+// it also generates and judges an answer to every question.
+func evaluateConfiguration(settings evalSettings, questions []eval.Question, answers *answerEval, progress io.Writer) (*eval.RetrievalReport, *eval.AnswerReport, error) {
+	fmt.Fprintf(progress, "\n🧪 Evaluating %s (model: %s, data dir: %s)\n", settings.Name, settings.Model, settings.DataDir)
+
+	filter, err := parseFilter(settings.Filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	options, err := generateOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	var promptTemplate, promptSource string
+	if answers != nil {
+		promptTemplate, promptSource, err = loadPromptTemplate("", settings.PromptFile, settings.PromptName)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	embeddingService, err := embedding.NewService(settings.Model)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize embedding service: %w", err)
+	}
+	defer embeddingService.Close()
+
+	vectorStore, err := openVectorStore(settings.DataDir, vectorstore.LockShared)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize vector store: %w", err)
+	}
+	defer vectorStore.Close()
+	if vectorStore.Count() == 0 {
+		return nil, nil, fmt.Errorf("no documents indexed in %s", settings.DataDir)
+	}
+
+	store, _, err := searchStore(vectorStore, settings.DataDir, settings.Exact, settings.NProbe)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var llmClient *llm.OllamaClient
+	if settings.usesLLM() || answers != nil {
+		llmClient, err = llm.NewOllamaClient(viper.GetString("ollama_url"), viper.GetString("ollama_model"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize Ollama client: %w", err)
+		}
+		llmClient.SetOptions(options)
+	}
+
+	pipeline := rag.NewPipeline(embeddingService, store, llmClient)
+	if len(filter) > 0 {
+		pipeline.SetFilter(filter)
+	}
+	pipeline.SetHierarchical(settings.Hierarchical)
+	if settings.Hybrid {
+		if err := checkHybrid(vectorStore); err != nil {
+			return nil, nil, err
+		}
+		pipeline.SetHybrid(true)
+	}
+	pipeline.SetQueryTransforms(rag.QueryTransforms{
+		Rewrite:     settings.Rewrite,
+		Keywords:    settings.Keywords,
+		HyDE:        settings.HyDE,
+		Paraphrases: settings.MultiQuery,
+	})
+
+	var answerReport *eval.AnswerReport
+	if answers != nil {
+		grounding, err := groundingPolicy()
+		if err != nil {
+			return nil, nil, err
+		}
+		pipeline.SetGroundingPolicy(grounding)
+		pipeline.SetSystemPrompt(viper.GetString("llm.system_prompt"))
+		if promptTemplate != "" {
+			if err := pipeline.SetPromptTemplate(promptTemplate); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", promptSource, err)
+			}
+			fmt.Fprintf(progress, "📝 Using prompt template %s\n", promptSource)
+		}
+		answerReport = &eval.AnswerReport{Name: settings.Name, Judge: answers.judge, SupportThreshold: answers.supportThreshold}
+		if answers.judge == rag.JudgeLLM {
+			answerReport.JudgeModel = answers.judgeModel
+			if answers.judgeModel != llmClient.GetModel() {
+				judge, err := llm.NewOllamaClient(viper.GetString("ollama_url"), answers.judgeModel)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to initialize judge model: %w", err)
+				}
+				judge.SetOptions(options)
+				pipeline.SetJudge(judge)
+			}
+		}
+	}
+
+	report := &eval.RetrievalReport{Name: settings.Name, Settings: settings, K: settings.TopK}
+	for i, q := range questions {
+		fmt.Fprintf(progress, "  [%d/%d] %s", i+1, len(questions), truncateString(q.Question, 60))
+
+		var answer string
+		var results []*vectorstore.SearchResult
+		var insufficient *rag.InsufficientContextError
+		if answers != nil {
+			answer, results, err = pipeline.Query(q.Question, settings.TopK, settings.Threshold)
+			if errors.As(err, &insufficient) {
+				err = nil
+			}
+		} else {
+			results, err = pipeline.Retrieve(q.Question, settings.TopK, settings.Threshold)
+		}
+		if err != nil {
+			fmt.Fprintf(progress, " ❌ %v\n", err)
+			if q.HasExpectations() {
+				report.Results = append(report.Results, eval.RetrievalResult{ID: q.ID, Question: q.Question, Error: err.Error()})
+			}
+			if answerReport != nil {
+				answerReport.Results = append(answerReport.Results, eval.AnswerResult{ID: q.ID, Question: q.Question, Error: err.Error()})
+			}
+			continue
+		}
+
+		if q.HasExpectations() {
+			result := eval.ScoreRetrieval(q, results, settings.TopK)
+			report.Results = append(report.Results, result)
+			if len(result.RelevantRanks) > 0 {
+				fmt.Fprintf(progress, " ✅ first hit at rank %d", result.RelevantRanks[0])
+			} else {
+				fmt.Fprintf(progress, " ⚠️  no expected source retrieved")
+			}
+		}
+		if answerReport != nil {
+			result := judgeAnswer(pipeline, q, answer, results, insufficient, answers)
+			answerReport.Results = append(answerReport.Results, result)
+			switch {
+			case result.Error != "":
+				fmt.Fprintf(progress, " ❌ %s", result.Error)
+			case result.Abstained:
+				fmt.Fprintf(progress, " 🤷 abstained (%s)", result.AbstainReason)
+			default:
+				fmt.Fprintf(progress, " 📝 faithfulness %.2f, relevance %.2f", result.Metrics.Faithfulness, result.Metrics.Relevance)
+			}
+		}
+		fmt.Fprintln(progress)
+	}
+	report.Metrics = eval.MeanRetrievalMetrics(report.Results)
+	if answerReport != nil {
+		answerReport.Summarize()
+	}
+	return report, answerReport, nil
+}
+
+// judgeAnswer scores a generated answer against the chunks it was based on,
+// or records why the grounding policy declined to answer
+func judgeAnswer(pipeline *rag.Pipeline, q eval.Question, answer string, results []*vectorstore.SearchResult, insufficient *rag.InsufficientContextError, answers *answerEval) eval.AnswerResult {
+	retrieved := make([]string, len(results))
+	for i, result := range results {
+		retrieved[i] = result.ID
+	}
+	if insufficient != nil {
+		return eval.AnswerResult{
+			ID:            q.ID,
+			Question:      q.Question,
+			Answer:        insufficient.Answer,
+			Retrieved:     retrieved,
+			Abstained:     true,
+			AbstainReason: insufficient.Reason,
+		}
+	}
+
+	judgement, err := pipeline.JudgeAnswer(q.Question, answer, results, answers.judge, answers.supportThreshold)
+	if err != nil {
+		return eval.AnswerResult{ID: q.ID, Question: q.Question, Answer: answer, Retrieved: retrieved, Error: err.Error()}
+	}
+	return eval.NewAnswerResult(q, answer, retrieved, judgement)
+}
+
+// printRetrievalReport shows the metrics of each configuration side by side
+// and, when comparing, the questions whose first hit moved
+func printRetrievalReport(report *eval.Report) {
+	configs := report.Configurations
+	fmt.Printf("\n📊 Retrieval metrics (%d questions)\n", len(configs[0].Results))
+	fmt.Println(strings.Repeat("-", 80))
+
+	header := fmt.Sprintf("%-14s", "metric")
+	for _, c := range configs {
+		header += fmt.Sprintf(" %14s", truncateString(c.Name, 14))
+	}
+	if len(configs) == 2 {
+		header += fmt.Sprintf(" %10s", "change")
+	}
+	fmt.Println(header)
+
+	rows := []struct {
+		name  string
+		value func(eval.RetrievalMetrics) float64
+	}{
+		{"recall@%d", func(m eval.RetrievalMetrics) float64 { return m.Recall }},
+		{"precision@%d", func(m eval.RetrievalMetrics) float64 { return m.Precision }},
+		{"MRR", func(m eval.RetrievalMetrics) float64 { return m.MRR }},
+		{"nDCG@%d", func(m eval.RetrievalMetrics) float64 { return m.NDCG }},
+	}
+	for _, row := range rows {
+		name := row.name
+		if strings.Contains(name, "%d") {
+			name = fmt.Sprintf(name, configs[0].K)
+			if len(configs) == 2 && configs[1].K != configs[0].K {
+				name = strings.Replace(row.name, "%d", "k", 1)
+			}
+		}
+		line := fmt.Sprintf("%-14s", name)
+		for _, c := range configs {
+			line += fmt.Sprintf(" %14.3f", row.value(c.Metrics))
+		}
+		if len(configs) == 2 {
+			line += fmt.Sprintf(" %+10.3f", row.value(configs[1].Metrics)-row.value(configs[0].Metrics))
+		}
+		fmt.Println(line)
+	}
+	fmt.Println(strings.Repeat("-", 80))
+
+	if len(configs) != 2 {
+		for _, r := range configs[0].Results {
+			if len(r.RelevantRanks) == 0 {
+				fmt.Printf("❌ %s: %s\n", r.ID, truncateString(r.Question, 60))
+			}
+		}
+		return
+	}
+
+	better, worse := 0, 0
+	for i, a := range configs[0].Results {
+		b := configs[1].Results[i]
+		if a.Metrics.MRR == b.Metrics.MRR {
+			continue
+		}
+		marker := "▼"
+		if b.Metrics.MRR > a.Metrics.MRR {
+			marker = "▲"
+			better++
+		} else {
+			worse++
+		}
+		fmt.Printf("%s %s: first hit %s → %s  %s\n", marker, a.ID, firstHit(a), firstHit(b), truncateString(a.Question, 50))
+	}
+	fmt.Printf("\n%s improved %d questions and worsened %d\n", configs[1].Name, better, worse)
+}
+
+// firstHit describes the rank of the first expected chunk
+func firstHit(r eval.RetrievalResult) string {
+	if len(r.RelevantRanks) == 0 {
+		return "none"
+	}
+	return strconv.Itoa(r.RelevantRanks[0])
+}
+
+// printAnswerReport shows the answer metrics of each configuration side by
+// side, followed by the unsupported sentences of each answer
+func printAnswerReport(reports []*eval.AnswerReport) {
+	judge := string(reports[0].Judge)
+	if reports[0].JudgeModel != "" {
+		judge += ", " + reports[0].JudgeModel
+	}
+	fmt.Printf("\n📝 Answer quality (judge: %s)\n", judge)
+	fmt.Println(strings.Repeat("-", 80))
+
+	header := fmt.Sprintf("%-14s", "metric")
+	for _, r := range reports {
+		header += fmt.Sprintf(" %14s", truncateString(r.Name, 14))
+	}
+	if len(reports) == 2 {
+		header += fmt.Sprintf(" %10s", "change")
+	}
+	fmt.Println(header)
+
+	rows := []struct {
+		name  string
+		value func(*eval.AnswerReport) float64
+	}{
+		{"faithfulness", func(r *eval.AnswerReport) float64 { return r.Metrics.Faithfulness }},
+		{"relevance", func(r *eval.AnswerReport) float64 { return r.Metrics.Relevance }},
+	}
+	for _, row := range rows {
+		line := fmt.Sprintf("%-14s", row.name)
+		for _, r := range reports {
+			line += fmt.Sprintf(" %14.3f", row.value(r))
+		}
+		if len(reports) == 2 {
+			line += fmt.Sprintf(" %+10.3f", row.value(reports[1])-row.value(reports[0]))
+		}
+		fmt.Println(line)
+	}
+	counts := []struct {
+		name  string
+		value func(*eval.AnswerReport) int
+	}{
+		{"answered", func(r *eval.AnswerReport) int { return r.Answered }},
+		{"abstained", func(r *eval.AnswerReport) int { return r.Abstained }},
+		{"failed", func(r *eval.AnswerReport) int { return r.Failed }},
+	}
+	for _, row := range counts {
+		line := fmt.Sprintf("%-14s", row.name)
+		for _, r := range reports {
+			line += fmt.Sprintf(" %14d", row.value(r))
+		}
+		fmt.Println(line)
+	}
+	fmt.Println(strings.Repeat("-", 80))
+
+	for _, r := range reports {
+		for _, result := range r.Results {
+			if !result.Scored() {
+				continue
+			}
+			for _, s := range result.Sentences {
+				if s.Supported {
+					continue
+				}
+				prefix := result.ID
+				if len(reports) > 1 {
+					prefix = r.Name + "/" + result.ID
+				}
+				fmt.Printf("⚠️  %s: unsupported: %s\n", prefix, truncateString(s.Sentence, 80))
+			}
+		}
+	}
+}
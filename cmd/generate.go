@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"edgerag/internal/eval"
+	"edgerag/internal/llm"
+	"edgerag/internal/vectorstore"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "gen-questions [output.jsonl]",
+	Short: "Generate a golden set of questions from indexed chunks",
+	Long: `Bootstrap a golden set for 'edgerag eval' from the indexed documents.
+
+Chunks are sampled at random from the vector store, and for each the LLM
+writes questions the chunk answers. Questions that are not phrased as
+questions, are too short or too long, refer to "the passage", repeat an
+earlier question, share no terms with the chunk or copy a long run of its
+words are dropped. The rest are written as JSONL with the chunk's ID as the
+expected chunk:
+
+  {"id": "synthetic-001", "question": "...", "expected_chunks": ["3f2a..._semantic_4"]}
+
+The sample depends only on --seed and the indexed chunks, and the seed is
+also passed to the LLM, so the same seed, model and index give the same set.
+Review the questions before relying on them: the LLM may still write some
+that the chunk doesn't answer, or that other chunks answer as well.
+
+Examples:
+  edgerag gen-questions golden.jsonl
+  edgerag gen-questions golden.jsonl --samples 200 --per-chunk 1 --seed 7
+  edgerag gen-questions docs.jsonl --filter file_type=markdown`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGenerate,
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+
+	generateCmd.Flags().IntP("samples", "n", 50, "Number of chunks to sample")
+	generateCmd.Flags().Int("per-chunk", 2, "Number of questions to ask for per chunk")
+	generateCmd.Flags().Int("seed", 42, "Random seed for sampling and generation")
+	generateCmd.Flags().Int("min-chunk-length", 200, "Skip chunks shorter than this many characters")
+	generateCmd.Flags().StringArray("filter", nil, "Only sample chunks whose metadata matches key=value (repeatable)")
+	generateCmd.Flags().String("data-dir", "", "Vector store directory to sample (default: the one 'edgerag index' writes)")
+	generateCmd.Flags().BoolP("verbose", "v", false, "Show rejected questions and why")
+}
+
+func runGenerate(cmd *cobra.Command, args []string) error {
+	outputPath := args[0]
+	samples, _ := cmd.Flags().GetInt("samples")
+	perChunk, _ := cmd.Flags().GetInt("per-chunk")
+	seed, _ := cmd.Flags().GetInt("seed")
+	minLength, _ := cmd.Flags().GetInt("min-chunk-length")
+	filterArgs, _ := cmd.Flags().GetStringArray("filter")
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	if samples <= 0 || perChunk <= 0 {
+		return fmt.Errorf("samples and per-chunk must be positive")
+	}
+	filter, err := parseFilter(filterArgs)
+	if err != nil {
+		return err
+	}
+	if dataDir == "" {
+		dataDir = vectorDataDir()
+	}
+
+	vectorStore, err := openVectorStore(dataDir, vectorstore.LockShared)
+	if err != nil {
+		return fmt.Errorf("failed to initialize vector store: %w", err)
+	}
+	defer vectorStore.Close()
+	if vectorStore.Count() == 0 {
+		return fmt.Errorf("no documents indexed. Please run 'edgerag index' first")
+	}
+
+	chunks, err := eval.SampleChunks(vectorStore, eval.SampleOptions{
+		Count:     samples,
+		MinLength: minLength,
+		Filter:    filter,
+		Seed:      int64(seed),
+	})
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("no chunks of at least %d characters match", minLength)
+	}
+	fmt.Printf("🎲 Sampled %d of %d chunks (seed %d)\n", len(chunks), vectorStore.Count(), seed)
+
+	fmt.Printf("🤖 Connecting to Ollama at %s...\n", viper.GetString("ollama_url"))
+	llmClient, err := llm.NewOllamaClient(viper.GetString("ollama_url"), viper.GetString("ollama_model"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize Ollama client: %w", err)
+	}
+	options, err := generateOptions()
+	if err != nil {
+		return err
+	}
+	options.Seed = &seed
+	llmClient.SetOptions(options)
+
+	generator := eval.NewQuestionGenerator(llmClient, perChunk)
+	var questions []eval.Question
+	reasons := make(map[string]int)
+	rejectedCount := 0
+	for i, chunk := range chunks {
+		file, _ := chunk.Metadata["file"].(string)
+		fmt.Printf("  [%d/%d] %s (%s)", i+1, len(chunks), chunk.ID, file)
+
+		accepted, rejected, err := generator.Generate(chunk)
+		if err != nil {
+			fmt.Printf(" ❌ %v\n", err)
+			continue
+		}
+		fmt.Printf(" ✅ %d questions", len(accepted))
+		if len(rejected) > 0 {
+			fmt.Printf(", %d rejected", len(rejected))
+		}
+		fmt.Println()
+
+		for _, q := range accepted {
+			q.ID = fmt.Sprintf("synthetic-%03d", len(questions)+1)
+			questions = append(questions, q)
+			if verbose {
+				fmt.Printf("      + %s\n", q.Question)
+			}
+		}
+		for _, r := range rejected {
+			reasons[r.Reason]++
+			rejectedCount++
+			if verbose {
+				fmt.Printf("      - %s (%s)\n", r.Question, r.Reason)
+			}
+		}
+	}
+
+	if len(questions) == 0 {
+		return fmt.Errorf("no questions passed the quality filter")
+	}
+	if err := eval.WriteGoldenSet(outputPath, questions); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n📝 Wrote %d questions to %s\n", len(questions), outputPath)
+	if rejectedCount > 0 {
+		names := make([]string, 0, len(reasons))
+		for reason := range reasons {
+			names = append(names, reason)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if reasons[names[i]] != reasons[names[j]] {
+				return reasons[names[i]] > reasons[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		fmt.Printf("🗑️  Rejected %d:", rejectedCount)
+		for _, reason := range names {
+			fmt.Printf(" %s %d;", reason, reasons[reason])
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Run 'edgerag eval %s' to measure retrieval against them\n", outputPath)
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"edgerag/internal/rag"
+)
+
+// loadHistory reads the turns of a conversation from a JSON history file, a
+// list of {"question": ..., "answer": ...} objects. A missing file is an
+// empty conversation, so the first query starts one.
+func loadHistory(path string) ([]rag.Turn, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	var turns []rag.Turn
+	if err := json.Unmarshal(data, &turns); err != nil {
+		return nil, fmt.Errorf("failed to parse history %s: %w", path, err)
+	}
+	return turns, nil
+}
+
+// saveHistory writes the turns of a conversation to a JSON history file
+func saveHistory(path string, turns []rag.Turn) error {
+	data, err := json.MarshalIndent(turns, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// recentTurns returns the last n turns
+func recentTurns(turns []rag.Turn, n int) []rag.Turn {
+	if len(turns) <= n {
+		return turns
+	}
+	return turns[len(turns)-n:]
+}
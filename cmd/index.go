@@ -19,43 +19,120 @@ var indexCmd = &cobra.Command{
 	Short: "Index documents for retrieval",
 	Long: `Index documents by processing them, generating embeddings, and storing them in the vector database.
 	
-Supported file formats:
-- .txt (plain text)
-- .md (markdown)
-- .go (Go source code)
-- .py (Python source code)
-- .js (JavaScript source code)
+Supported file formats (every one is indexed unless --extensions is given):
+%s
+Other extensions listed in --extensions are read as plain text.
 
-Chunking strategies:
-- Character-based: Fixed-size chunks with character boundaries (default)
-- Semantic: Intelligent splitting on paragraphs, sections, and natural boundaries
+Chunking strategies (--chunker):
+- fixed: Fixed-size chunks with character boundaries (default)
+- semantic: Intelligent splitting on paragraphs, sections, and natural boundaries;
+  Go, Python and JavaScript/TypeScript source is split per function and class
+  (same as --semantic)
+- topic: Sentences are embedded and chunks end where the topic shifts, i.e.
+  where the similarity between neighbouring sentences drops the most
+
+Chunk sizes are in characters unless --chunk-unit tokens is given, which
+measures them in the embedding model's own tokens. Either way, chunks longer
+than the model's maximum sequence length are reported, since the model
+truncates them when they are embedded.
+
+Markdown chunks record their heading path (e.g. "Deploy Guide > Configuration")
+as heading_path metadata; --heading-context also embeds it with the chunk so
+that the headings a section sits under inform its vector. YAML front matter
+fields are stored as metadata and can be used with --filter.
+
+With --hierarchical every chunk is stored twice over: as it is (the coarse
+level) and split into fine chunks of whole sentences, each linked to its
+coarse chunk. edgerag query --hierarchical matches questions against the fine
+chunks and answers from their coarse chunks.
 
 Examples:
   edgerag index ./docs
   edgerag index file.txt
   edgerag index . --recursive
-  edgerag index docs/ --semantic --chunk-size 800`,
+  edgerag index docs/ --semantic --chunk-size 800
+  edgerag index docs/ --semantic --chunk-unit tokens --chunk-size 120 --chunk-overlap 20
+  edgerag index docs/ --semantic --heading-context
+  edgerag index docs/ --semantic --chunk-size 1500 --hierarchical --fine-chunk-size 200
+  edgerag index docs/ --chunker topic --chunk-size 1000 --min-chunk-size 200
+  edgerag index docs/ --recursive --extensions .md,.pdf`,
 	Args: cobra.ExactArgs(1),
 	RunE: runIndex,
 }
 
 func init() {
 	rootCmd.AddCommand(indexCmd)
+	indexCmd.Long = fmt.Sprintf(indexCmd.Long, supportedFormatsHelp())
 	
 	indexCmd.Flags().BoolP("recursive", "r", false, "Recursively index directories")
-	indexCmd.Flags().StringSliceP("extensions", "e", []string{".txt", ".md", ".go", ".py", ".js"}, "File extensions to index")
+	indexCmd.Flags().StringSliceP("extensions", "e", nil, "File extensions to index (default: every extension with a registered loader)")
 	indexCmd.Flags().IntP("chunk-size", "c", 200, "Maximum chunk size for document splitting")
 	indexCmd.Flags().IntP("chunk-overlap", "o", 50, "Overlap between chunks when splitting documents")
 	indexCmd.Flags().BoolP("semantic", "s", false, "Use semantic chunking (split on paragraphs/sections)")
+	indexCmd.Flags().String("chunk-unit", "chars", "Unit of --chunk-size and --chunk-overlap: chars or tokens (embedding model tokens)")
+	indexCmd.Flags().String("chunker", "", "Chunking strategy: fixed, semantic or topic (default fixed, or semantic with --semantic)")
+	indexCmd.Flags().Int("min-chunk-size", 0, "Smallest chunk the topic chunker ends at a topic shift (default: a quarter of --chunk-size)")
+	indexCmd.Flags().Int("topic-window", 2, "Sentences averaged on each side of a gap when the topic chunker compares them")
+	indexCmd.Flags().Float64("topic-percentile", 90, "Topic chunker: end chunks at similarity drops above this percentile of the document's drops")
+	indexCmd.Flags().Bool("hierarchical", false, "Also split every chunk into sentence-level fine chunks, linked to it, for edgerag query --hierarchical")
+	indexCmd.Flags().Int("fine-chunk-size", 0, "Largest fine chunk with --hierarchical, in --chunk-unit units (default: a quarter of --chunk-size)")
+	indexCmd.Flags().Bool("heading-context", false, "Embed each markdown chunk with its heading path (e.g. \"Deploy Guide > Configuration\") before its text")
+	indexCmd.Flags().Float64("topic-threshold", 0, "Topic chunker: end chunks where the cosine distance between neighbouring sentences reaches this value (overrides --topic-percentile)")
 }
 
 func runIndex(cmd *cobra.Command, args []string) error {
 	path := args[0]
 	recursive, _ := cmd.Flags().GetBool("recursive")
 	extensions, _ := cmd.Flags().GetStringSlice("extensions")
+	if len(extensions) == 0 {
+		extensions = document.SupportedExtensions()
+	}
+	for i, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[i] = ext
+	}
 	chunkSize, _ := cmd.Flags().GetInt("chunk-size")
 	chunkOverlap, _ := cmd.Flags().GetInt("chunk-overlap")
 	useSemantic, _ := cmd.Flags().GetBool("semantic")
+	chunkUnit, _ := cmd.Flags().GetString("chunk-unit")
+	if chunkUnit != "chars" && chunkUnit != "tokens" {
+		return fmt.Errorf("invalid --chunk-unit %q: must be chars or tokens", chunkUnit)
+	}
+	chunker, _ := cmd.Flags().GetString("chunker")
+	switch chunker {
+	case "":
+		chunker = "fixed"
+		if useSemantic {
+			chunker = "semantic"
+		}
+	case "fixed", "semantic", "topic":
+	default:
+		return fmt.Errorf("invalid --chunker %q: must be fixed, semantic or topic", chunker)
+	}
+	minChunkSize, _ := cmd.Flags().GetInt("min-chunk-size")
+	if minChunkSize <= 0 {
+		minChunkSize = chunkSize / 4
+	}
+	topicWindow, _ := cmd.Flags().GetInt("topic-window")
+	topicPercentile, _ := cmd.Flags().GetFloat64("topic-percentile")
+	topicThreshold, _ := cmd.Flags().GetFloat64("topic-threshold")
+	headingContext, _ := cmd.Flags().GetBool("heading-context")
+	hierarchical, _ := cmd.Flags().GetBool("hierarchical")
+	fineChunkSize, _ := cmd.Flags().GetInt("fine-chunk-size")
+	if fineChunkSize <= 0 {
+		fineChunkSize = chunkSize / 4
+	}
+
+	// The text embedded for a chunk; the stored content is unchanged
+	embedText := func(chunk *document.Chunk) string {
+		return chunk.Content
+	}
+	if headingContext {
+		embedText = document.EmbeddingText
+	}
 
 	// Initialize embedding service
 	model := viper.GetString("model")
@@ -68,21 +145,81 @@ func runIndex(cmd *cobra.Command, args []string) error {
 	defer embeddingService.Close()
 	fmt.Printf("✅ Embedding service ready\n")
 
+	// Token counts size chunks in tokens mode and flag chunks the model
+	// would truncate in either mode
+	var tokenCounter *embedding.TokenCounter
+	var countTokens document.SizeFunc
+	maxSeqLength := 0
+	info, err := embeddingService.ModelInfo()
+	if err == nil {
+		maxSeqLength = info.MaxSeqLength
+		tokenCounter, err = embeddingService.TokenCounter()
+	}
+	if err == nil {
+		countTokens = tokenCounter.Count
+	}
+	if err != nil {
+		if chunkUnit == "tokens" {
+			return fmt.Errorf("failed to load the model's tokenizer: %w", err)
+		}
+		fmt.Printf("⚠️  Can't count tokens (%v); chunk lengths won't be checked against the model\n", err)
+	} else {
+		fmt.Printf("🔤 Model reads up to %d tokens per chunk\n", maxSeqLength)
+		if chunkUnit == "tokens" && maxSeqLength > 0 && chunkSize > maxSeqLength {
+			fmt.Printf("⚠️  --chunk-size %d is larger than the model's %d-token limit; longer chunks will be truncated\n", chunkSize, maxSeqLength)
+		}
+	}
+
 	// Initialize vector store
 	fmt.Printf("💾 Initializing vector store...\n")
-	dataDir := filepath.Join(os.Getenv("HOME"), ".edgerag", "vectors")
-	vectorStore, err := vectorstore.NewPersistentStore(dataDir)
+	dataDir := vectorDataDir()
+	vectorStore, err := openVectorStore(dataDir, vectorstore.LockExclusive)
 	if err != nil {
 		return fmt.Errorf("failed to initialize vector store: %w", err)
 	}
+	defer vectorStore.Close()
 	fmt.Printf("✅ Vector store ready (data dir: %s)\n", dataDir)
+
+	// Keep a trained IVF-PQ index in sync with newly added vectors
+	var store vectorstore.VectorStore = vectorStore
+	index, err := loadIVFPQIndex(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to load IVF-PQ index: %w", err)
+	}
+	if index != nil {
+		store = vectorstore.NewIndexedStore(vectorStore, index, 0)
+		fmt.Printf("⚡ IVF-PQ index found; new vectors will be encoded into it\n")
+	}
 	
 	// Show chunking strategy
-	if useSemantic {
-		fmt.Printf("📄 Using semantic chunking (max size: %d chars, overlap: %d chars)\n", chunkSize, chunkOverlap)
+	chunkOptions := document.ChunkOptions{
+		MaxSize:  chunkSize,
+		Overlap:  chunkOverlap,
+		Semantic: chunker == "semantic",
+	}
+	topicOptions := document.TopicOptions{
+		MaxSize:    chunkSize,
+		MinSize:    minChunkSize,
+		Window:     topicWindow,
+		Threshold:  topicThreshold,
+		Percentile: topicPercentile,
+	}
+	if chunkUnit == "tokens" {
+		chunkOptions.Size = countTokens
+		topicOptions.Size = countTokens
+	}
+	if chunker == "topic" {
+		fmt.Printf("📄 Using topic-shift chunking (size: %d-%d %s, window: %d sentences)\n", minChunkSize, chunkSize, chunkUnit, topicWindow)
+	} else if chunker == "semantic" {
+		fmt.Printf("📄 Using semantic chunking (max size: %d %s, overlap: %d %s)\n", chunkSize, chunkUnit, chunkOverlap, chunkUnit)
+	} else if chunkUnit == "tokens" {
+		fmt.Printf("📄 Using token-based chunking (size: %d tokens, overlap: %d tokens)\n", chunkSize, chunkOverlap)
 	} else {
 		fmt.Printf("📄 Using character-based chunking (size: %d chars, overlap: %d chars)\n", chunkSize, chunkOverlap)
 	}
+	if hierarchical {
+		fmt.Printf("🪜 Hierarchical index: each chunk is also split into fine chunks of up to %d %s\n", fineChunkSize, chunkUnit)
+	}
 	fmt.Println()
 
 	// Get files to process
@@ -104,34 +241,74 @@ func runIndex(cmd *cobra.Command, args []string) error {
 			fmt.Printf(" ❌ Failed to load %s: %v\n", file, err)
 			continue
 		}
-		fmt.Printf(" ✅ Loaded (%d bytes)\n", len(doc.Content))
+		if len(doc.Pages) > 0 {
+			unit := doc.PageUnit
+			if unit == "" {
+				unit = "page"
+			}
+			fmt.Printf(" ✅ Loaded (%d bytes from %d %ss)\n", len(doc.Content), len(doc.Pages), unit)
+		} else {
+			fmt.Printf(" ✅ Loaded (%d bytes)\n", len(doc.Content))
+		}
 
 		fmt.Printf("  ⏳ Chunking document...")
 		var chunks []*document.Chunk
-		if useSemantic {
-			chunks = document.ChunkSmartDocument(doc, chunkSize, chunkOverlap)
+		if chunker == "topic" {
+			chunks, err = document.ChunkByTopic(doc, embeddingService, topicOptions)
+			if err != nil {
+				fmt.Printf(" ❌ Failed to chunk %s: %v\n", file, err)
+				continue
+			}
+		} else {
+			chunks = document.ChunkWithOptions(doc, chunkOptions)
+		}
+		if hierarchical {
+			coarse := len(chunks)
+			chunks = document.ChunkHierarchy(doc, chunks, fineChunkSize, chunkOptions.Size)
+			fmt.Printf(" ✅ Created %d chunks and %d fine chunks\n", coarse, len(chunks)-coarse)
 		} else {
-			chunks = document.ChunkDocument(doc, chunkSize, chunkOverlap)
+			fmt.Printf(" ✅ Created %d chunks\n", len(chunks))
+		}
+		if countTokens != nil && maxSeqLength > 0 {
+			reportTruncatedChunks(chunks, embedText, countTokens, maxSeqLength)
+		}
+		// Counts after a worker failure are estimates, which would size
+		// chunks in the wrong unit
+		if tokenCounter != nil && tokenCounter.Err() != nil {
+			if chunkUnit == "tokens" {
+				return fmt.Errorf("failed to size chunks of %s: %w", file, tokenCounter.Err())
+			}
+			fmt.Printf("  ⚠️  %v; chunk lengths won't be checked against the model\n", tokenCounter.Err())
+			tokenCounter, countTokens = nil, nil
 		}
-		fmt.Printf(" ✅ Created %d chunks\n", len(chunks))
 
-		// Generate embeddings for each chunk
+		// Generate embeddings for each chunk. Coarse chunks come before their
+		// fine chunks, which are dropped when the coarse chunk isn't stored
+		// so retrieval never meets a fine chunk without its parent.
 		fmt.Printf("  ⏳ Generating embeddings...\n")
+		unstored := make(map[string]bool)
 		for j, chunk := range chunks {
 			fmt.Printf("    [%d/%d] Embedding chunk %d (%.1f%%)...", 
 				j+1, len(chunks), j+1, float64(j+1)/float64(len(chunks))*100)
+
+			if parentID, _ := chunk.Metadata["parent_chunk_id"].(string); unstored[parentID] {
+				fmt.Printf(" ⏭️  Skipped (coarse chunk %s wasn't stored)\n", parentID)
+				continue
+			}
 			
-			embedding, err := embeddingService.GetEmbedding(chunk.Content)
+			embedding, err := embeddingService.GetEmbedding(embedText(chunk))
 			if err != nil {
 				fmt.Printf(" ❌ Failed: %v\n", err)
+				unstored[chunk.ID] = true
 				continue
 			}
 			fmt.Printf(" ✅ Done (%d dims)\n", len(embedding))
 
 			// Store in vector database
-			err = vectorStore.Add(chunk.ID, embedding, chunk.Content, chunk.Metadata)
+			err = store.Add(chunk.ID, embedding, chunk.Content, chunk.Metadata)
 			if err != nil {
 				fmt.Printf("    ❌ Failed to store chunk %d: %v\n", j, err)
+				unstored[chunk.ID] = true
 				continue
 			}
 		}
@@ -139,6 +316,12 @@ func runIndex(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 	}
 
+	if index != nil {
+		if err := index.Save(filepath.Join(dataDir, vectorstore.IVFPQIndexFile)); err != nil {
+			return fmt.Errorf("failed to save IVF-PQ index: %w", err)
+		}
+	}
+
 	fmt.Printf("\nIndexing complete! Indexed %d documents with %d total vectors\n", 
 		len(files), vectorStore.Count())
 
@@ -190,6 +373,34 @@ func getFilesToProcess(path string, recursive bool, extensions []string) ([]stri
 	return files, err
 }
 
+// reportTruncatedChunks warns about chunks longer than the model's maximum
+// sequence length, whose ends are dropped when they are embedded
+func reportTruncatedChunks(chunks []*document.Chunk, embedText func(*document.Chunk) string, countTokens document.SizeFunc, maxSeqLength int) {
+	over, longest := 0, 0
+	for _, chunk := range chunks {
+		tokens := countTokens(embedText(chunk))
+		if tokens > maxSeqLength {
+			over++
+		}
+		if tokens > longest {
+			longest = tokens
+		}
+	}
+	if over > 0 {
+		fmt.Printf("  ⚠️  %d of %d chunks exceed the model's %d-token limit and will be truncated (longest: %d tokens)\n",
+			over, len(chunks), maxSeqLength, longest)
+	}
+}
+
+// supportedFormatsHelp lists the registered loaders for the help text
+func supportedFormatsHelp() string {
+	var b strings.Builder
+	for _, loader := range document.Loaders() {
+		fmt.Fprintf(&b, "- %s: %s\n", loader.Name(), strings.Join(loader.Extensions(), ", "))
+	}
+	return b.String()
+}
+
 func hasValidExtension(filename string, extensions []string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
 	for _, validExt := range extensions {
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"edgerag/internal/rag"
+)
+
+// promptExtensions are the file extensions tried, in order, for a template
+// name in the prompt library
+var promptExtensions = []string{".tmpl", ".txt"}
+
+// promptDir returns the prompt library directory: prompts.dir from the
+// config, or ~/.edgerag/prompts
+func promptDir() string {
+	if dir := viper.GetString("prompts.dir"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".edgerag", "prompts")
+}
+
+// loadPromptTemplate returns the prompt template chosen by an inline
+// template, a template file or the name of a template in the library, at
+// most one of which may be set, or else by the prompts.default config. It
+// returns "" for the built-in prompt. The template is validated, and source
+// describes where it came from.
+func loadPromptTemplate(inline, file, name string) (text, source string, err error) {
+	set := 0
+	for _, option := range []string{inline, file, name} {
+		if option != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return "", "", fmt.Errorf("use only one of --prompt-template, --prompt-template-file and --prompt-name")
+	}
+	if set == 0 {
+		name = viper.GetString("prompts.default")
+	}
+
+	switch {
+	case inline != "":
+		text, source = inline, "--prompt-template"
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		text, source = string(data), file
+	case name != "":
+		path, err := findPromptTemplate(name)
+		if err != nil {
+			return "", "", err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		text, source = string(data), path
+	default:
+		return "", "", nil
+	}
+
+	if _, err := rag.ParsePromptTemplate(filepath.Base(source), text); err != nil {
+		return "", "", fmt.Errorf("%s: %w", source, err)
+	}
+	return text, source, nil
+}
+
+// findPromptTemplate returns the path of a named template in the library
+func findPromptTemplate(name string) (string, error) {
+	dir := promptDir()
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid prompt name %q: use --prompt-template-file for paths", name)
+	}
+	for _, ext := range promptExtensions {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	available := listPromptTemplates(dir)
+	if len(available) == 0 {
+		return "", fmt.Errorf("prompt template %q not found: %s has no templates", name, dir)
+	}
+	return "", fmt.Errorf("prompt template %q not found in %s (available: %s)", name, dir, strings.Join(available, ", "))
+}
+
+// listPromptTemplates returns the names of the templates in a library
+// directory
+func listPromptTemplates(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		for _, promptExt := range promptExtensions {
+			name := strings.TrimSuffix(entry.Name(), ext)
+			if ext == promptExt && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
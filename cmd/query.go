@@ -1,14 +1,16 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"edgerag/internal/document"
 	"edgerag/internal/embedding"
 	"edgerag/internal/llm"
 	"edgerag/internal/rag"
@@ -26,7 +28,67 @@ This command will:
 
 Examples:
   edgerag query "How do I initialize a Go module?"
-  edgerag query "What are the main features of this project?" --top-k 5`,
+  edgerag query "What are the main features of this project?" --top-k 5
+  edgerag query "Where is the retry policy configured?" --nprobe 32
+  edgerag query "How is logging set up?" --filter extension=.go
+  edgerag query "How do I roll back a release?" --expand neighbors --expand-window 2
+  edgerag query "What does the deploy guide say about secrets?" --expand parent
+  edgerag query "Which port does the API listen on?" --hierarchical
+  edgerag query "What does error E1042 mean?" --hybrid --store-backend sqlite
+  edgerag query "why is sync slow" --rewrite --expand-keywords --verbose
+  edgerag query "How are retries configured?" --hyde
+  edgerag query "How do I rotate the API keys?" --multi-query 3
+  edgerag query "Summarize the release process" --prompt-name cite-sources
+  edgerag query "What changed in v2?" --temperature 0 --num-ctx 8192
+  edgerag query "Does a rollback undo migrations?" --history chat.json
+
+Context expansion (--expand) hands the LLM more than the matched chunks:
+- neighbors: each match with the --expand-window chunks either side of it
+- parent: each match's enclosing markdown section, or its whole document,
+  up to --expand-max-chars characters around the match
+Matches from the same document whose expansions overlap are merged.
+
+--hybrid also searches the full-text index of the sqlite backend for the
+query's keywords, and fuses that ranking with the similarity ranking.
+
+Query transformations ask the LLM to turn the question into a better search
+before retrieval; the question itself is still what gets answered:
+- --rewrite: restate it as a self-contained, specific search query
+- --expand-keywords: add related keywords and synonyms to the query
+- --hyde: draft a hypothetical answer and search with it alongside the query
+- --multi-query N: also search for N paraphrases of the question and fuse the
+  rankings with reciprocal rank fusion
+Use --verbose to see the transformed queries.
+
+A grounding policy makes the LLM decline rather than answer from weak
+matches. Retrieval is rejected when the best match scores below --min-score,
+or leads the other matches by less than --min-margin on average. With
+--verify, the answer's sentences are checked against the context, and the
+answer is withheld when less than --min-supported of them are supported.
+All are off by default, and can be set in the config file under grounding
+(min_score, min_margin, verify, verify_judge, min_supported).
+
+Prompt templates use Go text/template syntax over {{.Question}}, {{.Context}},
+{{.Results}} (each with .Index, .ID, .Content, .Score, .File, .Location and
+.Metadata), {{.History}} and {{.Date}}. --prompt-name NAME loads NAME.tmpl or
+NAME.txt from the prompt library, ~/.edgerag/prompts or prompts.dir in the
+config; prompts.default names the template used when none is given.
+Templates are checked before the query runs.
+
+--history FILE carries on a conversation: the earlier turns are read from
+FILE, a JSON list of {"question", "answer"} objects, and the question and its
+answer are appended to it. The last --history-turns turns are sent to the
+LLM as earlier chat messages, or given to templates that use {{.History}}
+in their place; a missing FILE starts a new conversation.
+
+Answers are generated through Ollama's chat API, with --system-prompt as the
+system message. --temperature, --top-p, --num-ctx, --num-predict, --seed,
+--stop and --keep-alive are passed to Ollama; set them for every query in the
+config file under llm (temperature, top_p, num_ctx, num_predict, seed, stop,
+keep_alive, system_prompt). Use --temperature 0 or a --seed for reproducible
+answers, and raise --num-ctx when many or long chunks are retrieved. The
+LLM calls that transform the query or verify the answer only take
+--temperature, --top-p and --seed, so their replies aren't cut short.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runQuery,
 }
@@ -35,17 +97,120 @@ func init() {
 	rootCmd.AddCommand(queryCmd)
 	
 	queryCmd.Flags().IntP("top-k", "k", 3, "Number of most relevant chunks to retrieve")
-	queryCmd.Flags().Float32P("threshold", "t", 0.3, "Similarity threshold for retrieval")
-	queryCmd.Flags().StringP("prompt-template", "p", "", "Custom prompt template for LLM")
+	queryCmd.Flags().Float32P("threshold", "t", 0.3, "Minimum similarity score for retrieval (scale depends on the store metric)")
+	queryCmd.Flags().StringP("prompt-template", "p", "", "Custom prompt template for LLM, in Go text/template syntax")
+	queryCmd.Flags().String("prompt-template-file", "", "Read the prompt template from a file")
+	queryCmd.Flags().String("prompt-name", "", "Use a named prompt template from the prompt library (~/.edgerag/prompts)")
+	queryCmd.Flags().String("history", "", "Conversation file (JSON) to read earlier turns from and append this turn to")
+	queryCmd.Flags().Int("history-turns", 5, "Number of the latest --history turns given to the LLM")
 	queryCmd.Flags().BoolP("show-sources", "s", true, "Show source documents in the response")
+	queryCmd.Flags().Int("nprobe", 8, "Number of IVF-PQ lists to scan when an index has been trained")
+	queryCmd.Flags().Bool("exact", false, "Ignore any trained IVF-PQ index and search all vectors exactly")
+	queryCmd.Flags().StringArray("filter", nil, "Only retrieve chunks whose metadata matches key=value (repeatable)")
+	queryCmd.Flags().Bool("hierarchical", false, "Match fine chunks and answer from their coarse chunks (requires an index built with --hierarchical)")
+	queryCmd.Flags().Bool("hybrid", false, "Also search the full-text index for the question's keywords and fuse the rankings (sqlite backend)")
+	queryCmd.Flags().Bool("rewrite", false, "Have the LLM rewrite the question as a search query before retrieval")
+	queryCmd.Flags().Bool("expand-keywords", false, "Have the LLM add related keywords and synonyms to the query before retrieval")
+	queryCmd.Flags().Bool("hyde", false, "Search with a hypothetical answer drafted by the LLM as well as the query (HyDE)")
+	queryCmd.Flags().Int("multi-query", 0, "Also search for this many LLM paraphrases of the question and fuse the rankings (reciprocal rank fusion)")
+	queryCmd.Flags().BoolP("verbose", "v", false, "Show the transformed queries used for retrieval")
+	queryCmd.Flags().String("expand", "none", "Expand matched chunks before answering: none, neighbors or parent")
+	queryCmd.Flags().Int("expand-window", 1, "Chunks on each side of a match added by --expand neighbors")
+	queryCmd.Flags().Int("expand-max-chars", rag.DefaultParentMaxChars, "Characters of its section --expand parent takes around each match")
+	queryCmd.Flags().Float32("min-score", 0, "Decline to answer when the best match scores below this (0 disables)")
+	queryCmd.Flags().Float32("min-margin", 0, "Decline to answer when the best match leads the others by less than this on average (0 disables)")
+	queryCmd.Flags().Bool("verify", false, "Check the answer against the context and withhold it if unsupported")
+	queryCmd.Flags().String("verify-judge", "llm", "How --verify checks the answer: llm or embedding")
+	queryCmd.Flags().Float64("min-supported", rag.DefaultMinSupported, "Fraction of answer sentences --verify requires to be supported")
+
+	viper.BindPFlag("grounding.min_score", queryCmd.Flags().Lookup("min-score"))
+	viper.BindPFlag("grounding.min_margin", queryCmd.Flags().Lookup("min-margin"))
+	viper.BindPFlag("grounding.verify", queryCmd.Flags().Lookup("verify"))
+	viper.BindPFlag("grounding.verify_judge", queryCmd.Flags().Lookup("verify-judge"))
+	viper.BindPFlag("grounding.min_supported", queryCmd.Flags().Lookup("min-supported"))
+
+	queryCmd.Flags().String("system-prompt", "", "System message instructing the LLM, sent ahead of the prompt")
+	queryCmd.Flags().Float64("temperature", 0, "LLM sampling temperature; 0 always picks the likeliest token (default: the model's)")
+	queryCmd.Flags().Float64("top-p", 0, "LLM nucleus sampling probability mass (default: the model's)")
+	queryCmd.Flags().Int("num-ctx", 0, "LLM context window in tokens, prompt included (default: the model's)")
+	queryCmd.Flags().Int("num-predict", 0, "Maximum number of tokens to generate, -1 for no limit (default: the model's)")
+	queryCmd.Flags().Int("seed", 0, "LLM random seed, for reproducible answers (default: random)")
+	queryCmd.Flags().StringArray("stop", nil, "Stop generating when the LLM produces this sequence (repeatable)")
+	queryCmd.Flags().String("keep-alive", "", "How long Ollama keeps the model loaded, e.g. 10m, or seconds with -1 for ever")
+
+	viper.BindPFlag("llm.system_prompt", queryCmd.Flags().Lookup("system-prompt"))
+	viper.BindPFlag("llm.temperature", queryCmd.Flags().Lookup("temperature"))
+	viper.BindPFlag("llm.top_p", queryCmd.Flags().Lookup("top-p"))
+	viper.BindPFlag("llm.num_ctx", queryCmd.Flags().Lookup("num-ctx"))
+	viper.BindPFlag("llm.num_predict", queryCmd.Flags().Lookup("num-predict"))
+	viper.BindPFlag("llm.seed", queryCmd.Flags().Lookup("seed"))
+	viper.BindPFlag("llm.stop", queryCmd.Flags().Lookup("stop"))
+	viper.BindPFlag("llm.keep_alive", queryCmd.Flags().Lookup("keep-alive"))
 }
 
 func runQuery(cmd *cobra.Command, args []string) error {
 	question := args[0]
 	topK, _ := cmd.Flags().GetInt("top-k")
 	threshold, _ := cmd.Flags().GetFloat32("threshold")
-	promptTemplate, _ := cmd.Flags().GetString("prompt-template")
+	promptInline, _ := cmd.Flags().GetString("prompt-template")
+	promptFile, _ := cmd.Flags().GetString("prompt-template-file")
+	promptName, _ := cmd.Flags().GetString("prompt-name")
+	historyPath, _ := cmd.Flags().GetString("history")
+	historyTurns, _ := cmd.Flags().GetInt("history-turns")
 	showSources, _ := cmd.Flags().GetBool("show-sources")
+	nprobe, _ := cmd.Flags().GetInt("nprobe")
+	exact, _ := cmd.Flags().GetBool("exact")
+	filterArgs, _ := cmd.Flags().GetStringArray("filter")
+	hierarchical, _ := cmd.Flags().GetBool("hierarchical")
+	hybrid, _ := cmd.Flags().GetBool("hybrid")
+	transforms := rag.QueryTransforms{}
+	transforms.Rewrite, _ = cmd.Flags().GetBool("rewrite")
+	transforms.Keywords, _ = cmd.Flags().GetBool("expand-keywords")
+	transforms.HyDE, _ = cmd.Flags().GetBool("hyde")
+	transforms.Paraphrases, _ = cmd.Flags().GetInt("multi-query")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	expandName, _ := cmd.Flags().GetString("expand")
+	expandWindow, _ := cmd.Flags().GetInt("expand-window")
+	expandMaxChars, _ := cmd.Flags().GetInt("expand-max-chars")
+
+	filter, err := parseFilter(filterArgs)
+	if err != nil {
+		return err
+	}
+	expansion, err := rag.ParseExpansionMode(expandName)
+	if err != nil {
+		return err
+	}
+	grounding, err := groundingPolicy()
+	if err != nil {
+		return err
+	}
+	promptTemplate, promptSource, err := loadPromptTemplate(promptInline, promptFile, promptName)
+	if err != nil {
+		return err
+	}
+	options, err := generateOptions()
+	if err != nil {
+		return err
+	}
+	if transforms.Paraphrases < 0 {
+		return fmt.Errorf("--multi-query must not be negative")
+	}
+	if expandWindow < 0 {
+		return fmt.Errorf("--expand-window must not be negative")
+	}
+	if expandMaxChars <= 0 {
+		return fmt.Errorf("--expand-max-chars must be positive")
+	}
+	if historyTurns < 0 {
+		return fmt.Errorf("--history-turns must not be negative")
+	}
+	var history []rag.Turn
+	if historyPath != "" {
+		if history, err = loadHistory(historyPath); err != nil {
+			return err
+		}
+	}
 
 	// Initialize services
 	model := viper.GetString("model")
@@ -56,52 +221,111 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	defer embeddingService.Close()
 
 	// Initialize persistent vector store
-	dataDir := filepath.Join(os.Getenv("HOME"), ".edgerag", "vectors")
-	vectorStore, err := vectorstore.NewPersistentStore(dataDir)
+	dataDir := vectorDataDir()
+	vectorStore, err := openVectorStore(dataDir, vectorstore.LockShared)
 	if err != nil {
 		return fmt.Errorf("failed to initialize vector store: %w", err)
 	}
+	defer vectorStore.Close()
 	
 	if vectorStore.Count() == 0 {
 		return fmt.Errorf("no documents indexed. Please run 'edgerag index' first")
 	}
 
+	// Use the approximate index when one has been trained
+	store, indexed, err := searchStore(vectorStore, dataDir, exact, nprobe)
+	if err != nil {
+		return err
+	}
+	if indexed {
+		fmt.Printf("⚡ Using IVF-PQ index (nprobe=%d)\n", nprobe)
+	}
+
 	ollamaURL := viper.GetString("ollama_url")
 	ollamaModel := viper.GetString("ollama_model")
 	llmClient, err := llm.NewOllamaClient(ollamaURL, ollamaModel)
 	if err != nil {
 		return fmt.Errorf("failed to initialize Ollama client: %w", err)
 	}
+	llmClient.SetOptions(options)
 
 	// Initialize RAG pipeline
-	ragPipeline := rag.NewPipeline(embeddingService, vectorStore, llmClient)
+	ragPipeline := rag.NewPipeline(embeddingService, store, llmClient)
+	ragPipeline.SetSystemPrompt(viper.GetString("llm.system_prompt"))
+
+	if len(filter) > 0 {
+		ragPipeline.SetFilter(filter)
+	}
+	if hierarchical {
+		ragPipeline.SetHierarchical(true)
+	}
+	if hybrid {
+		if err := checkHybrid(vectorStore); err != nil {
+			return err
+		}
+		ragPipeline.SetHybrid(true)
+	}
+	ragPipeline.SetQueryTransforms(transforms)
+	if verbose {
+		ragPipeline.SetTraceFunc(printQueryTrace)
+	}
+	if expansion != rag.ExpandNone {
+		ragPipeline.SetContextExpansion(expansion, expandWindow, expandMaxChars)
+	}
+	ragPipeline.SetGroundingPolicy(grounding)
+	ragPipeline.SetHistory(recentTurns(history, historyTurns))
 
 	// Set custom prompt template if provided
 	if promptTemplate != "" {
-		ragPipeline.SetPromptTemplate(promptTemplate)
+		if err := ragPipeline.SetPromptTemplate(promptTemplate); err != nil {
+			return fmt.Errorf("%s: %w", promptSource, err)
+		}
+		if verbose {
+			fmt.Printf("📝 Using prompt template %s\n", promptSource)
+		}
 	}
 
 	fmt.Printf("🔍 Searching for relevant information...\n")
 
 	// Perform RAG query
 	response, sources, err := ragPipeline.Query(question, topK, threshold)
-	if err != nil {
+	var insufficient *rag.InsufficientContextError
+	if errors.As(err, &insufficient) {
+		printInsufficientContext(insufficient)
+	} else if err != nil {
 		return fmt.Errorf("failed to process query: %w", err)
-	}
+	} else {
+		// Display results
+		fmt.Printf("\n📖 Answer:\n")
+		fmt.Println(strings.Repeat("-", 80))
+		fmt.Println(response)
+		fmt.Println(strings.Repeat("-", 80))
 
-	// Display results
-	fmt.Printf("\n📖 Answer:\n")
-	fmt.Println(strings.Repeat("-", 80))
-	fmt.Println(response)
-	fmt.Println(strings.Repeat("-", 80))
+		if historyPath != "" {
+			history = append(history, rag.Turn{Question: question, Answer: response})
+			if err := saveHistory(historyPath, history); err != nil {
+				return err
+			}
+		}
+	}
 
 	if showSources && len(sources) > 0 {
-		fmt.Printf("\n📚 Sources (%d found):\n", len(sources))
+		if insufficient != nil {
+			fmt.Printf("\n📚 Closest matches (%d found):\n", len(sources))
+		} else {
+			fmt.Printf("\n📚 Sources (%d found):\n", len(sources))
+		}
 		for i, source := range sources {
 			fmt.Printf("\n[%d] Similarity: %.3f\n", i+1, source.Score)
 			if source.Metadata["file"] != nil {
 				fmt.Printf("File: %s\n", source.Metadata["file"])
 			}
+			if location := document.PageLabel(source.Metadata); location != "" {
+				fmt.Printf("Location: %s\n", location)
+			}
+			if matched, ok := source.Metadata["matched_chunks"]; ok {
+				fmt.Printf("Matched: %v fine chunks\n", matched)
+			}
 			if source.Metadata["chunk_id"] != nil {
 				fmt.Printf("Chunk: %s\n", source.Metadata["chunk_id"])
 			}
@@ -112,6 +336,103 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// groundingPolicy builds the grounding policy from the grounding config,
+// which the query flags override
+func groundingPolicy() (rag.GroundingPolicy, error) {
+	judge, err := rag.ParseJudgeMode(viper.GetString("grounding.verify_judge"))
+	if err != nil {
+		return rag.GroundingPolicy{}, err
+	}
+	return rag.GroundingPolicy{
+		MinTopScore:  float32(viper.GetFloat64("grounding.min_score")),
+		MinMargin:    float32(viper.GetFloat64("grounding.min_margin")),
+		Verify:       viper.GetBool("grounding.verify"),
+		VerifyJudge:  judge,
+		MinSupported: viper.GetFloat64("grounding.min_supported"),
+	}, nil
+}
+
+// generateOptions builds the LLM generation options from the llm config,
+// which the query flags override. Options set in neither are left to the
+// model's defaults.
+func generateOptions() (llm.GenerateOptions, error) {
+	var options llm.GenerateOptions
+	if viper.IsSet("llm.temperature") {
+		temperature := viper.GetFloat64("llm.temperature")
+		if temperature < 0 {
+			return options, fmt.Errorf("temperature must not be negative")
+		}
+		options.Temperature = &temperature
+	}
+	if viper.IsSet("llm.top_p") {
+		topP := viper.GetFloat64("llm.top_p")
+		if topP <= 0 || topP > 1 {
+			return options, fmt.Errorf("top_p must be in (0, 1]")
+		}
+		options.TopP = &topP
+	}
+	if viper.IsSet("llm.num_ctx") {
+		numCtx := viper.GetInt("llm.num_ctx")
+		if numCtx <= 0 {
+			return options, fmt.Errorf("num_ctx must be positive")
+		}
+		options.NumCtx = &numCtx
+	}
+	if viper.IsSet("llm.num_predict") {
+		numPredict := viper.GetInt("llm.num_predict")
+		if numPredict == 0 || numPredict < -2 {
+			return options, fmt.Errorf("num_predict must be positive, -1 (no limit) or -2 (fill the context)")
+		}
+		options.NumPredict = &numPredict
+	}
+	if viper.IsSet("llm.seed") {
+		seed := viper.GetInt("llm.seed")
+		options.Seed = &seed
+	}
+	options.Stop = viper.GetStringSlice("llm.stop")
+	if keepAlive := viper.GetString("llm.keep_alive"); keepAlive != "" {
+		if _, err := strconv.ParseFloat(keepAlive, 64); err != nil {
+			if _, err := time.ParseDuration(keepAlive); err != nil {
+				return options, fmt.Errorf("invalid keep_alive %q: use a duration such as 10m, or a number of seconds", keepAlive)
+			}
+		}
+		options.KeepAlive = keepAlive
+	}
+	return options, nil
+}
+
+// printInsufficientContext explains why no answer was given, and which
+// claims of a withheld answer the context doesn't support
+func printInsufficientContext(insufficient *rag.InsufficientContextError) {
+	fmt.Printf("\n🤷 Not enough context to answer:\n")
+	fmt.Println(strings.Repeat("-", 80))
+	fmt.Println("The indexed documents don't contain enough relevant information to answer")
+	fmt.Println("this question reliably.")
+	fmt.Printf("\nReason: %s\n", insufficient.Error())
+	if insufficient.Judgement != nil {
+		fmt.Printf("\nUnsupported claims in the withheld answer:\n")
+		for _, sentence := range insufficient.Judgement.Unsupported() {
+			fmt.Printf("  ✗ %s\n", sentence)
+		}
+	}
+	fmt.Println(strings.Repeat("-", 80))
+}
+
+// printQueryTrace shows a query the pipeline derived from the question
+func printQueryTrace(stage, text string) {
+	labels := map[string]string{
+		"rewrite":    "✏️  Rewritten query",
+		"keywords":   "🔑 Expanded query",
+		"hyde":       "💭 Hypothetical document",
+		"paraphrase": "🔀 Paraphrase",
+	}
+	label, ok := labels[stage]
+	if !ok {
+		label = "🔄 " + stage
+	}
+	fmt.Printf("%s: %s\n", label, text)
+}
+
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -37,10 +38,18 @@ func init() {
 	rootCmd.PersistentFlags().String("model", "paraphrase-MiniLM-L3-v2", "sentence-transformer model to use for embeddings")
 	rootCmd.PersistentFlags().String("ollama-model", "llama3.2", "Ollama model to use for LLM inference")
 	rootCmd.PersistentFlags().String("ollama-url", "http://localhost:11434", "Ollama server URL")
+	rootCmd.PersistentFlags().String("store-backend", "json", "vector store backend: json (one file per chunk) or sqlite (single database file)")
+	rootCmd.PersistentFlags().String("metric", "", "vector similarity metric: cosine, dot or euclidean (default: the store's recorded metric, or cosine for a new store)")
+	rootCmd.PersistentFlags().Bool("normalize", false, "normalize embeddings to unit length when they are stored (recorded with the store)")
+	rootCmd.PersistentFlags().Duration("lock-timeout", 0, "how long to wait for a vector store locked by another edgerag process (negative waits forever)")
 
 	viper.BindPFlag("model", rootCmd.PersistentFlags().Lookup("model"))
 	viper.BindPFlag("ollama_model", rootCmd.PersistentFlags().Lookup("ollama-model"))
 	viper.BindPFlag("ollama_url", rootCmd.PersistentFlags().Lookup("ollama-url"))
+	viper.BindPFlag("store.backend", rootCmd.PersistentFlags().Lookup("store-backend"))
+	viper.BindPFlag("store.metric", rootCmd.PersistentFlags().Lookup("metric"))
+	viper.BindPFlag("store.normalize", rootCmd.PersistentFlags().Lookup("normalize"))
+	viper.BindPFlag("store.lock_timeout", rootCmd.PersistentFlags().Lookup("lock-timeout"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -61,4 +70,9 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
-} 
\ No newline at end of file
+} 
+
+// vectorDataDir returns the directory where the vector store persists its data
+func vectorDataDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".edgerag", "vectors")
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"edgerag/internal/vectorstore"
+)
+
+// storeOptions builds vector store options from the store.* config keys.
+// Without store.metric the metric recorded with the store is used.
+func storeOptions() (vectorstore.Options, error) {
+	var metric vectorstore.Metric
+	if name := viper.GetString("store.metric"); name != "" {
+		var err error
+		if metric, err = vectorstore.ParseMetric(name); err != nil {
+			return vectorstore.Options{}, err
+		}
+	}
+
+	return vectorstore.Options{
+		Metric:      metric,
+		Normalize:   viper.GetBool("store.normalize"),
+		LockTimeout: viper.GetDuration("store.lock_timeout"),
+	}, nil
+}
+
+// openVectorStore opens the configured store.backend in dataDir using the
+// configured store options. Readers should pass LockShared and writers
+// LockExclusive; the caller must Close the store to release the lock.
+func openVectorStore(dataDir string, lock vectorstore.LockMode) (vectorstore.VectorStore, error) {
+	options, err := storeOptions()
+	if err != nil {
+		return nil, fmt.Errorf("invalid store configuration: %w", err)
+	}
+	options.Lock = lock
+
+	backend, err := vectorstore.ParseBackend(viper.GetString("store.backend"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid store configuration: %w", err)
+	}
+
+	store, err := vectorstore.Open(backend, dataDir, options)
+	if errors.Is(err, vectorstore.ErrStoreLocked) {
+		return nil, fmt.Errorf("%w\nWait for the other edgerag process to finish or retry with --lock-timeout", err)
+	}
+	if errors.Is(err, vectorstore.ErrSettingsMismatch) {
+		return nil, fmt.Errorf("%w\nDrop --metric/--normalize (and store.metric/store.normalize from the config) to use the store's own, or remove the data directory and re-index", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if locked, ok := store.(interface{ StaleLock() *vectorstore.LockOwner }); ok && locked.StaleLock() != nil {
+		owner := locked.StaleLock()
+		fmt.Fprintf(os.Stderr, "⚠️  Recovered a stale store lock left by %s\n", owner)
+		fmt.Fprintf(os.Stderr, "   That process exited without releasing it; re-run 'edgerag index' on any files it was indexing\n")
+	}
+
+	return store, nil
+}
+
+// parseFilter converts key=value arguments into a metadata filter. Values that
+// look like numbers or booleans are typed accordingly so they match metadata
+// stored as JSON numbers or booleans.
+func parseFilter(args []string) (vectorstore.Filter, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	filter := make(vectorstore.Filter, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected key=value", arg)
+		}
+
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			filter[key] = i
+		} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+			filter[key] = f
+		} else if b, err := strconv.ParseBool(value); err == nil {
+			filter[key] = b
+		} else {
+			filter[key] = value
+		}
+	}
+	return filter, nil
+}
+
+// checkHybrid returns an error explaining how to get a full-text index when
+// hybrid retrieval is asked of a store without one
+func checkHybrid(store vectorstore.VectorStore) error {
+	if _, ok := store.(vectorstore.LexicalSearcher); !ok {
+		return fmt.Errorf("--hybrid needs a full-text index: use --store-backend sqlite (or store.backend: sqlite) and index the documents into it")
+	}
+	return nil
+}
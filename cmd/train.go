@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"edgerag/internal/vectorstore"
+)
+
+var trainCmd = &cobra.Command{
+	Use:   "train",
+	Short: "Train an IVF-PQ index over the stored vectors",
+	Long: `Train an IVF-PQ (inverted file with product quantization) index for fast
+approximate search over very large stores.
+
+Training runs k-means on a sample of the stored vectors to build a coarse
+quantizer, then learns product-quantizer codebooks for the residuals. Every
+stored vector is then encoded into compact codes and the index is saved in the
+data directory. Once an index exists, 'edgerag query' uses it automatically
+and 'edgerag index' keeps it up to date.
+
+Re-run this command after large changes to the corpus so the codebooks reflect
+the current data.
+
+Examples:
+  edgerag train
+  edgerag train --nlist 1024 --subquantizers 32 --sample 100000`,
+	Args: cobra.NoArgs,
+	RunE: runTrain,
+}
+
+func init() {
+	rootCmd.AddCommand(trainCmd)
+
+	defaults := vectorstore.DefaultIVFPQConfig()
+	trainCmd.Flags().Int("nlist", defaults.NList, "Number of coarse clusters (inverted lists)")
+	trainCmd.Flags().IntP("subquantizers", "m", defaults.M, "Number of PQ sub-quantizers (must divide the embedding dimension)")
+	trainCmd.Flags().Int("nbits", defaults.NBits, "Bits per sub-quantizer code (1-8)")
+	trainCmd.Flags().Int("sample", 0, "Number of vectors sampled for training (default 64 x max(nlist, 2^nbits))")
+	trainCmd.Flags().Int("iterations", defaults.Iterations, "Maximum k-means iterations")
+	trainCmd.Flags().Int64("seed", defaults.Seed, "Random seed for sampling and k-means initialization")
+}
+
+func runTrain(cmd *cobra.Command, args []string) error {
+	config := vectorstore.DefaultIVFPQConfig()
+	config.NList, _ = cmd.Flags().GetInt("nlist")
+	config.M, _ = cmd.Flags().GetInt("subquantizers")
+	config.NBits, _ = cmd.Flags().GetInt("nbits")
+	config.Iterations, _ = cmd.Flags().GetInt("iterations")
+	config.Seed, _ = cmd.Flags().GetInt64("seed")
+	sampleSize, _ := cmd.Flags().GetInt("sample")
+
+	dataDir := vectorDataDir()
+	fmt.Printf("💾 Loading vector store (data dir: %s)...\n", dataDir)
+	vectorStore, err := openVectorStore(dataDir, vectorstore.LockExclusive)
+	if err != nil {
+		return fmt.Errorf("failed to initialize vector store: %w", err)
+	}
+	defer vectorStore.Close()
+
+	ids := vectorStore.List()
+	if len(ids) == 0 {
+		return fmt.Errorf("no documents indexed. Please run 'edgerag index' first")
+	}
+	fmt.Printf("✅ Loaded %d vectors\n", len(ids))
+
+	if sampleSize <= 0 {
+		sampleSize = 64 * max(config.NList, 1<<config.NBits)
+	}
+	if sampleSize > len(ids) {
+		sampleSize = len(ids)
+	}
+
+	// Sample training vectors reproducibly
+	rng := rand.New(rand.NewSource(config.Seed))
+	perm := rng.Perm(len(ids))
+	samples := make([][]float32, 0, sampleSize)
+	for _, i := range perm[:sampleSize] {
+		vector, err := vectorStore.Get(ids[i])
+		if err != nil {
+			continue
+		}
+		samples = append(samples, vector.Embedding)
+	}
+
+	fmt.Printf("🧮 Training IVF-PQ index (nlist=%d, m=%d, nbits=%d) on %d sampled vectors...\n",
+		config.NList, config.M, config.NBits, len(samples))
+	index, err := vectorstore.TrainIVFPQ(samples, config)
+	if err != nil {
+		return fmt.Errorf("failed to train index: %w", err)
+	}
+	fmt.Printf("✅ Training complete\n")
+
+	fmt.Printf("⏳ Encoding %d vectors...\n", len(ids))
+	for _, id := range ids {
+		vector, err := vectorStore.Get(id)
+		if err != nil {
+			continue
+		}
+		if err := index.Add(id, vector.Embedding); err != nil {
+			fmt.Printf("  ❌ Failed to encode %s: %v\n", id, err)
+		}
+	}
+
+	indexPath := filepath.Join(dataDir, vectorstore.IVFPQIndexFile)
+	if err := index.Save(indexPath); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+
+	stats := index.GetStats()
+	fmt.Printf("\nIndex saved to %s\n", indexPath)
+	fmt.Printf("  Encoded vectors: %d (%d bytes each)\n", stats["encoded_vectors"], stats["bytes_per_code"])
+	fmt.Printf("  Largest list: %d, empty lists: %d\n", stats["largest_list"], stats["empty_lists"])
+
+	return nil
+}
+
+// loadIVFPQIndex loads the IVF-PQ index from the data directory, returning
+// nil without error when no index has been trained
+func loadIVFPQIndex(dataDir string) (*vectorstore.IVFPQIndex, error) {
+	indexPath := filepath.Join(dataDir, vectorstore.IVFPQIndexFile)
+	index, err := vectorstore.LoadIVFPQIndex(indexPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return index, err
+}
+
+// searchStore wraps a store in the IVF-PQ index trained in dataDir, probing
+// nprobe lists per query, unless exact is set or no index has been trained.
+// It reports whether the index is used.
+func searchStore(store vectorstore.VectorStore, dataDir string, exact bool, nprobe int) (vectorstore.VectorStore, bool, error) {
+	if exact {
+		return store, false, nil
+	}
+	index, err := loadIVFPQIndex(dataDir)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load IVF-PQ index: %w", err)
+	}
+	if index == nil {
+		return store, false, nil
+	}
+	return vectorstore.NewIndexedStore(store, index, nprobe), true, nil
+}
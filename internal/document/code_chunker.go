@@ -0,0 +1,87 @@
+package document
+
+import (
+	"fmt"
+	"strings"
+)
+
+// chunkSourceCode splits a source file on its declarations when a structural
+// chunker exists for its language. ok is false when the language has none.
+// Source that fails to parse is chunked by lines.
+func chunkSourceCode(doc *Document, maxChunkSize int, overlap int, size SizeFunc) (chunks []*Chunk, ok bool) {
+	var err error
+	switch GetFileType(fileName(doc)) {
+	case "go":
+		chunks, err = chunkGoSource(doc, maxChunkSize, size)
+	case "python":
+		chunks, err = chunkPythonSource(doc, maxChunkSize, size)
+	case "javascript", "typescript":
+		chunks, err = chunkJSSource(doc, maxChunkSize, size)
+	default:
+		return nil, false
+	}
+
+	if err != nil {
+		// Convert the size budget into lines using the file's average line
+		lineCount := strings.Count(doc.Content, "\n") + 1
+		perLine := size(doc.Content) / lineCount
+		if perLine < 1 {
+			perLine = 1
+		}
+		maxLines := maxChunkSize / perLine
+		if maxLines < 10 {
+			maxLines = 10
+		}
+		return ChunkByLines(doc, maxLines, overlap/perLine), true
+	}
+	return chunks, true
+}
+
+// fileName returns the file a document was loaded from, if known
+func fileName(doc *Document) string {
+	if name, ok := doc.Metadata["filename"].(string); ok {
+		return name
+	}
+	if ext, ok := doc.Metadata["extension"].(string); ok {
+		return "file" + ext
+	}
+	return ""
+}
+
+// codeSymbol describes one declaration-level piece of a source file
+type codeSymbol struct {
+	kind      string // func, method, class, type, const, var, package...
+	name      string
+	receiver  string // enclosing type for methods
+	lineStart int    // 1-based, inclusive
+	lineEnd   int
+	part      int // 1-based part number when a symbol is split, else 0
+	parts     int
+}
+
+// newCodeChunk creates a chunk for a piece of source code
+func newCodeChunk(doc *Document, chunkIndex int, content string, symbol codeSymbol) *Chunk {
+	chunkMetadata := newChunkMetadata(doc)
+	chunkMetadata["chunk_index"] = chunkIndex
+	chunkMetadata["parent_id"] = doc.ID
+	chunkMetadata["chunk_type"] = "code"
+	chunkMetadata["line_start"] = symbol.lineStart
+	chunkMetadata["line_end"] = symbol.lineEnd
+	chunkMetadata["symbol_kind"] = symbol.kind
+	if symbol.name != "" {
+		chunkMetadata["symbol"] = symbol.name
+	}
+	if symbol.receiver != "" {
+		chunkMetadata["receiver"] = symbol.receiver
+	}
+	if symbol.parts > 1 {
+		chunkMetadata["part"] = symbol.part
+		chunkMetadata["parts"] = symbol.parts
+	}
+
+	return &Chunk{
+		ID:       fmt.Sprintf("%s_code_%d", doc.ID, chunkIndex),
+		Content:  strings.TrimRight(content, " \t\n"),
+		Metadata: chunkMetadata,
+	}
+}
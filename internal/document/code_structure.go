@@ -0,0 +1,252 @@
+package document
+
+import (
+	"strings"
+)
+
+// This file holds the language-neutral half of the line-based structural
+// chunkers (Python, JavaScript/TypeScript). A language lexes its source into
+// per-line facts; declarations are then found at line granularity, packed
+// into chunks and, when too large, split at statement boundaries.
+
+// lineInfo describes one physical source line
+type lineInfo struct {
+	start bool // begins a statement: not inside a string, comment, bracket or continuation
+	code  bool // has something besides whitespace and comments
+	level int  // indentation width (Python) or brace depth (JavaScript) at line start
+}
+
+// codeLanguage supplies the language-specific rules
+type codeLanguage struct {
+	// classify names the declaration a statement's first line starts, or
+	// returns an empty kind for other statements. member is set for
+	// statements directly inside a class body.
+	classify func(line string, member bool) (kind, name string)
+	// attaches reports lines, such as decorators, that belong to the
+	// statement after them
+	attaches func(line string) bool
+	// continues reports lines that carry on the previous statement even
+	// though they begin at its level, like "else:" or a closing brace
+	continues func(line string) bool
+	// comment is the line comment marker used to note elided code
+	comment string
+}
+
+// codeUnit is a range of lines holding one statement or declaration
+type codeUnit struct {
+	start, end int // line indices, end exclusive
+	header     int // line of the declaration itself, after comments and decorators
+	kind       string
+	name       string
+}
+
+// structuralChunker splits one document using a codeLanguage
+type structuralChunker struct {
+	doc      *Document
+	lang     codeLanguage
+	lines    []string
+	info     []lineInfo
+	maxChunk int
+	measure  SizeFunc
+	chunks   []*Chunk
+}
+
+// chunkStructured chunks lines using the declaration structure in info
+func chunkStructured(doc *Document, lang codeLanguage, lines []string, info []lineInfo, maxChunkSize int, size SizeFunc) []*Chunk {
+	c := &structuralChunker{
+		doc:      doc,
+		lang:     lang,
+		lines:    lines,
+		info:     info,
+		maxChunk: maxChunkSize,
+		measure:  size,
+	}
+	c.process(c.units(0, len(lines), 0, false), "", "module")
+	return c.chunks
+}
+
+// units splits lines [from, to) into statements beginning at level
+func (c *structuralChunker) units(from, to, level int, member bool) []codeUnit {
+	var bounds []int
+	for i := from; i < to; i++ {
+		if c.info[i].start && c.info[i].code && c.info[i].level == level &&
+			!c.lang.continues(strings.TrimSpace(c.lines[i])) {
+			bounds = append(bounds, i)
+		}
+	}
+	if len(bounds) == 0 {
+		return []codeUnit{{start: from, end: to, header: from}}
+	}
+
+	var units []codeUnit
+	for k := 0; k < len(bounds); k++ {
+		start := bounds[k]
+		// Decorators join the statement they decorate
+		for k+1 < len(bounds) && c.lang.attaches(strings.TrimSpace(c.lines[bounds[k]])) {
+			k++
+		}
+		end := to
+		if k+1 < len(bounds) {
+			end = bounds[k+1]
+		}
+		units = append(units, codeUnit{start: start, end: end, header: bounds[k]})
+	}
+	units[0].start = from
+
+	// Comments directly above a statement belong to it rather than to the
+	// statement before
+	for k := 1; k < len(units); k++ {
+		s := units[k].start
+		for s > units[k-1].header+1 && c.isComment(s-1) {
+			s--
+		}
+		units[k].start = s
+		units[k-1].end = s
+	}
+
+	for k := range units {
+		units[k].kind, units[k].name = c.lang.classify(strings.TrimSpace(c.lines[units[k].header]), member)
+	}
+	return units
+}
+
+func (c *structuralChunker) isComment(i int) bool {
+	return !c.info[i].code && strings.TrimSpace(c.lines[i]) != ""
+}
+
+func (c *structuralChunker) size(from, to int) int {
+	if from >= to {
+		return 0
+	}
+	return c.measure(strings.Join(c.lines[from:to], "\n"))
+}
+
+// process emits chunks for units; plain statements between declarations are
+// merged under plainKind while they fit
+func (c *structuralChunker) process(units []codeUnit, receiver, plainKind string) {
+	var merged []codeUnit
+	for _, u := range units {
+		if u.kind == "" {
+			u.kind = plainKind
+			if n := len(merged); n > 0 && merged[n-1].kind == plainKind &&
+				c.size(merged[n-1].start, u.end) <= c.maxChunk {
+				merged[n-1].end = u.end
+				continue
+			}
+		}
+		merged = append(merged, u)
+	}
+
+	for _, u := range merged {
+		symbol := codeSymbol{kind: u.kind, name: u.name, receiver: receiver}
+		if u.kind == plainKind {
+			symbol.name = ""
+		} else if receiver != "" {
+			symbol.name = receiver + "." + u.name
+			if u.kind == "function" {
+				symbol.kind = "method"
+			}
+		}
+
+		if c.size(u.start, u.end) <= c.maxChunk {
+			c.emit(u.start, u.end, "", symbol, 0, 0)
+			continue
+		}
+
+		bodyLevel, ok := c.bodyLevel(u)
+		if !ok {
+			c.emit(u.start, u.end, "", symbol, 0, 0)
+			continue
+		}
+
+		if u.kind == "class" {
+			members := c.units(u.header+1, u.end, bodyLevel, true)
+			// The class header keeps its doc comment and everything before
+			// the first member
+			headerEnd := members[0].start
+			if members[0].kind == "" {
+				headerEnd = members[0].end
+				members = members[1:]
+			}
+			c.emit(u.start, headerEnd, "", symbol, 0, 0)
+			if len(members) > 0 {
+				c.process(members, u.name, "fields")
+			}
+			continue
+		}
+
+		c.split(u, bodyLevel, symbol)
+	}
+}
+
+// bodyLevel finds the level of the statements inside a declaration
+func (c *structuralChunker) bodyLevel(u codeUnit) (int, bool) {
+	headerLevel := c.info[u.header].level
+	for i := u.header + 1; i < u.end; i++ {
+		if c.info[i].start && c.info[i].code && c.info[i].level > headerLevel {
+			return c.info[i].level, true
+		}
+	}
+	return 0, false
+}
+
+// split packs an oversized unit's body statements into parts of at most
+// maxChunk characters. Later parts repeat the declaration line so each can
+// be read alone.
+func (c *structuralChunker) split(u codeUnit, bodyLevel int, symbol codeSymbol) {
+	var bounds []int
+	for _, inner := range c.units(u.header+1, u.end, bodyLevel, false) {
+		if inner.start > u.header+1 {
+			bounds = append(bounds, inner.start)
+		}
+	}
+
+	// Group statements while they fit; a single larger statement gets a part
+	// of its own
+	partStarts := []int{u.start}
+	for k, b := range bounds {
+		next := u.end
+		if k+1 < len(bounds) {
+			next = bounds[k+1]
+		}
+		if c.size(partStarts[len(partStarts)-1], next) > c.maxChunk {
+			partStarts = append(partStarts, b)
+		}
+	}
+
+	indent := leadingWhitespace(c.lines[u.header])
+	for i, from := range partStarts {
+		to := u.end
+		if i+1 < len(partStarts) {
+			to = partStarts[i+1]
+		}
+		prefix := ""
+		if i > 0 {
+			prefix = c.lines[u.header] + "\n" + indent + "    " + c.lang.comment + " ...\n"
+		}
+		c.emit(from, to, prefix, symbol, i+1, len(partStarts))
+	}
+}
+
+func (c *structuralChunker) emit(from, to int, prefix string, symbol codeSymbol, part, parts int) {
+	// Leave trailing blank lines out of the chunk and its line range
+	for to > from+1 && strings.TrimSpace(c.lines[to-1]) == "" {
+		to--
+	}
+	for from < to-1 && strings.TrimSpace(c.lines[from]) == "" {
+		from++
+	}
+	content := strings.Join(c.lines[from:to], "\n")
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	symbol.lineStart = from + 1
+	symbol.lineEnd = to
+	symbol.part, symbol.parts = part, parts
+	c.chunks = append(c.chunks, newCodeChunk(c.doc, len(c.chunks), prefix+content, symbol))
+}
+
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
@@ -0,0 +1,263 @@
+package document
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// ChunkGoSource splits Go source into one chunk per top-level declaration:
+// the package clause with its imports, and each func, method, type and
+// const/var block together with its doc comment. Comments standing between
+// declarations, such as license headers and section comments, go with the
+// declaration after them, or into a chunk of their own at the end of the
+// file. Functions longer than maxChunkSize are split between statements,
+// and each part repeats the function signature. Chunks record the package,
+// symbol, receiver and line range.
+func ChunkGoSource(doc *Document, maxChunkSize int) ([]*Chunk, error) {
+	return chunkGoSource(doc, maxChunkSize, CountChars)
+}
+
+func chunkGoSource(doc *Document, maxChunkSize int, size SizeFunc) ([]*Chunk, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, fileName(doc), doc.Content, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go source: %w", err)
+	}
+
+	src := doc.Content
+	tf := fset.File(file.Pos())
+	offset := func(p token.Pos) int { return tf.Offset(p) }
+	line := func(p token.Pos) int { return tf.Line(p) }
+
+	var chunks []*Chunk
+	emit := func(content string, symbol codeSymbol) {
+		chunk := newCodeChunk(doc, len(chunks), content, symbol)
+		chunk.Metadata["package"] = file.Name.Name
+		chunks = append(chunks, chunk)
+	}
+
+	// leadingComment returns where the first comment between from and to
+	// starts, or to if there is none
+	leadingComment := func(from, to token.Pos) token.Pos {
+		for _, group := range file.Comments {
+			if group.Pos() >= from && group.End() <= to {
+				return group.Pos()
+			}
+		}
+		return to
+	}
+	// withLineComment extends the end of a declaration over a comment
+	// following it on the same line
+	withLineComment := func(end token.Pos) token.Pos {
+		for _, group := range file.Comments {
+			if group.Pos() >= end && line(group.Pos()) == line(end) {
+				return group.End()
+			}
+		}
+		return end
+	}
+
+	// Package clause, package doc comment and imports
+	headerStart := file.Package
+	if file.Doc != nil {
+		headerStart = file.Doc.Pos()
+	}
+	headerStart = leadingComment(tf.Pos(0), headerStart)
+	headerEnd := file.Name.End()
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			headerEnd = gen.End()
+		}
+	}
+	headerEnd = withLineComment(headerEnd)
+	emit(src[offset(headerStart):offset(headerEnd)], codeSymbol{
+		kind:      "package",
+		name:      file.Name.Name,
+		lineStart: line(headerStart),
+		lineEnd:   line(headerEnd),
+	})
+
+	prevEnd := headerEnd
+	for _, decl := range file.Decls {
+		start, end := decl.Pos(), withLineComment(decl.End())
+		from := prevEnd
+		if end > prevEnd {
+			prevEnd = end
+		}
+		var symbol codeSymbol
+
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			start = leadingComment(from, start)
+			symbol = codeSymbol{kind: "func", name: d.Name.Name}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				symbol.kind = "method"
+				symbol.receiver = receiverTypeName(d.Recv.List[0].Type)
+				symbol.name = symbol.receiver + "." + d.Name.Name
+			}
+			symbol.lineStart, symbol.lineEnd = line(start), line(end)
+
+			content := src[offset(start):offset(end)]
+			if size(content) > maxChunkSize && d.Body != nil && len(d.Body.List) > 1 {
+				for _, part := range splitGoFunc(src, d, start, maxChunkSize, size, offset, line) {
+					part.symbol.kind, part.symbol.name, part.symbol.receiver = symbol.kind, symbol.name, symbol.receiver
+					emit(part.content, part.symbol)
+				}
+				continue
+			}
+			emit(content, symbol)
+
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			start = leadingComment(from, start)
+			symbol = codeSymbol{
+				kind:      d.Tok.String(),
+				name:      strings.Join(genDeclNames(d), ", "),
+				lineStart: line(start),
+				lineEnd:   line(end),
+			}
+			emit(src[offset(start):offset(end)], symbol)
+		}
+	}
+
+	// Comments after the last declaration
+	if n := len(file.Comments); n > 0 && file.Comments[n-1].Pos() >= prevEnd {
+		start := leadingComment(prevEnd, file.Comments[n-1].End())
+		end := file.Comments[n-1].End()
+		emit(src[offset(start):offset(end)], codeSymbol{
+			kind:      "comment",
+			lineStart: line(start),
+			lineEnd:   line(end),
+		})
+	}
+
+	return chunks, nil
+}
+
+type goPart struct {
+	content string
+	symbol  codeSymbol
+}
+
+// splitGoFunc groups a function body's statements into parts of at most
+// maxChunkSize (a single larger statement gets a part of its own).
+// The first part carries the doc comment; every part starts with the
+// signature so it can be understood alone.
+func splitGoFunc(src string, fn *ast.FuncDecl, start token.Pos, maxChunkSize int, size SizeFunc,
+	offset func(token.Pos) int, line func(token.Pos) int) []goPart {
+	signature := src[offset(fn.Pos()) : offset(fn.Body.Lbrace)+1]
+	docText := src[offset(start):offset(fn.Pos())]
+
+	var groups [][]ast.Stmt
+	var current []ast.Stmt
+	total := size(docText) + size(signature)
+	for _, stmt := range fn.Body.List {
+		stmtSize := size(src[offset(stmt.Pos()):offset(stmt.End())])
+		if len(current) > 0 && total+stmtSize > maxChunkSize {
+			groups = append(groups, current)
+			current = nil
+			total = size(signature)
+		}
+		current = append(current, stmt)
+		total += stmtSize + 1
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+
+	parts := make([]goPart, len(groups))
+	for i, group := range groups {
+		first, last := group[0], group[len(group)-1]
+
+		// Keep comments between statements with the statement they precede
+		from := offset(first.Pos())
+		if i > 0 {
+			prev := groups[i-1]
+			from = offset(prev[len(prev)-1].End())
+		}
+		to := offset(last.End())
+		lineStart := line(first.Pos())
+
+		var b strings.Builder
+		if i == 0 {
+			b.WriteString(docText)
+			lineStart = line(start)
+		}
+		b.WriteString(signature)
+		if i > 0 {
+			b.WriteString("\n\t// ...")
+		}
+		body := src[from:to]
+		if i > 0 {
+			body = strings.TrimLeft(body, " \t\r\n")
+			b.WriteString("\n\t")
+		} else {
+			b.WriteString(src[offset(fn.Body.Lbrace)+1 : from])
+		}
+		b.WriteString(body)
+		if i < len(groups)-1 {
+			b.WriteString("\n\t// ...")
+		} else {
+			to = offset(fn.Body.Rbrace) + 1
+			b.WriteString(src[offset(last.End()):to])
+		}
+
+		lineEnd := line(last.End())
+		if i == len(groups)-1 {
+			lineEnd = line(fn.Body.Rbrace)
+		}
+		parts[i] = goPart{
+			content: b.String(),
+			symbol: codeSymbol{
+				lineStart: lineStart,
+				lineEnd:   lineEnd,
+				part:      i + 1,
+				parts:     len(groups),
+			},
+		}
+	}
+	return parts
+}
+
+// receiverTypeName returns the type a method is declared on, without
+// pointer or type parameters
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// genDeclNames lists the names declared by a type, const or var declaration
+func genDeclNames(d *ast.GenDecl) []string {
+	var names []string
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, s.Name.Name)
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				names = append(names, name.Name)
+			}
+		}
+	}
+	return names
+}
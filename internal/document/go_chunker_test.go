@@ -0,0 +1,65 @@
+package document
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkGoSourceKeepsComments(t *testing.T) {
+	src := `// Copyright 2024 The Authors. Licensed under Apache 2.0.
+
+// Package sample is a test.
+package sample
+
+import "fmt"
+
+// ---- Helpers ----
+
+// Greet says hello
+func Greet() { fmt.Println("hi") }
+
+const answer = 42 // the answer
+
+// ---- Types ----
+
+type T struct{}
+
+// TODO: remove after v2
+`
+	doc := &Document{ID: "sample", Content: src, Metadata: map[string]interface{}{"file": "sample.go"}}
+	chunks, err := ChunkGoSource(doc, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		kind, contains string
+	}{
+		{"package", "Copyright 2024"},
+		{"func", "---- Helpers ----"},
+		{"const", "// the answer"},
+		{"type", "---- Types ----"},
+		{"comment", "TODO: remove after v2"},
+	}
+	if len(chunks) != len(tests) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(tests))
+	}
+	for i, tt := range tests {
+		chunk := chunks[i]
+		if kind := chunk.Metadata["symbol_kind"]; kind != tt.kind {
+			t.Errorf("chunk %d is a %v, want %s", i, kind, tt.kind)
+		}
+		if !strings.Contains(chunk.Content, tt.contains) {
+			t.Errorf("%s chunk %q doesn't contain %q", tt.kind, chunk.Content, tt.contains)
+		}
+	}
+	var joined strings.Builder
+	for _, chunk := range chunks {
+		joined.WriteString(chunk.Content)
+	}
+	for _, field := range strings.Fields(src) {
+		if !strings.Contains(joined.String(), field) {
+			t.Errorf("chunks lost %q", field)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package document
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Chunk levels of a hierarchical index, stored as chunk_level metadata
+const (
+	// LevelCoarse chunks are large, such as whole sections, and are what the
+	// LLM reads
+	LevelCoarse = "coarse"
+	// LevelFine chunks are sentence-sized pieces of a coarse chunk, embedded
+	// for precise matching
+	LevelFine = "fine"
+)
+
+// ChunkHierarchy indexes a document at two granularities. Each coarse chunk
+// is split into fine chunks of whole sentences packed up to fineSize, or
+// pieces of a sentence larger than that. Coarse chunks list their fine
+// chunks' IDs as child_ids, fine chunks name their coarse chunk as
+// parent_chunk_id, and every chunk records its chunk_level. The coarse
+// chunks are returned first, followed by the fine chunks, which are indexed
+// in document order.
+func ChunkHierarchy(doc *Document, coarse []*Chunk, fineSize int, size SizeFunc) []*Chunk {
+	if size == nil {
+		size = CountChars
+	}
+
+	unit := doc.PageUnit
+	if unit == "" {
+		unit = "page"
+	}
+
+	var fine []*Chunk
+	for _, parent := range coarse {
+		parentStart, _ := parent.Metadata["chunk_start"].(int)
+
+		var children []string
+		offset := 0
+		for _, paragraph := range strings.Split(parent.Content, "\n\n") {
+			paragraph = strings.TrimSpace(paragraph)
+			if paragraph == "" {
+				continue
+			}
+			// A heading alone matches little; it is in the coarse chunk
+			// and its heading path already
+			if _, _, ok := parseMarkdownHeading(paragraph); ok && !strings.Contains(paragraph, "\n") {
+				continue
+			}
+			for _, piece := range packWithin(fineSentences(paragraph, fineSize, size), " ", fineSize, size) {
+				offset = locateChunk(parent.Content, piece, offset)
+				start := parentStart + offset
+				if len(doc.Pages) > 0 {
+					start = locateChunk(doc.Content, piece, start)
+				}
+
+				chunkMetadata := make(map[string]interface{})
+				for k, v := range parent.Metadata {
+					chunkMetadata[k] = v
+				}
+				delete(chunkMetadata, "child_ids")
+				chunkMetadata["chunk_index"] = len(fine)
+				chunkMetadata["chunk_start"] = start
+				chunkMetadata["chunk_end"] = start + len(piece)
+				chunkMetadata["chunk_level"] = LevelFine
+				chunkMetadata["parent_chunk_id"] = parent.ID
+				if len(doc.Pages) > 0 {
+					delete(chunkMetadata, unit)
+					delete(chunkMetadata, unit+"_end")
+					annotatePages(doc, chunkMetadata, start, start+len(piece))
+				}
+
+				chunk := &Chunk{
+					ID:       fmt.Sprintf("%s_fine_%d", doc.ID, len(fine)),
+					Content:  piece,
+					Metadata: chunkMetadata,
+				}
+				fine = append(fine, chunk)
+				children = append(children, chunk.ID)
+			}
+		}
+
+		parent.Metadata["chunk_level"] = LevelCoarse
+		parent.Metadata["child_ids"] = children
+	}
+
+	return append(append([]*Chunk{}, coarse...), fine...)
+}
+
+// fineSentences splits a paragraph into sentences, breaking any larger than
+// fineSize at words
+func fineSentences(paragraph string, fineSize int, size SizeFunc) []string {
+	var sentences []string
+	for _, sentence := range SplitSentences(paragraph) {
+		sentences = append(sentences, splitToFit(sentence, fineSize, size)...)
+	}
+	return sentences
+}
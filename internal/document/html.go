@@ -0,0 +1,431 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// htmlBoilerplate lists elements whose content is never part of the page's
+// text: scripts and styles, plus navigation and page chrome
+var htmlBoilerplate = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Nav:      true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Canvas:   true,
+	atom.Head:     true,
+}
+
+// htmlBoilerplateRoles are ARIA landmark roles for the same kind of chrome
+var htmlBoilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+}
+
+// LoadHTML loads an HTML file as structured text. Boilerplate is dropped and
+// headings, lists, tables and code blocks are rendered in markdown style so
+// the semantic chunker can split on headings. The page title and heading
+// outline are recorded in metadata.
+func LoadHTML(filePath string) (*Document, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	reader, err := charset.NewReader(bytes.NewReader(data), "text/html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect encoding of %s: %w", filePath, err)
+	}
+	root, err := html.Parse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML %s: %w", filePath, err)
+	}
+
+	r := &htmlRenderer{}
+	r.render(htmlContentRoot(root))
+	content := r.text()
+
+	doc := newFileDocument(filePath, data, content)
+
+	title := ""
+	if node := findHTMLElement(root, atom.Title); node != nil {
+		title = collapseSpace(htmlTextContent(node))
+	}
+	if title == "" && len(r.outline.paths) > 0 {
+		title = r.outline.paths[0]
+	}
+	if title != "" {
+		doc.Metadata["title"] = title
+	}
+	if len(r.outline.paths) > 0 {
+		doc.Metadata["headings"] = r.outline.paths
+	}
+
+	return doc, nil
+}
+
+// htmlContentRoot picks the element holding the page's main content: <main>
+// or role="main" when present, a lone <article>, and otherwise <body>
+func htmlContentRoot(root *html.Node) *html.Node {
+	if node := findHTMLNode(root, func(n *html.Node) bool {
+		return n.DataAtom == atom.Main || htmlAttr(n, "role") == "main"
+	}); node != nil {
+		return node
+	}
+
+	var articles []*html.Node
+	walkHTML(root, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Article {
+			articles = append(articles, n)
+			return false
+		}
+		return true
+	})
+	if len(articles) == 1 {
+		return articles[0]
+	}
+
+	if body := findHTMLElement(root, atom.Body); body != nil {
+		return body
+	}
+	return root
+}
+
+// htmlRenderer converts an element tree to markdown-style text
+type htmlRenderer struct {
+	out     strings.Builder
+	lists   []htmlList
+	outline headingOutline
+}
+
+type htmlList struct {
+	ordered bool
+	next    int
+}
+
+func (r *htmlRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.writeInline(n.Data)
+		return
+	case html.ElementNode:
+	case html.DocumentNode:
+		r.renderChildren(n)
+		return
+	default:
+		return
+	}
+
+	if isHTMLBoilerplate(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		text := collapseSpace(htmlTextContent(n))
+		if text == "" {
+			return
+		}
+		r.paragraph()
+		r.out.WriteString(strings.Repeat("#", level) + " " + text)
+		r.paragraph()
+		r.outline.add(level, text)
+
+	case atom.P, atom.Section, atom.Article, atom.Main, atom.Blockquote,
+		atom.Figure, atom.Address, atom.Details, atom.Dl:
+		r.paragraph()
+		r.renderChildren(n)
+		r.paragraph()
+
+	case atom.Div, atom.Figcaption, atom.Summary, atom.Dt:
+		r.line()
+		r.renderChildren(n)
+		r.line()
+
+	case atom.Dd:
+		r.line()
+		r.out.WriteString("  ")
+		r.renderChildren(n)
+		r.line()
+
+	case atom.Br:
+		r.out.WriteString("\n")
+
+	case atom.Hr:
+		r.paragraph()
+
+	case atom.Ul, atom.Ol:
+		if len(r.lists) == 0 {
+			r.paragraph()
+		} else {
+			r.line()
+		}
+		r.lists = append(r.lists, htmlList{ordered: n.DataAtom == atom.Ol, next: 1})
+		r.renderChildren(n)
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.paragraph()
+		} else {
+			r.line()
+		}
+
+	case atom.Li:
+		r.line()
+		marker := "- "
+		depth := len(r.lists)
+		if depth > 0 {
+			list := &r.lists[depth-1]
+			if list.ordered {
+				marker = fmt.Sprintf("%d. ", list.next)
+				list.next++
+			}
+			r.out.WriteString(strings.Repeat("  ", depth-1))
+		}
+		r.out.WriteString(marker)
+		r.renderChildren(n)
+		r.line()
+
+	case atom.Pre:
+		r.paragraph()
+		code := strings.Trim(htmlTextContent(n), "\n")
+		r.out.WriteString("```" + htmlCodeLanguage(n) + "\n" + code + "\n```")
+		r.paragraph()
+
+	case atom.Code, atom.Kbd, atom.Samp:
+		text := htmlTextContent(n)
+		if strings.TrimSpace(text) != "" {
+			r.writeInline("`" + strings.TrimSpace(text) + "`")
+		}
+
+	case atom.Table:
+		r.paragraph()
+		r.renderTable(n)
+		r.paragraph()
+
+	case atom.Img:
+		if alt := strings.TrimSpace(htmlAttr(n, "alt")); alt != "" {
+			r.writeInline("[" + alt + "]")
+		}
+
+	default:
+		r.renderChildren(n)
+	}
+}
+
+func (r *htmlRenderer) renderChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.render(c)
+	}
+}
+
+// renderTable writes each row as a pipe-separated line, with a separator
+// after a header row
+func (r *htmlRenderer) renderTable(table *html.Node) {
+	var rows [][]string
+	headerRow := -1
+	walkHTML(table, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+		if n.DataAtom == atom.Table && n != table {
+			return false // Nested tables are flattened into their cell
+		}
+		if n.DataAtom != atom.Tr {
+			return true
+		}
+		var cells []string
+		allHeaders := true
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+				continue
+			}
+			if c.DataAtom != atom.Th {
+				allHeaders = false
+			}
+			cells = append(cells, strings.ReplaceAll(collapseSpace(htmlTextContent(c)), "|", "\\|"))
+		}
+		if len(cells) > 0 {
+			if allHeaders && headerRow < 0 && len(rows) == 0 {
+				headerRow = 0
+			}
+			rows = append(rows, cells)
+		}
+		return false
+	})
+
+	for i, cells := range rows {
+		r.out.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		if i == headerRow {
+			r.out.WriteString(strings.Repeat("| --- ", len(cells)) + "|\n")
+		}
+	}
+}
+
+// writeInline writes text with whitespace collapsed as a browser would
+func (r *htmlRenderer) writeInline(text string) {
+	text = htmlWhitespace.ReplaceAllString(text, " ")
+	if text == "" || text == " " && r.atLineStart() {
+		return
+	}
+	if r.atLineStart() {
+		text = strings.TrimLeft(text, " ")
+	} else if strings.HasSuffix(r.out.String(), " ") {
+		text = strings.TrimLeft(text, " ")
+	}
+	r.out.WriteString(text)
+}
+
+func (r *htmlRenderer) atLineStart() bool {
+	s := r.out.String()
+	return len(s) == 0 || s[len(s)-1] == '\n'
+}
+
+// line ends the current line if anything is on it
+func (r *htmlRenderer) line() {
+	s := r.out.String()
+	if len(s) > 0 && s[len(s)-1] != '\n' {
+		r.out.WriteString("\n")
+	}
+}
+
+// paragraph ensures a blank line before the next block
+func (r *htmlRenderer) paragraph() {
+	r.line()
+	if s := r.out.String(); len(s) > 0 && !strings.HasSuffix(s, "\n\n") {
+		r.out.WriteString("\n")
+	}
+}
+
+func (r *htmlRenderer) text() string {
+	text := trailingSpace.ReplaceAllString(r.out.String(), "\n")
+	text = extraNewlines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+var htmlWhitespace = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(htmlWhitespace.ReplaceAllString(s, " "))
+}
+
+func isHTMLBoilerplate(n *html.Node) bool {
+	if n.DataAtom == atom.Header || n.DataAtom == atom.Footer {
+		// Only the page-level header and footer are chrome; an article's
+		// header usually holds its title
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p.DataAtom == atom.Article || p.DataAtom == atom.Main || p.DataAtom == atom.Section {
+				return false
+			}
+		}
+		return true
+	}
+	if htmlBoilerplate[n.DataAtom] {
+		return true
+	}
+	if htmlBoilerplateRoles[htmlAttr(n, "role")] {
+		return true
+	}
+	if htmlAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	for _, a := range n.Attr {
+		if a.Key == "hidden" {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlCodeLanguage reads a "language-x" or "lang-x" class from a <pre> or
+// its <code> child
+func htmlCodeLanguage(pre *html.Node) string {
+	nodes := []*html.Node{pre}
+	if c := pre.FirstChild; c != nil && c.DataAtom == atom.Code {
+		nodes = append(nodes, c)
+	}
+	for _, n := range nodes {
+		for _, class := range strings.Fields(htmlAttr(n, "class")) {
+			for _, prefix := range []string{"language-", "lang-"} {
+				if strings.HasPrefix(class, prefix) {
+					return strings.TrimPrefix(class, prefix)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// htmlTextContent returns the raw text below n, skipping boilerplate
+func htmlTextContent(n *html.Node) string {
+	var b strings.Builder
+	walkHTML(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c != n && isHTMLBoilerplate(c) && c.DataAtom != atom.Head {
+			return false
+		}
+		if c.Type == html.ElementNode && c.DataAtom == atom.Br {
+			b.WriteString("\n")
+		}
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+		return true
+	})
+	return b.String()
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// walkHTML visits n and its descendants depth-first; returning false from
+// visit skips a node's children
+func walkHTML(n *html.Node, visit func(*html.Node) bool) {
+	if !visit(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkHTML(c, visit)
+	}
+}
+
+func findHTMLNode(root *html.Node, match func(*html.Node) bool) *html.Node {
+	var found *html.Node
+	walkHTML(root, func(n *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if n.Type == html.ElementNode && match(n) {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func findHTMLElement(root *html.Node, a atom.Atom) *html.Node {
+	return findHTMLNode(root, func(n *html.Node) bool { return n.DataAtom == a })
+}
@@ -0,0 +1,317 @@
+package document
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	jsFunctionPattern = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`)
+	jsClassPattern    = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`)
+	jsArrowPattern    = regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|\(|[A-Za-z_$][\w$]*\s*=>)`)
+	jsTypePattern     = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:interface|type|enum|const\s+enum|namespace|module)\s+([A-Za-z_$][\w$.]*)`)
+	jsMethodPattern   = regexp.MustCompile(`^(?:(?:static|async|get|set|public|private|protected|readonly|override|abstract|declare)\s+)*\*?\s*(#?[A-Za-z_$][\w$]*)\s*(?:<[^>]*>)?\s*\(`)
+	jsKeywords        = map[string]bool{
+		"if": true, "for": true, "while": true, "switch": true, "catch": true,
+		"return": true, "function": true, "with": true,
+	}
+)
+
+// jsRegexKeywords are keywords after which a slash starts a regular
+// expression rather than a division
+var jsRegexKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true,
+	"in": true, "of": true, "new": true, "delete": true, "void": true,
+	"throw": true, "yield": true, "await": true, "instanceof": true,
+}
+
+var jsLanguage = codeLanguage{
+	classify: func(line string, member bool) (string, string) {
+		if m := jsFunctionPattern.FindStringSubmatch(line); m != nil {
+			return "function", m[1]
+		}
+		if m := jsClassPattern.FindStringSubmatch(line); m != nil {
+			return "class", m[1]
+		}
+		if m := jsArrowPattern.FindStringSubmatch(line); m != nil {
+			return "function", m[1]
+		}
+		if m := jsTypePattern.FindStringSubmatch(line); m != nil {
+			return "type", m[1]
+		}
+		if !member {
+			return "", ""
+		}
+		if m := jsMethodPattern.FindStringSubmatch(line); m != nil && !jsKeywords[m[1]] {
+			return "function", m[1]
+		}
+		return "", ""
+	},
+	attaches: func(line string) bool {
+		return strings.HasPrefix(line, "@")
+	},
+	continues: func(line string) bool {
+		return line != "" && strings.ContainsRune("})].,?:+*/%&|^=>", rune(line[0]))
+	},
+	comment: "//",
+}
+
+// ChunkJSSource splits JavaScript or TypeScript source into one chunk per
+// top-level function, class, interface, type or enum, matching braces to
+// find where each ends while skipping strings, comments, template literals
+// and regular expressions. Comments and decorators directly above a
+// declaration stay with it and other top-level statements are grouped.
+// Classes larger than maxChunkSize are split into a header chunk and one
+// chunk per method, and long functions are split between statements.
+func ChunkJSSource(doc *Document, maxChunkSize int) ([]*Chunk, error) {
+	return chunkJSSource(doc, maxChunkSize, CountChars)
+}
+
+func chunkJSSource(doc *Document, maxChunkSize int, size SizeFunc) ([]*Chunk, error) {
+	lines, info, err := lexJS(doc.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JavaScript source: %w", err)
+	}
+	return chunkStructured(doc, jsLanguage, lines, info, maxChunkSize, size), nil
+}
+
+// jsLexer tracks the state needed to find statement starts in JavaScript
+type jsLexer struct {
+	src   string
+	pos   int
+	brace int // open braces outside template substitutions
+	paren int // open parentheses and brackets
+	// templates holds, for each template literal substitution being read,
+	// the brace depth inside it
+	templates []int
+	// last is the last significant character or word read, used to tell a
+	// regular expression from a division
+	last string
+}
+
+// lexJS records, for each line, whether it begins at statement level and
+// the brace depth it begins at
+func lexJS(src string) ([]string, []lineInfo, error) {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	lines := strings.Split(src, "\n")
+	info := make([]lineInfo, len(lines))
+
+	lx := &jsLexer{src: src}
+	line := 0
+	info[0].start = true
+	lineStart := func() {
+		line++
+		info[line].level = lx.brace
+		info[line].start = lx.paren == 0 && len(lx.templates) == 0 && !jsContinues(lx.last)
+	}
+
+	for lx.pos < len(src) {
+		ch := src[lx.pos]
+		switch {
+		case ch == '\n':
+			lx.pos++
+			lineStart()
+			continue
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\f':
+			lx.pos++
+			continue
+		case strings.HasPrefix(src[lx.pos:], "//"):
+			for lx.pos < len(src) && src[lx.pos] != '\n' {
+				lx.pos++
+			}
+			continue
+		case strings.HasPrefix(src[lx.pos:], "/*"):
+			end := strings.Index(src[lx.pos+2:], "*/")
+			if end < 0 {
+				return nil, nil, fmt.Errorf("unterminated comment on line %d", line+1)
+			}
+			for _, c := range src[lx.pos : lx.pos+end+4] {
+				if c == '\n' {
+					line++
+					info[line].level = lx.brace
+				}
+			}
+			lx.pos += end + 4
+			continue
+		}
+
+		info[line].code = true
+		startLine := line
+		if err := lx.token(&line); err != nil {
+			return nil, nil, fmt.Errorf("%w on line %d", err, startLine+1)
+		}
+		// Lines inside a multi-line string or template are not statements
+		for l := startLine + 1; l <= line; l++ {
+			info[l].code = true
+			info[l].level = lx.brace
+		}
+	}
+
+	if lx.brace != 0 || lx.paren != 0 || len(lx.templates) != 0 {
+		return nil, nil, fmt.Errorf("unbalanced brackets")
+	}
+	return lines, info, nil
+}
+
+// jsContinues reports whether a statement ending in last must continue on
+// the next line
+func jsContinues(last string) bool {
+	if last == "" {
+		return false
+	}
+	if last == "=>" {
+		return true
+	}
+	return len(last) == 1 && strings.Contains("=+-*/%&|^?:,.(<[!~", last)
+}
+
+// token reads one token at lx.pos, advancing line across any newlines in it
+func (lx *jsLexer) token(line *int) error {
+	src := lx.src
+	ch := src[lx.pos]
+
+	switch {
+	case ch == '\'' || ch == '"':
+		lx.pos++
+		for lx.pos < len(src) && src[lx.pos] != ch {
+			if src[lx.pos] == '\\' {
+				lx.pos++
+			} else if src[lx.pos] == '\n' {
+				// Unterminated; JSX text often holds apostrophes
+				lx.last = "'"
+				return nil
+			}
+			lx.pos++
+		}
+		lx.pos++
+		lx.last = "'"
+		return nil
+
+	case ch == '`':
+		lx.pos++
+		return lx.template(line)
+
+	case ch == '/':
+		if !lx.regexAllowed() {
+			lx.pos++
+			lx.last = "/"
+			return nil
+		}
+		lx.pos++
+		inClass := false
+		for lx.pos < len(src) && src[lx.pos] != '\n' {
+			c := src[lx.pos]
+			lx.pos++
+			if c == '\\' {
+				lx.pos++
+			} else if c == '[' {
+				inClass = true
+			} else if c == ']' {
+				inClass = false
+			} else if c == '/' && !inClass {
+				break
+			}
+		}
+		for lx.pos < len(src) && isJSIdentChar(src[lx.pos]) {
+			lx.pos++
+		}
+		lx.last = "x"
+		return nil
+
+	case ch == '{':
+		if n := len(lx.templates); n > 0 {
+			lx.templates[n-1]++
+		} else {
+			lx.brace++
+		}
+
+	case ch == '}':
+		if n := len(lx.templates); n > 0 {
+			if lx.templates[n-1] == 0 {
+				// End of a template substitution: back to the literal
+				lx.templates = lx.templates[:n-1]
+				lx.pos++
+				return lx.template(line)
+			}
+			lx.templates[n-1]--
+		} else {
+			lx.brace--
+			if lx.brace < 0 {
+				return fmt.Errorf("unbalanced '}'")
+			}
+		}
+
+	case ch == '(' || ch == '[':
+		lx.paren++
+
+	case ch == ')' || ch == ']':
+		lx.paren--
+		if lx.paren < 0 {
+			return fmt.Errorf("unbalanced %q", ch)
+		}
+
+	case isJSIdentChar(ch):
+		start := lx.pos
+		for lx.pos < len(src) && isJSIdentChar(src[lx.pos]) {
+			lx.pos++
+		}
+		lx.last = src[start:lx.pos]
+		return nil
+
+	case ch == '=' && strings.HasPrefix(src[lx.pos:], "=>"):
+		lx.pos += 2
+		lx.last = "=>"
+		return nil
+	}
+
+	lx.pos++
+	lx.last = string(ch)
+	return nil
+}
+
+// template reads a template literal up to its closing backquote or the
+// start of a substitution
+func (lx *jsLexer) template(line *int) error {
+	src := lx.src
+	for lx.pos < len(src) {
+		switch c := src[lx.pos]; {
+		case c == '\\':
+			lx.pos++
+		case c == '\n':
+			*line++
+		case c == '`':
+			lx.pos++
+			lx.last = "'"
+			return nil
+		case c == '$' && lx.pos+1 < len(src) && src[lx.pos+1] == '{':
+			lx.pos += 2
+			lx.templates = append(lx.templates, 0)
+			lx.last = "{"
+			return nil
+		}
+		lx.pos++
+	}
+	return fmt.Errorf("unterminated template literal")
+}
+
+// regexAllowed reports whether a slash at the current position starts a
+// regular expression
+func (lx *jsLexer) regexAllowed() bool {
+	if lx.last == "" || lx.last == "=>" {
+		return true
+	}
+	if jsRegexKeywords[lx.last] {
+		return true
+	}
+	if len(lx.last) == 1 && strings.Contains("(,=:[!&|?{};+-*%<>~^", lx.last) {
+		return true
+	}
+	return false
+}
+
+func isJSIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c == '#' ||
+		c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c >= 0x80
+}
@@ -0,0 +1,43 @@
+package document
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzLexJS checks that the JavaScript lexer and chunker handle any input
+// without panicking, and that chunking loses no code
+func FuzzLexJS(f *testing.F) {
+	f.Add("function add(a, b) {\n  return a + b;\n}\n")
+	f.Add("class A {\n  m() { return `x ${y + `z ${w}`} /`; }\n}\n")
+	f.Add("const re = /[/}]+/g;\nconst d = a / b / c;\n")
+	f.Add("export interface P {\n  x: number; // }\n}\n/* { */\ntype T = { a: string };\n")
+	f.Add("@decorator()\nexport default class {\n}\nif (x) {\n  y();\n}\n")
+	f.Add("const s = 'it\\'s';\r\nlet t = \"{\";\n")
+	f.Add("unterminated = `${")
+
+	f.Fuzz(func(t *testing.T, src string) {
+		lines, info, err := lexJS(src)
+		if err != nil {
+			return
+		}
+		if len(lines) != len(info) {
+			t.Fatalf("got %d lines but %d line infos", len(lines), len(info))
+		}
+
+		doc := &Document{ID: "fuzz", Content: src, Metadata: map[string]interface{}{"file": "fuzz.js"}}
+		chunks, err := ChunkJSSource(doc, 200)
+		if err != nil {
+			t.Fatalf("ChunkJSSource failed on source lexJS accepted: %v", err)
+		}
+		var joined strings.Builder
+		for _, chunk := range chunks {
+			joined.WriteString(chunk.Content)
+		}
+		for _, field := range strings.Fields(src) {
+			if !strings.Contains(joined.String(), field) {
+				t.Fatalf("chunks lost %q", field)
+			}
+		}
+	})
+}
@@ -0,0 +1,318 @@
+package document
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Loader reads one kind of file into a Document, adding metadata specific to
+// its format
+type Loader interface {
+	// Name identifies the file type and is recorded as file_type metadata
+	Name() string
+	// Extensions lists the file extensions handled, lowercase with the dot
+	Extensions() []string
+	// Load reads the file at path
+	Load(path string) (*Document, error)
+}
+
+// registeredLoader numbers registrations so loaders need not be comparable
+type registeredLoader struct {
+	id     int
+	loader Loader
+}
+
+var (
+	registryMutex sync.RWMutex
+	loaders       []registeredLoader
+	byExtension   = make(map[string]registeredLoader)
+	nextLoaderID  int
+)
+
+// RegisterLoader makes a loader available to LoadFromFile for each of its
+// extensions. A loader registered later takes over extensions claimed by an
+// earlier one, so applications embedding this package can replace the
+// built-in loaders as well as add their own.
+func RegisterLoader(loader Loader) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	nextLoaderID++
+	entry := registeredLoader{id: nextLoaderID, loader: loader}
+	for _, ext := range loader.Extensions() {
+		byExtension[strings.ToLower(ext)] = entry
+	}
+
+	// Drop loaders left without any extension
+	kept := loaders[:0]
+	for _, existing := range loaders {
+		for _, ext := range existing.loader.Extensions() {
+			if byExtension[strings.ToLower(ext)].id == existing.id {
+				kept = append(kept, existing)
+				break
+			}
+		}
+	}
+	loaders = append(kept, entry)
+}
+
+// LoaderFor returns the loader registered for the file's extension
+func LoaderFor(path string) (Loader, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	entry, ok := byExtension[strings.ToLower(filepath.Ext(path))]
+	return entry.loader, ok
+}
+
+// Loaders returns the registered loaders in registration order
+func Loaders() []Loader {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	result := make([]Loader, len(loaders))
+	for i, entry := range loaders {
+		result[i] = entry.loader
+	}
+	return result
+}
+
+// SupportedExtensions returns every extension with a registered loader,
+// sorted
+func SupportedExtensions() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	extensions := make([]string, 0, len(byExtension))
+	for ext := range byExtension {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+// LoadFromFile loads a document using the loader registered for its
+// extension. Files with an unregistered extension are read as plain text.
+func LoadFromFile(filePath string) (*Document, error) {
+	loader, ok := LoaderFor(filePath)
+	if !ok {
+		loader = textLoader
+	}
+
+	doc, err := loader.Load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := doc.Metadata["file_type"]; !ok {
+		doc.Metadata["file_type"] = loader.Name()
+	}
+	return doc, nil
+}
+
+// funcLoader adapts a load function to the Loader interface
+type funcLoader struct {
+	name       string
+	extensions []string
+	load       func(path string) (*Document, error)
+}
+
+// NewLoader creates a Loader from a name, the extensions it handles and a
+// load function
+func NewLoader(name string, extensions []string, load func(path string) (*Document, error)) Loader {
+	return &funcLoader{name: name, extensions: extensions, load: load}
+}
+
+func (l *funcLoader) Name() string                        { return l.name }
+func (l *funcLoader) Extensions() []string                { return l.extensions }
+func (l *funcLoader) Load(path string) (*Document, error) { return l.load(path) }
+
+// newFileDocument builds a document for content read from filePath, with an
+// ID derived from the path and raw bytes and the metadata every loader
+// records
+func newFileDocument(filePath string, data []byte, content string) *Document {
+	hasher := md5.New()
+	hasher.Write([]byte(filePath))
+	hasher.Write(data)
+	docID := fmt.Sprintf("%x", hasher.Sum(nil))
+
+	return &Document{
+		ID:      docID,
+		Content: content,
+		Metadata: map[string]interface{}{
+			"file":      filePath,
+			"filename":  filepath.Base(filePath),
+			"extension": filepath.Ext(filePath),
+			"size":      len(data),
+		},
+	}
+}
+
+// readUTF8File reads a file that must be valid UTF-8 text
+func readUTF8File(filePath string) ([]byte, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	// Validate UTF-8 encoding
+	if !utf8.Valid(content) {
+		return nil, fmt.Errorf("file %s contains invalid UTF-8", filePath)
+	}
+	return content, nil
+}
+
+// LoadText loads a plain text file
+func LoadText(filePath string) (*Document, error) {
+	content, err := readUTF8File(filePath)
+	if err != nil {
+		return nil, err
+	}
+	doc := newFileDocument(filePath, content, string(content))
+	doc.Metadata["line_count"] = strings.Count(string(content), "\n") + 1
+	return doc, nil
+}
+
+// LoadMarkdown loads a markdown file. YAML front matter is removed from the
+// content and its fields are added to the metadata, where they can be used
+// in filters; fields named like built-in metadata get a "front_matter_"
+// prefix, and a block that isn't valid YAML is kept as text. The title
+// comes from the front matter or else the first top-level heading.
+func LoadMarkdown(filePath string) (*Document, error) {
+	content, err := readUTF8File(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	body := string(content)
+	var fields map[string]interface{}
+	if frontMatter, rest, ok := splitFrontMatter(body); ok {
+		// A block that isn't valid YAML is left in place as ordinary text
+		if parsed, err := parseFrontMatter(frontMatter); err == nil {
+			fields = parsed
+			body = rest
+		}
+	}
+
+	doc := newFileDocument(filePath, content, body)
+	for key, value := range fields {
+		if reservedMetadata[key] {
+			key = "front_matter_" + key
+		}
+		doc.Metadata[key] = value
+	}
+	if _, ok := doc.Metadata["title"]; !ok {
+		var fence markdownFence
+		for _, line := range strings.Split(body, "\n") {
+			if fence.inCode(line) {
+				continue
+			}
+			if level, text, ok := parseMarkdownHeading(line); ok && level == 1 && text != "" {
+				doc.Metadata["title"] = text
+				break
+			}
+		}
+	}
+	return doc, nil
+}
+
+// codeLanguages maps source file extensions to language names
+var codeLanguages = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".jsx":  "javascript",
+	".mjs":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".java": "java",
+	".c":    "c",
+	".h":    "c",
+	".cpp":  "cpp",
+	".cc":   "cpp",
+	".cxx":  "cpp",
+	".hpp":  "cpp",
+	".rs":   "rust",
+	".rb":   "ruby",
+	".php":  "php",
+	".sh":   "shell",
+	".bash": "shell",
+	".sql":  "sql",
+	".css":  "css",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".xml":  "xml",
+}
+
+// LoadCode loads a source file, recording its language and line count
+func LoadCode(filePath string) (*Document, error) {
+	content, err := readUTF8File(filePath)
+	if err != nil {
+		return nil, err
+	}
+	doc := newFileDocument(filePath, content, string(content))
+	if language, ok := codeLanguages[strings.ToLower(filepath.Ext(filePath))]; ok {
+		doc.Metadata["language"] = language
+	}
+	doc.Metadata["line_count"] = strings.Count(string(content), "\n") + 1
+	return doc, nil
+}
+
+// LoadJSON loads a JSON file, rejecting invalid JSON and recording the
+// top-level type and, for objects, its keys
+func LoadJSON(filePath string) (*Document, error) {
+	content, err := readUTF8File(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return nil, fmt.Errorf("file %s is not valid JSON: %w", filePath, err)
+	}
+
+	doc := newFileDocument(filePath, content, string(content))
+	switch v := value.(type) {
+	case map[string]interface{}:
+		doc.Metadata["json_type"] = "object"
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		doc.Metadata["json_keys"] = keys
+	case []interface{}:
+		doc.Metadata["json_type"] = "array"
+		doc.Metadata["json_length"] = len(v)
+	default:
+		doc.Metadata["json_type"] = "scalar"
+	}
+	return doc, nil
+}
+
+// textLoader also reads files whose extension has no registered loader
+var textLoader = NewLoader("text", []string{".txt", ".text"}, LoadText)
+
+func init() {
+	codeExtensions := make([]string, 0, len(codeLanguages))
+	for ext := range codeLanguages {
+		codeExtensions = append(codeExtensions, ext)
+	}
+	sort.Strings(codeExtensions)
+
+	RegisterLoader(textLoader)
+	RegisterLoader(NewLoader("markdown", []string{".md", ".markdown"}, LoadMarkdown))
+	RegisterLoader(NewLoader("code", codeExtensions, LoadCode))
+	RegisterLoader(NewLoader("json", []string{".json"}, LoadJSON))
+	RegisterLoader(NewLoader("pdf", []string{".pdf"}, LoadPDF))
+	RegisterLoader(NewLoader("html", []string{".html", ".htm"}, LoadHTML))
+	RegisterLoader(NewLoader("docx", []string{".docx"}, LoadDOCX))
+	RegisterLoader(NewLoader("pptx", []string{".pptx"}, LoadPPTX))
+	RegisterLoader(NewLoader("xlsx", []string{".xlsx"}, LoadXLSX))
+}
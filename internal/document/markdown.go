@@ -0,0 +1,167 @@
+package document
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// markdownHeading matches an ATX heading, capturing its level marker and
+// text without any closing hashes
+var markdownHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+
+// parseMarkdownHeading returns the level and text of a heading line
+func parseMarkdownHeading(line string) (level int, text string, ok bool) {
+	m := markdownHeading.FindStringSubmatch(line)
+	if m == nil {
+		return 0, "", false
+	}
+	return len(m[1]), strings.TrimSpace(m[2]), true
+}
+
+// markdownFence tracks fenced code blocks so lines inside them aren't taken
+// for headings. A fence closes only with the character it opened with,
+// repeated at least as many times.
+type markdownFence struct {
+	marker string
+}
+
+// inCode reports whether line is part of a fenced code block, fences
+// included, updating the state
+func (f *markdownFence) inCode(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return f.marker != ""
+	}
+
+	run := 0
+	for run < len(trimmed) && (trimmed[run] == '`' || trimmed[run] == '~') && trimmed[run] == trimmed[0] {
+		run++
+	}
+	if run < 3 {
+		return f.marker != ""
+	}
+
+	if f.marker == "" {
+		f.marker = trimmed[:run]
+		return true
+	}
+	if trimmed[0] == f.marker[0] && run >= len(f.marker) && strings.TrimSpace(trimmed[run:]) == "" {
+		f.marker = ""
+	}
+	return true
+}
+
+// HeadingPath returns the chunk's heading breadcrumb, such as
+// "Deploy Guide > Configuration", or "" when it has none
+func HeadingPath(chunk *Chunk) string {
+	path, _ := chunk.Metadata["heading_path"].(string)
+	return path
+}
+
+// EmbeddingText returns the text to embed for a chunk: its content, preceded
+// by its heading path when it has one, so that the headings a section sits
+// under inform its embedding
+func EmbeddingText(chunk *Chunk) string {
+	if path := HeadingPath(chunk); path != "" {
+		return path + "\n\n" + chunk.Content
+	}
+	return chunk.Content
+}
+
+// reservedMetadata are keys set by the loaders and chunkers, which front
+// matter fields may not replace
+var reservedMetadata = map[string]bool{
+	"file": true, "filename": true, "extension": true, "size": true,
+	"file_type": true, "parent_id": true, "chunk_index": true,
+	"chunk_start": true, "chunk_end": true, "chunk_type": true,
+	"heading_path": true, "heading": true, "heading_level": true, "headings": true,
+}
+
+// splitFrontMatter separates YAML front matter, delimited by "---" lines at
+// the very start of a markdown file, from the body
+func splitFrontMatter(content string) (frontMatter, body string, ok bool) {
+	text := strings.TrimPrefix(content, "\ufeff")
+	if !strings.HasPrefix(text, "---\n") && !strings.HasPrefix(text, "---\r\n") {
+		return "", content, false
+	}
+	rest := text[strings.Index(text, "\n")+1:]
+
+	offset := 0
+	for offset <= len(rest) {
+		end := strings.Index(rest[offset:], "\n")
+		line := rest[offset:]
+		if end >= 0 {
+			line = rest[offset : offset+end]
+		}
+		if trimmed := strings.TrimRight(line, " \t\r"); trimmed == "---" || trimmed == "..." {
+			body := ""
+			if end >= 0 {
+				body = rest[offset+end+1:]
+			}
+			return rest[:offset], body, true
+		}
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+	return "", content, false
+}
+
+// parseFrontMatter decodes YAML front matter into flat metadata fields.
+// Nested mappings become dotted keys ("author.name"), lists of scalars
+// become string lists, and dates are formatted as YYYY-MM-DD.
+func parseFrontMatter(frontMatter string) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if err := yaml.Unmarshal([]byte(frontMatter), &values); err != nil {
+		return nil, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	fields := make(map[string]interface{})
+	var flatten func(prefix string, value interface{})
+	flatten = func(prefix string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				flatten(prefix+"."+key, v[key])
+			}
+		case []interface{}:
+			list := make([]string, 0, len(v))
+			for _, item := range v {
+				if item != nil {
+					list = append(list, frontMatterScalar(item))
+				}
+			}
+			fields[prefix] = list
+		case nil:
+		case string, bool, int, float64:
+			fields[prefix] = v
+		default:
+			fields[prefix] = frontMatterScalar(v)
+		}
+	}
+	for key, value := range values {
+		flatten(key, value)
+	}
+	return fields, nil
+}
+
+func frontMatterScalar(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+			return t.Format("2006-01-02")
+		}
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,772 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file reads the Office Open XML formats (DOCX, PPTX, XLSX) straight
+// from their zip containers. Text is rendered in the same markdown style as
+// the HTML loader so headings and tables survive chunking.
+
+// maxOOXMLPartSize bounds how much of a single zip member is read, guarding
+// against decompression bombs
+const maxOOXMLPartSize = 256 << 20
+
+// ooxmlPackage is an opened OOXML zip container
+type ooxmlPackage struct {
+	files map[string]*zip.File
+	data  []byte
+}
+
+func openOOXML(filePath string) (*ooxmlPackage, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s as an Office document: %w", filePath, err)
+	}
+	pkg := &ooxmlPackage{files: make(map[string]*zip.File), data: data}
+	for _, f := range reader.File {
+		pkg.files[f.Name] = f
+	}
+	return pkg, nil
+}
+
+// has reports whether the package contains a part
+func (p *ooxmlPackage) has(name string) bool {
+	_, ok := p.files[name]
+	return ok
+}
+
+// open returns a decoder over a part of the package
+func (p *ooxmlPackage) open(name string) (*xml.Decoder, io.Closer, error) {
+	f, ok := p.files[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("missing part %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open part %s: %w", name, err)
+	}
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxOOXMLPartSize))
+	decoder.Strict = false
+	return decoder, rc, nil
+}
+
+// relationships maps relationship IDs to part names for the part at source
+func (p *ooxmlPackage) relationships(source string) map[string]string {
+	relsPath := path.Join(path.Dir(source), "_rels", path.Base(source)+".rels")
+	decoder, closer, err := p.open(relsPath)
+	if err != nil {
+		return nil
+	}
+	defer closer.Close()
+
+	rels := make(map[string]string)
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "Relationship" {
+			target := xmlAttr(start, "Target")
+			if xmlAttr(start, "TargetMode") == "External" {
+				continue
+			}
+			if strings.HasPrefix(target, "/") {
+				target = strings.TrimPrefix(target, "/")
+			} else {
+				target = path.Join(path.Dir(source), target)
+			}
+			rels[xmlAttr(start, "Id")] = target
+		}
+	}
+	return rels
+}
+
+// coreTitle reads the document title from docProps/core.xml
+func (p *ooxmlPackage) coreTitle() string {
+	decoder, closer, err := p.open("docProps/core.xml")
+	if err != nil {
+		return ""
+	}
+	defer closer.Close()
+
+	inTitle := false
+	var title strings.Builder
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inTitle = t.Name.Local == "title"
+		case xml.EndElement:
+			inTitle = false
+		case xml.CharData:
+			if inTitle {
+				title.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(title.String())
+}
+
+// newOfficeDocument assembles a document with the metadata every Office
+// loader records
+func newOfficeDocument(filePath string, pkg *ooxmlPackage, content string) *Document {
+	doc := newFileDocument(filePath, pkg.data, content)
+	if title := pkg.coreTitle(); title != "" {
+		doc.Metadata["title"] = title
+	}
+	return doc
+}
+
+func xmlAttr(start xml.StartElement, local string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// LoadDOCX loads a Word document. Paragraphs styled as headings become
+// markdown headings, list paragraphs become list items and tables are
+// rendered as pipe-separated rows.
+func LoadDOCX(filePath string) (*Document, error) {
+	pkg, err := openOOXML(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := pkg.docxHeadingLevels()
+
+	decoder, closer, err := pkg.open("word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load DOCX %s: %w", filePath, err)
+	}
+	defer closer.Close()
+
+	var (
+		out       strings.Builder
+		para      strings.Builder
+		outline   headingOutline
+		style     string
+		isList    bool
+		lastList  bool // previous block was a list item
+		inText    bool
+		tableRows [][]string
+		row       []string
+		cell      []string
+		depth     int // table nesting depth
+	)
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DOCX %s: %w", filePath, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				style, isList = "", false
+			case "pStyle":
+				style = xmlAttr(t, "val")
+			case "numPr":
+				isList = true
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			case "tbl":
+				depth++
+				if depth == 1 {
+					tableRows = nil
+				}
+			case "tr":
+				if depth == 1 {
+					row = nil
+				}
+			case "tc":
+				if depth == 1 {
+					cell = nil
+				}
+			}
+
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if text == "" {
+					continue
+				}
+				if depth > 0 {
+					cell = append(cell, collapseSpace(text))
+					continue
+				}
+				level := levels[style]
+				if isList && level == 0 && lastList {
+					out.WriteString("\n")
+				} else {
+					writeBlockSeparator(&out)
+				}
+				lastList = false
+				switch {
+				case level > 0:
+					out.WriteString(strings.Repeat("#", level) + " " + collapseSpace(text))
+					outline.add(level, collapseSpace(text))
+				case isList:
+					out.WriteString("- " + text)
+					lastList = true
+				default:
+					out.WriteString(text)
+				}
+			case "tc":
+				if depth == 1 {
+					row = append(row, strings.ReplaceAll(strings.Join(cell, " "), "|", "\\|"))
+				}
+			case "tr":
+				if depth == 1 && len(row) > 0 {
+					tableRows = append(tableRows, row)
+				}
+			case "tbl":
+				depth--
+				if depth == 0 && len(tableRows) > 0 {
+					lastList = false
+					writeBlockSeparator(&out)
+					writePipeTable(&out, tableRows, true)
+				}
+			}
+		}
+	}
+
+	doc := newOfficeDocument(filePath, pkg, strings.TrimSpace(out.String()))
+	if len(outline.paths) > 0 {
+		doc.Metadata["headings"] = outline.paths
+	}
+	return doc, nil
+}
+
+var headingStyleName = regexp.MustCompile(`^heading\s*([1-9])$`)
+
+// docxHeadingLevels maps paragraph style IDs to heading levels using
+// word/styles.xml, so localized style IDs resolve through their names
+func (p *ooxmlPackage) docxHeadingLevels() map[string]int {
+	levels := map[string]int{"Title": 1}
+	for i := 1; i <= 9; i++ {
+		levels["Heading"+strconv.Itoa(i)] = i
+	}
+
+	decoder, closer, err := p.open("word/styles.xml")
+	if err != nil {
+		return levels
+	}
+	defer closer.Close()
+
+	var styleID string
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "style":
+			styleID = xmlAttr(start, "styleId")
+		case "name":
+			name := strings.ToLower(xmlAttr(start, "val"))
+			if m := headingStyleName.FindStringSubmatch(name); m != nil {
+				levels[styleID] = int(m[1][0] - '0')
+			} else if name == "title" {
+				levels[styleID] = 1
+			}
+		case "outlineLvl":
+			if lvl, err := strconv.Atoi(xmlAttr(start, "val")); err == nil && lvl < 9 && styleID != "" {
+				if _, known := levels[styleID]; !known {
+					levels[styleID] = lvl + 1
+				}
+			}
+		}
+	}
+	return levels
+}
+
+// LoadPPTX loads a PowerPoint deck. Each slide becomes a section headed by
+// its number and title, and chunks record the slide they came from.
+func LoadPPTX(filePath string) (*Document, error) {
+	pkg, err := openOOXML(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	slides := pkg.pptxSlideOrder()
+	if len(slides) == 0 {
+		return nil, fmt.Errorf("PPTX %s contains no slides", filePath)
+	}
+
+	var out strings.Builder
+	var spans []PageSpan
+	for i, slidePath := range slides {
+		title, body, err := pkg.pptxSlideText(slidePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load slide %d of %s: %w", i+1, filePath, err)
+		}
+		if title == "" && body == "" {
+			continue
+		}
+
+		writeBlockSeparator(&out)
+		start := out.Len()
+		heading := fmt.Sprintf("# Slide %d", i+1)
+		if title != "" {
+			heading += ": " + title
+		}
+		out.WriteString(heading)
+		if body != "" {
+			out.WriteString("\n\n" + body)
+		}
+		spans = append(spans, PageSpan{Number: i + 1, Start: start, End: out.Len()})
+	}
+
+	doc := newOfficeDocument(filePath, pkg, out.String())
+	doc.Pages = spans
+	doc.PageUnit = "slide"
+	doc.Metadata["slide_count"] = len(slides)
+	return doc, nil
+}
+
+// pptxSlideOrder returns slide part names in presentation order
+func (p *ooxmlPackage) pptxSlideOrder() []string {
+	rels := p.relationships("ppt/presentation.xml")
+	var slides []string
+
+	if decoder, closer, err := p.open("ppt/presentation.xml"); err == nil {
+		defer closer.Close()
+		for {
+			tok, err := decoder.Token()
+			if err != nil {
+				break
+			}
+			if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "sldId" {
+				for _, a := range start.Attr {
+					if a.Name.Local == "id" && a.Name.Space != "" {
+						if target, ok := rels[a.Value]; ok {
+							slides = append(slides, target)
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(slides) > 0 {
+		return slides
+	}
+
+	// Fall back to the slide part names, ordered numerically
+	for name := range p.files {
+		if strings.HasPrefix(name, "ppt/slides/slide") && strings.HasSuffix(name, ".xml") {
+			slides = append(slides, name)
+		}
+	}
+	sort.Slice(slides, func(i, j int) bool {
+		return partNumber(slides[i]) < partNumber(slides[j])
+	})
+	return slides
+}
+
+var partNumberPattern = regexp.MustCompile(`(\d+)\.xml$`)
+
+func partNumber(name string) int {
+	if m := partNumberPattern.FindStringSubmatch(name); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+// pptxSlideText extracts the title placeholder and the remaining text of a
+// slide, one line per paragraph
+func (p *ooxmlPackage) pptxSlideText(slidePath string) (string, string, error) {
+	decoder, closer, err := p.open(slidePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer closer.Close()
+
+	var (
+		title     []string
+		body      []string
+		para      strings.Builder
+		inText    bool
+		isTitle   bool
+		shapeDeep int
+	)
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sp":
+				shapeDeep++
+				if shapeDeep == 1 {
+					isTitle = false
+				}
+			case "ph":
+				if typ := xmlAttr(t, "type"); typ == "title" || typ == "ctrTitle" {
+					isTitle = true
+				}
+			case "p":
+				para.Reset()
+			case "t":
+				inText = true
+			case "br":
+				para.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "sp":
+				shapeDeep--
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if text == "" {
+					continue
+				}
+				if isTitle {
+					title = append(title, collapseSpace(text))
+				} else {
+					body = append(body, text)
+				}
+			}
+		}
+	}
+
+	return strings.Join(title, " "), strings.Join(body, "\n"), nil
+}
+
+// LoadXLSX loads an Excel workbook. The first non-empty row of each sheet is
+// taken as its header and every following row is written as "Header: value"
+// pairs so each row stays meaningful when chunked on its own. Chunks record
+// the sheet number and name.
+func LoadXLSX(filePath string) (*Document, error) {
+	pkg, err := openOOXML(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := pkg.xlsxSharedStrings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load XLSX %s: %w", filePath, err)
+	}
+	sheets := pkg.xlsxSheets()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("XLSX %s contains no sheets", filePath)
+	}
+
+	var out strings.Builder
+	var spans []PageSpan
+	names := make([]string, 0, len(sheets))
+	for i, sheet := range sheets {
+		names = append(names, sheet.name)
+		rows, err := pkg.xlsxRows(sheet.part, shared)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sheet %s of %s: %w", sheet.name, filePath, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		writeBlockSeparator(&out)
+		start := out.Len()
+		out.WriteString("# Sheet: " + sheet.name)
+
+		header := rows[0]
+		for _, row := range rows[1:] {
+			var pairs []string
+			for col, value := range row {
+				if value == "" {
+					continue
+				}
+				label := ""
+				if col < len(header) {
+					label = header[col]
+				}
+				if label == "" {
+					label = columnName(col)
+				}
+				pairs = append(pairs, label+": "+value)
+			}
+			if len(pairs) > 0 {
+				// Rows are separated by blank lines so the paragraph
+				// chunkers can pack whole rows into chunks
+				out.WriteString("\n\n" + strings.Join(pairs, "; "))
+			}
+		}
+		if len(rows) == 1 {
+			out.WriteString("\n\n" + strings.Join(header, "; "))
+		}
+		spans = append(spans, PageSpan{Number: i + 1, Name: sheet.name, Start: start, End: out.Len()})
+	}
+
+	doc := newOfficeDocument(filePath, pkg, out.String())
+	doc.Pages = spans
+	doc.PageUnit = "sheet"
+	doc.Metadata["sheets"] = names
+	return doc, nil
+}
+
+type xlsxSheet struct {
+	name string
+	part string
+}
+
+// xlsxSheets lists the workbook's sheets in tab order
+func (p *ooxmlPackage) xlsxSheets() []xlsxSheet {
+	rels := p.relationships("xl/workbook.xml")
+	decoder, closer, err := p.open("xl/workbook.xml")
+	if err != nil {
+		return nil
+	}
+	defer closer.Close()
+
+	var sheets []xlsxSheet
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "sheet" {
+			continue
+		}
+		for _, a := range start.Attr {
+			if a.Name.Local == "id" && a.Name.Space != "" {
+				if part, ok := rels[a.Value]; ok && p.has(part) {
+					sheets = append(sheets, xlsxSheet{name: xmlAttr(start, "name"), part: part})
+				}
+			}
+		}
+	}
+	return sheets
+}
+
+// xlsxSharedStrings reads the workbook's shared string table
+func (p *ooxmlPackage) xlsxSharedStrings() ([]string, error) {
+	if !p.has("xl/sharedStrings.xml") {
+		return nil, nil
+	}
+	decoder, closer, err := p.open("xl/sharedStrings.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	var (
+		strs     []string
+		current  strings.Builder
+		inText   bool
+		phonetic int
+	)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "t":
+				inText = phonetic == 0
+			case "rPh":
+				phonetic++
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "rPh":
+				phonetic--
+			case "si":
+				strs = append(strs, current.String())
+			}
+		}
+	}
+	return strs, nil
+}
+
+// xlsxRows reads a worksheet's non-empty rows as cell text indexed by column
+func (p *ooxmlPackage) xlsxRows(part string, shared []string) ([][]string, error) {
+	decoder, closer, err := p.open(part)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	var (
+		rows     [][]string
+		row      []string
+		col      int
+		cellType string
+		value    strings.Builder
+		inValue  bool
+	)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = nil
+				col = 0
+			case "c":
+				if ref := xmlAttr(t, "r"); ref != "" {
+					col = columnIndex(ref)
+				}
+				cellType = xmlAttr(t, "t")
+				value.Reset()
+			case "v", "t":
+				inValue = true
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				text := strings.TrimSpace(value.String())
+				switch cellType {
+				case "s":
+					if i, err := strconv.Atoi(text); err == nil && i >= 0 && i < len(shared) {
+						text = strings.TrimSpace(shared[i])
+					}
+				case "b":
+					if text == "1" {
+						text = "TRUE"
+					} else if text == "0" {
+						text = "FALSE"
+					}
+				}
+				text = collapseSpace(text)
+				for len(row) <= col {
+					row = append(row, "")
+				}
+				row[col] = text
+				col++
+			case "row":
+				for _, v := range row {
+					if v != "" {
+						rows = append(rows, row)
+						break
+					}
+				}
+			}
+		}
+	}
+	return rows, nil
+}
+
+// columnIndex converts the letters of a cell reference such as "AB12" to a
+// 0-based column index
+func columnIndex(ref string) int {
+	n := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		n = n*26 + int(c-'A'+1)
+	}
+	return n - 1
+}
+
+// columnName converts a 0-based column index to its letters
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// writeBlockSeparator starts a new block with a blank line
+func writeBlockSeparator(out *strings.Builder) {
+	if out.Len() > 0 {
+		out.WriteString("\n\n")
+	}
+}
+
+// writePipeTable writes rows as pipe-separated lines, marking the first row
+// as a header when header is set
+func writePipeTable(out *strings.Builder, rows [][]string, header bool) {
+	for i, cells := range rows {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString("| " + strings.Join(cells, " | ") + " |")
+		if i == 0 && header && len(rows) > 1 {
+			out.WriteString("\n" + strings.Repeat("| --- ", len(cells)) + "|")
+		}
+	}
+}
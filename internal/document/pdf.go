@@ -0,0 +1,391 @@
+package document
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// maxFormDepth bounds recursion into nested form XObjects
+const maxFormDepth = 8
+
+// LoadPDF extracts the text of a PDF file into a document. Pages are joined
+// by blank lines and recorded in Document.Pages so chunks can cite them.
+func LoadPDF(filePath string) (*Document, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	file, err := parsePDF(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PDF %s: %w", filePath, err)
+	}
+	if file.encrypted() {
+		return nil, fmt.Errorf("PDF %s is encrypted; encrypted PDFs are not supported", filePath)
+	}
+
+	pageTexts, err := file.extractPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract text from %s: %w", filePath, err)
+	}
+
+	var content strings.Builder
+	pages := make([]PageSpan, 0, len(pageTexts))
+	for i, text := range pageTexts {
+		if text == "" {
+			continue
+		}
+		if content.Len() > 0 {
+			content.WriteString("\n\n")
+		}
+		start := content.Len()
+		content.WriteString(text)
+		pages = append(pages, PageSpan{Number: i + 1, Start: start, End: content.Len()})
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("PDF %s has no extractable text (it may be scanned images)", filePath)
+	}
+
+	doc := newFileDocument(filePath, data, content.String())
+	doc.Pages = pages
+	doc.Metadata["page_count"] = len(pageTexts)
+	if title := file.title(); title != "" {
+		doc.Metadata["title"] = title
+	}
+
+	return doc, nil
+}
+
+// trailer returns the value of key from the newest trailer that has it
+func (f *pdfFile) trailer(key pdfName) interface{} {
+	for i := len(f.trailers) - 1; i >= 0; i-- {
+		if v, ok := f.trailers[i][key]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (f *pdfFile) encrypted() bool {
+	return f.trailer("Encrypt") != nil
+}
+
+func (f *pdfFile) title() string {
+	info := f.dict(f.trailer("Info"))
+	if s, ok := f.resolve(info["Title"]).(pdfString); ok {
+		return strings.TrimSpace(decodeTextString(s))
+	}
+	return ""
+}
+
+// catalog finds the document catalog, falling back to scanning objects when
+// the trailer is missing or damaged
+func (f *pdfFile) catalog() pdfDict {
+	if root := f.dict(f.trailer("Root")); root != nil {
+		return root
+	}
+	for _, obj := range f.objects {
+		if dict, ok := obj.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			return dict
+		}
+	}
+	return nil
+}
+
+// pdfPage is a leaf of the page tree with its inherited resources
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages walks the page tree in document order
+func (f *pdfFile) pages() ([]pdfPage, error) {
+	catalog := f.catalog()
+	if catalog == nil {
+		return nil, fmt.Errorf("document catalog not found")
+	}
+	root := f.dict(catalog["Pages"])
+	if root == nil {
+		return nil, fmt.Errorf("page tree not found")
+	}
+
+	var pages []pdfPage
+	var walk func(node pdfDict, resources pdfDict, depth int)
+	walk = func(node pdfDict, resources pdfDict, depth int) {
+		// The depth limit also guards against cyclic Kids references
+		if node == nil || depth > 64 {
+			return
+		}
+		if r := f.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		kids, hasKids := f.resolve(node["Kids"]).(pdfArray)
+		if node["Type"] == pdfName("Page") || !hasKids {
+			pages = append(pages, pdfPage{dict: node, resources: resources})
+			return
+		}
+		for _, kid := range kids {
+			walk(f.dict(kid), resources, depth+1)
+		}
+	}
+	walk(root, nil, 0)
+
+	return pages, nil
+}
+
+// extractPages returns the text of every page in order
+func (f *pdfFile) extractPages() ([]string, error) {
+	pages, err := f.pages()
+	if err != nil {
+		return nil, err
+	}
+
+	fonts := make(map[interface{}]*pdfFont)
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		var content []byte
+		switch v := f.resolve(page.dict["Contents"]).(type) {
+		case *pdfStream:
+			content, _ = f.decodeStream(v)
+		case pdfArray:
+			for _, part := range v {
+				if stream, ok := f.resolve(part).(*pdfStream); ok {
+					if data, err := f.decodeStream(stream); err == nil {
+						content = append(content, data...)
+						content = append(content, '\n')
+					}
+				}
+			}
+		}
+
+		w := &pdfTextWriter{}
+		f.runContent(content, page.resources, fonts, w, 0)
+		texts[i] = w.text()
+	}
+	return texts, nil
+}
+
+// font returns the decoder for a font resource, caching by object
+func (f *pdfFile) font(resources pdfDict, name pdfName, cache map[interface{}]*pdfFont) *pdfFont {
+	fontRef := f.dict(resources["Font"])[name]
+	if fontRef == nil {
+		return nil
+	}
+	key := fontRef
+	if _, isRef := fontRef.(pdfRef); !isRef {
+		key = fmt.Sprintf("%p", f.dict(fontRef))
+	}
+	if font, ok := cache[key]; ok {
+		return font
+	}
+	fontDict := f.dict(fontRef)
+	if fontDict == nil {
+		return nil
+	}
+	font := f.loadPDFFont(fontDict)
+	cache[key] = font
+	return font
+}
+
+// runContent interprets the text operators of a content stream, recursing
+// into form XObjects
+func (f *pdfFile) runContent(content []byte, resources pdfDict, fonts map[interface{}]*pdfFont, w *pdfTextWriter, depth int) {
+	lex := &pdfLexer{data: content}
+	var operands []interface{}
+	var font *pdfFont
+
+	number := func(i int) float64 {
+		if i < len(operands) {
+			if v, ok := operands[i].(float64); ok {
+				return v
+			}
+		}
+		return 0
+	}
+
+	for {
+		tok, err := lex.next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			return // Keep what was extracted before the damage
+		}
+		op, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch op {
+		case "BI":
+			skipInlineImage(lex)
+		case "Tf":
+			if len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = f.font(resources, name, fonts)
+				}
+			}
+		case "Td", "TD":
+			w.move(number(0), number(1))
+		case "Tm":
+			w.setMatrix(number(4), number(5))
+		case "T*":
+			w.newline()
+		case "Tj":
+			if len(operands) >= 1 {
+				if s, ok := operands[0].(pdfString); ok {
+					w.write(font.decode(s))
+				}
+			}
+		case "'":
+			w.newline()
+			if len(operands) >= 1 {
+				if s, ok := operands[0].(pdfString); ok {
+					w.write(font.decode(s))
+				}
+			}
+		case "\"":
+			w.newline()
+			if len(operands) >= 3 {
+				if s, ok := operands[2].(pdfString); ok {
+					w.write(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				if arr, ok := operands[0].(pdfArray); ok {
+					for _, item := range arr {
+						switch v := item.(type) {
+						case pdfString:
+							w.write(font.decode(v))
+						case float64:
+							// Large negative adjustments (in thousandths of
+							// an em) are how many producers encode spaces
+							if v < -200 {
+								w.space()
+							}
+						}
+					}
+				}
+			}
+		case "Do":
+			if depth < maxFormDepth && len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					f.runForm(resources, name, fonts, w, depth)
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// runForm interprets a form XObject drawn by the Do operator
+func (f *pdfFile) runForm(resources pdfDict, name pdfName, fonts map[interface{}]*pdfFont, w *pdfTextWriter, depth int) {
+	stream, ok := f.resolve(f.dict(resources["XObject"])[name]).(*pdfStream)
+	if !ok || stream.dict["Subtype"] != pdfName("Form") {
+		return
+	}
+	data, err := f.decodeStream(stream)
+	if err != nil {
+		return
+	}
+	formResources := f.dict(stream.dict["Resources"])
+	if formResources == nil {
+		formResources = resources
+	}
+	f.runContent(data, formResources, fonts, w, depth+1)
+}
+
+// skipInlineImage moves past the binary data of an inline image (BI ... ID
+// data EI)
+func skipInlineImage(lex *pdfLexer) {
+	for {
+		tok, err := lex.next()
+		if err != nil {
+			return
+		}
+		if kw, ok := tok.(pdfKeyword); ok && kw == "ID" {
+			break
+		}
+	}
+	for i := lex.pos; i+2 <= len(lex.data); i++ {
+		if lex.data[i] == 'E' && lex.data[i+1] == 'I' &&
+			(i == 0 || isPDFWhite(lex.data[i-1])) &&
+			(i+2 == len(lex.data) || isPDFWhite(lex.data[i+2])) {
+			lex.pos = i + 2
+			return
+		}
+	}
+	lex.pos = len(lex.data)
+}
+
+// pdfTextWriter lays out shown text, turning vertical moves into line
+// breaks and horizontal gaps into spaces
+type pdfTextWriter struct {
+	out   strings.Builder
+	lineY float64
+	haveY bool
+}
+
+func (w *pdfTextWriter) write(s string) {
+	w.out.WriteString(s)
+}
+
+func (w *pdfTextWriter) lastByte() byte {
+	s := w.out.String()
+	if len(s) == 0 {
+		return '\n'
+	}
+	return s[len(s)-1]
+}
+
+func (w *pdfTextWriter) newline() {
+	if w.lastByte() != '\n' {
+		w.out.WriteByte('\n')
+	}
+}
+
+func (w *pdfTextWriter) space() {
+	if c := w.lastByte(); c != ' ' && c != '\n' {
+		w.out.WriteByte(' ')
+	}
+}
+
+// move handles a relative text position change
+func (w *pdfTextWriter) move(tx, ty float64) {
+	switch {
+	case math.Abs(ty) > 0.5:
+		w.newline()
+	case tx != 0:
+		w.space()
+	}
+	w.lineY += ty
+}
+
+// setMatrix handles an absolute text position change
+func (w *pdfTextWriter) setMatrix(x, y float64) {
+	if w.haveY && math.Abs(y-w.lineY) > 0.5 {
+		w.newline()
+	} else {
+		w.space()
+	}
+	w.lineY = y
+	w.haveY = true
+}
+
+var (
+	trailingSpace = regexp.MustCompile(`[ \t]+\n`)
+	extraNewlines = regexp.MustCompile(`\n{3,}`)
+)
+
+func (w *pdfTextWriter) text() string {
+	text := strings.ReplaceAll(w.out.String(), "\r", "\n")
+	text = trailingSpace.ReplaceAllString(text, "\n")
+	text = extraNewlines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,410 @@
+package document
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// pdfFont maps the character codes in a font's text strings to Unicode,
+// preferring the font's ToUnicode CMap and falling back to its encoding
+type pdfFont struct {
+	composite bool      // Type0 font with multi-byte codes
+	toUnicode *pdfCMap  // may be nil
+	encoding  [256]rune // code -> rune for simple fonts; 0 means unmapped
+}
+
+// loadPDFFont builds the decoder for a font dictionary
+func (f *pdfFile) loadPDFFont(fontDict pdfDict) *pdfFont {
+	font := &pdfFont{}
+	subtype := f.name(fontDict["Subtype"])
+	font.composite = subtype == "Type0"
+
+	if stream, ok := f.resolve(fontDict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := f.decodeStream(stream); err == nil {
+			font.toUnicode = parseCMap(data)
+		}
+	}
+
+	if font.composite {
+		return font
+	}
+
+	// TrueType fonts without an encoding are usually laid out like WinAnsi;
+	// Type1 fonts default to StandardEncoding
+	base := standardEncoding
+	if subtype == "TrueType" {
+		base = winAnsiEncoding
+	}
+
+	switch enc := f.resolve(fontDict["Encoding"]).(type) {
+	case pdfName:
+		if table, ok := namedEncoding(enc); ok {
+			base = table
+		}
+		font.encoding = base
+	case pdfDict:
+		if table, ok := namedEncoding(f.name(enc["BaseEncoding"])); ok {
+			base = table
+		}
+		font.encoding = base
+		code := 0
+		for _, item := range f.array(enc["Differences"]) {
+			switch v := f.resolve(item).(type) {
+			case float64:
+				code = int(v)
+			case pdfName:
+				if code >= 0 && code < 256 {
+					if r, ok := glyphNameToRune(string(v)); ok {
+						font.encoding[code] = r
+					}
+				}
+				code++
+			}
+		}
+	default:
+		font.encoding = base
+	}
+
+	return font
+}
+
+func namedEncoding(name pdfName) ([256]rune, bool) {
+	switch name {
+	case "WinAnsiEncoding":
+		return winAnsiEncoding, true
+	case "MacRomanEncoding":
+		return macRomanEncoding, true
+	case "StandardEncoding":
+		return standardEncoding, true
+	}
+	return [256]rune{}, false
+}
+
+// decode converts a shown string to text
+func (font *pdfFont) decode(s pdfString) string {
+	if font == nil {
+		return decodeTextString(s)
+	}
+
+	var out strings.Builder
+	b := []byte(s)
+
+	if font.composite {
+		for i := 0; i < len(b); {
+			n := 2
+			if font.toUnicode != nil {
+				n = font.toUnicode.codeLength(b[i:])
+			}
+			if i+n > len(b) {
+				break
+			}
+			if font.toUnicode != nil {
+				if text, ok := font.toUnicode.lookup(b[i : i+n]); ok {
+					out.WriteString(text)
+				}
+			}
+			i += n
+		}
+		return out.String()
+	}
+
+	for _, c := range b {
+		if font.toUnicode != nil {
+			if text, ok := font.toUnicode.lookup([]byte{c}); ok {
+				out.WriteString(text)
+				continue
+			}
+		}
+		if r := font.encoding[c]; r != 0 {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// pdfCMap is a parsed ToUnicode CMap
+type pdfCMap struct {
+	codespaces []cmapRange
+	chars      map[string]string
+	ranges     []cmapBFRange
+}
+
+type cmapRange struct {
+	lo, hi []byte
+}
+
+type cmapBFRange struct {
+	lo, hi uint32
+	size   int
+	start  []uint16 // destination of lo, incremented across the range
+	list   []string // explicit destinations, when given as an array
+}
+
+// parseCMap reads the codespace, bfchar and bfrange sections of a CMap
+func parseCMap(data []byte) *pdfCMap {
+	cmap := &pdfCMap{chars: make(map[string]string)}
+	lex := &pdfLexer{data: data}
+
+	var operands []interface{}
+	for {
+		tok, err := lex.next()
+		if err != nil {
+			break
+		}
+		kw, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(lo) == len(hi) && len(lo) > 0 {
+					cmap.codespaces = append(cmap.codespaces, cmapRange{lo: []byte(lo), hi: []byte(hi)})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap.chars[string(src)] = utf16BEString([]byte(dst))
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+					continue
+				}
+				r := cmapBFRange{lo: bytesToUint(lo), hi: bytesToUint(hi), size: len(lo)}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					r.start = utf16Units([]byte(dst))
+				case pdfArray:
+					for _, item := range dst {
+						if s, ok := item.(pdfString); ok {
+							r.list = append(r.list, utf16BEString([]byte(s)))
+						}
+					}
+				default:
+					continue
+				}
+				cmap.ranges = append(cmap.ranges, r)
+			}
+		}
+
+		if strings.HasPrefix(string(kw), "end") || strings.HasPrefix(string(kw), "begin") {
+			operands = operands[:0]
+		}
+	}
+
+	return cmap
+}
+
+// codeLength returns how many bytes the code at the start of b occupies
+func (c *pdfCMap) codeLength(b []byte) int {
+	for n := 1; n <= 4 && n <= len(b); n++ {
+		for _, cs := range c.codespaces {
+			if len(cs.lo) != n {
+				continue
+			}
+			inRange := true
+			for i := 0; i < n; i++ {
+				if b[i] < cs.lo[i] || b[i] > cs.hi[i] {
+					inRange = false
+					break
+				}
+			}
+			if inRange {
+				return n
+			}
+		}
+	}
+	if len(c.codespaces) == 0 {
+		return 2
+	}
+	return 1
+}
+
+// lookup maps a character code to its Unicode text
+func (c *pdfCMap) lookup(code []byte) (string, bool) {
+	if text, ok := c.chars[string(code)]; ok {
+		return text, true
+	}
+	if len(code) > 4 {
+		return "", false
+	}
+	v := bytesToUint(pdfString(code))
+	for _, r := range c.ranges {
+		if r.size != len(code) || v < r.lo || v > r.hi {
+			continue
+		}
+		offset := int(v - r.lo)
+		if r.list != nil {
+			if offset < len(r.list) {
+				return r.list[offset], true
+			}
+			return "", false
+		}
+		if len(r.start) == 0 {
+			return "", false
+		}
+		units := append([]uint16(nil), r.start...)
+		units[len(units)-1] += uint16(offset)
+		return string(utf16.Decode(units)), true
+	}
+	return "", false
+}
+
+func bytesToUint(b pdfString) uint32 {
+	var v uint32
+	for i := 0; i < len(b); i++ {
+		v = v<<8 | uint32(b[i])
+	}
+	return v
+}
+
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	if len(b)%2 == 1 {
+		units = append(units, uint16(b[len(b)-1]))
+	}
+	return units
+}
+
+func utf16BEString(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
+
+// glyphNameToRune resolves an Adobe glyph name as used in /Differences
+func glyphNameToRune(name string) (rune, bool) {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i] // Drop suffixes such as ".sc" or ".alt"
+	}
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	if len(name) == 1 && ((name[0] >= 'a' && name[0] <= 'z') || (name[0] >= 'A' && name[0] <= 'Z')) {
+		return rune(name[0]), true
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 {
+		if v, err := strconv.ParseUint(name[3:7], 16, 32); err == nil {
+			return rune(v), true
+		}
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return rune(v), true
+		}
+	}
+	return 0, false
+}
+
+var glyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$',
+	"percent": '%', "ampersand": '&', "quotesingle": '\'', "parenleft": '(', "parenright": ')',
+	"asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-', "period": '.', "slash": '/',
+	"zero": '0', "one": '1', "two": '2', "three": '3', "four": '4',
+	"five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9',
+	"colon": ':', "semicolon": ';', "less": '<', "equal": '=', "greater": '>', "question": '?',
+	"at": '@', "bracketleft": '[', "backslash": '\\', "bracketright": ']', "asciicircum": '^',
+	"underscore": '_', "grave": '`', "braceleft": '{', "bar": '|', "braceright": '}', "asciitilde": '~',
+	"quoteleft": '‘', "quoteright": '’', "quotedblleft": '“', "quotedblright": '”',
+	"quotesinglbase": '‚', "quotedblbase": '„', "guilsinglleft": '‹', "guilsinglright": '›',
+	"guillemotleft": '«', "guillemotright": '»', "endash": '–', "emdash": '—', "minus": '−',
+	"bullet": '•', "ellipsis": '…', "dagger": '†', "daggerdbl": '‡', "perthousand": '‰',
+	"trademark": '™', "copyright": '©', "registered": '®', "degree": '°', "section": '§',
+	"paragraph": '¶', "periodcentered": '·', "exclamdown": '¡', "questiondown": '¿',
+	"cent": '¢', "sterling": '£', "yen": '¥', "Euro": '€', "florin": 'ƒ', "currency": '¤',
+	"brokenbar": '¦', "dieresis": '¨', "macron": '¯', "acute": '´', "cedilla": '¸',
+	"circumflex": 'ˆ', "tilde": '˜', "ring": '˚', "caron": 'ˇ', "breve": '˘', "dotaccent": '˙',
+	"hungarumlaut": '˝', "ogonek": '˛', "ordfeminine": 'ª', "ordmasculine": 'º',
+	"onesuperior": '¹', "twosuperior": '²', "threesuperior": '³', "onequarter": '¼',
+	"onehalf": '½', "threequarters": '¾', "multiply": '×', "divide": '÷', "plusminus": '±',
+	"logicalnot": '¬', "mu": 'µ', "nbspace": ' ', "sfthyphen": '­', "fraction": '⁄',
+	"fi": 'ﬁ', "fl": 'ﬂ', "ff": 'ﬀ', "ffi": 'ﬃ', "ffl": 'ﬄ', "dotlessi": 'ı',
+	"AE": 'Æ', "ae": 'æ', "OE": 'Œ', "oe": 'œ', "Oslash": 'Ø', "oslash": 'ø',
+	"germandbls": 'ß', "Lslash": 'Ł', "lslash": 'ł', "Eth": 'Ð', "eth": 'ð', "Thorn": 'Þ', "thorn": 'þ',
+	"Agrave": 'À', "Aacute": 'Á', "Acircumflex": 'Â', "Atilde": 'Ã', "Adieresis": 'Ä', "Aring": 'Å',
+	"Ccedilla": 'Ç', "Egrave": 'È', "Eacute": 'É', "Ecircumflex": 'Ê', "Edieresis": 'Ë',
+	"Igrave": 'Ì', "Iacute": 'Í', "Icircumflex": 'Î', "Idieresis": 'Ï', "Ntilde": 'Ñ',
+	"Ograve": 'Ò', "Oacute": 'Ó', "Ocircumflex": 'Ô', "Otilde": 'Õ', "Odieresis": 'Ö',
+	"Ugrave": 'Ù', "Uacute": 'Ú', "Ucircumflex": 'Û', "Udieresis": 'Ü', "Yacute": 'Ý', "Ydieresis": 'Ÿ',
+	"Scaron": 'Š', "scaron": 'š', "Zcaron": 'Ž', "zcaron": 'ž',
+	"agrave": 'à', "aacute": 'á', "acircumflex": 'â', "atilde": 'ã', "adieresis": 'ä', "aring": 'å',
+	"ccedilla": 'ç', "egrave": 'è', "eacute": 'é', "ecircumflex": 'ê', "edieresis": 'ë',
+	"igrave": 'ì', "iacute": 'í', "icircumflex": 'î', "idieresis": 'ï', "ntilde": 'ñ',
+	"ograve": 'ò', "oacute": 'ó', "ocircumflex": 'ô', "otilde": 'õ', "odieresis": 'ö',
+	"ugrave": 'ù', "uacute": 'ú', "ucircumflex": 'û', "udieresis": 'ü', "yacute": 'ý', "ydieresis": 'ÿ',
+}
+
+// asciiEncoding fills the printable ASCII range shared by the base encodings
+func asciiEncoding() [256]rune {
+	var table [256]rune
+	for c := 0x20; c < 0x7F; c++ {
+		table[c] = rune(c)
+	}
+	table['\t'], table['\n'], table['\r'] = '\t', '\n', '\r'
+	return table
+}
+
+var winAnsiEncoding = func() [256]rune {
+	table := asciiEncoding()
+	high := [32]rune{
+		'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+		0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+	}
+	for i, r := range high {
+		table[0x80+i] = r
+	}
+	for c := 0xA0; c <= 0xFF; c++ {
+		table[c] = rune(c)
+	}
+	return table
+}()
+
+var macRomanEncoding = func() [256]rune {
+	table := asciiEncoding()
+	high := []rune(
+		"ÄÅÇÉÑÖÜáàâäãåçéè" +
+			"êëíìîïñóòôöõúùûü" +
+			"†°¢£§•¶ß®©™´¨≠ÆØ" +
+			"∞±≤≥¥µ∂∑∏π∫ªºΩæø" +
+			"¿¡¬√ƒ≈∆«»… ÀÃÕŒœ" +
+			"–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ" +
+			"‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔ" +
+			"\uF8FFÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ")
+	for i, r := range high {
+		table[0x80+i] = r
+	}
+	return table
+}()
+
+var standardEncoding = func() [256]rune {
+	table := asciiEncoding()
+	table['\''] = '’'
+	table['`'] = '‘'
+	high := map[int]rune{
+		0xA1: '¡', 0xA2: '¢', 0xA3: '£', 0xA4: '⁄', 0xA5: '¥', 0xA6: 'ƒ', 0xA7: '§',
+		0xA8: '¤', 0xA9: '\'', 0xAA: '“', 0xAB: '«', 0xAC: '‹', 0xAD: '›', 0xAE: 'ﬁ', 0xAF: 'ﬂ',
+		0xB1: '–', 0xB2: '†', 0xB3: '‡', 0xB4: '·', 0xB6: '¶', 0xB7: '•', 0xB8: '‚',
+		0xB9: '„', 0xBA: '”', 0xBB: '»', 0xBC: '…', 0xBD: '‰', 0xBF: '¿',
+		0xC1: '`', 0xC2: '´', 0xC3: 'ˆ', 0xC4: '˜', 0xC5: '¯', 0xC6: '˘', 0xC7: '˙',
+		0xC8: '¨', 0xCA: '˚', 0xCB: '¸', 0xCD: '˝', 0xCE: '˛', 0xCF: 'ˇ', 0xD0: '—',
+		0xE1: 'Æ', 0xE3: 'ª', 0xE8: 'Ł', 0xE9: 'Ø', 0xEA: 'Œ', 0xEB: 'º',
+		0xF1: 'æ', 0xF5: 'ı', 0xF8: 'ł', 0xF9: 'ø', 0xFA: 'œ', 0xFB: 'ß',
+	}
+	for c, r := range high {
+		table[c] = r
+	}
+	return table
+}()
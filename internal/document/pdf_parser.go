@@ -0,0 +1,795 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf16"
+)
+
+// This file implements the subset of the PDF object syntax needed to pull
+// text out of a file: a lexer for objects and content streams, an object
+// table built by scanning the file (so damaged cross-reference tables don't
+// matter), object streams, and the common stream filters.
+
+type pdfName string
+type pdfKeyword string
+type pdfString string
+
+type pdfRef struct {
+	num, gen int
+}
+
+type pdfDict map[pdfName]interface{}
+type pdfArray []interface{}
+
+type pdfStream struct {
+	dict pdfDict
+	raw  []byte
+}
+
+// pdfLexer tokenizes PDF object and content-stream syntax
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFWhite(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isPDFDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFWhite(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+}
+
+// next returns the next value or keyword, or io.EOF at the end of input
+func (l *pdfLexer) next() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		return l.readName(), nil
+	case c == '(':
+		return l.readLiteralString()
+	case c == '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return l.readDict()
+		}
+		return l.readHexString()
+	case c == '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), nil
+		}
+		l.pos++
+		return pdfKeyword(">"), nil
+	case c == '[':
+		l.pos++
+		return l.readArray()
+	case c == ']' || c == '{' || c == '}' || c == ')':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.readNumberOrRef()
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFWhite(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+func (l *pdfLexer) readName() pdfName {
+	l.pos++ // skip '/'
+	var name []byte
+	for l.pos < len(l.data) && !isPDFWhite(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				name = append(name, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		name = append(name, c)
+		l.pos++
+	}
+	return pdfName(name)
+}
+
+func (l *pdfLexer) readLiteralString() (interface{}, error) {
+	l.pos++ // skip '('
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(out), nil
+			}
+			out = append(out, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				break
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				// Line continuation
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return nil, fmt.Errorf("unterminated string")
+}
+
+func (l *pdfLexer) readHexString() (interface{}, error) {
+	l.pos++ // skip '<'
+	var digits []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			out := make([]byte, len(digits)/2)
+			for i := range out {
+				v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+				out[i] = byte(v)
+			}
+			return pdfString(out), nil
+		}
+		if isHexDigit(c) {
+			digits = append(digits, c)
+		}
+	}
+	return nil, fmt.Errorf("unterminated hex string")
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func (l *pdfLexer) readArray() (interface{}, error) {
+	var arr pdfArray
+	for {
+		v, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if kw, ok := v.(pdfKeyword); ok && kw == "]" {
+			return arr, nil
+		}
+		arr = append(arr, v)
+	}
+}
+
+func (l *pdfLexer) readDict() (interface{}, error) {
+	dict := make(pdfDict)
+	for {
+		k, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if kw, ok := k.(pdfKeyword); ok && kw == ">>" {
+			return dict, nil
+		}
+		key, ok := k.(pdfName)
+		if !ok {
+			continue // Tolerate junk keys in damaged files
+		}
+		v, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if kw, ok := v.(pdfKeyword); ok && kw == ">>" {
+			return dict, nil
+		}
+		dict[key] = v
+	}
+}
+
+func (l *pdfLexer) readNumber() (float64, bool) {
+	start := l.pos
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if !(c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9')) {
+			break
+		}
+		l.pos++
+	}
+	v, err := strconv.ParseFloat(string(l.data[start:l.pos]), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// readNumberOrRef reads a number, or an indirect reference "num gen R"
+func (l *pdfLexer) readNumberOrRef() (interface{}, error) {
+	start := l.pos
+	v, ok := l.readNumber()
+	if !ok {
+		return pdfKeyword(l.data[start:l.pos]), nil
+	}
+	if v != float64(int(v)) || v < 0 {
+		return v, nil
+	}
+
+	// Look ahead for "gen R"
+	save := l.pos
+	l.skipSpace()
+	if l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+		gen, ok := l.readNumber()
+		if ok {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == 'R' &&
+				(l.pos+1 == len(l.data) || isPDFWhite(l.data[l.pos+1]) || isPDFDelim(l.data[l.pos+1])) {
+				l.pos++
+				return pdfRef{num: int(v), gen: int(gen)}, nil
+			}
+		}
+	}
+	l.pos = save
+	return v, nil
+}
+
+// pdfFile is a parsed PDF: its objects keyed by object number plus the
+// trailer dictionaries found in the file
+type pdfFile struct {
+	objects  map[int]interface{}
+	trailers []pdfDict
+}
+
+var pdfObjHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// parsePDF scans data for "n g obj" definitions, later definitions replacing
+// earlier ones as incremental updates do, then expands object streams
+func parsePDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	file := &pdfFile{objects: make(map[int]interface{})}
+
+	pos := 0
+	for pos < len(data) {
+		loc := pdfObjHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		lex := &pdfLexer{data: data, pos: pos + loc[1]}
+		value, err := lex.next()
+		if err != nil {
+			pos += loc[1]
+			continue
+		}
+		if dict, ok := value.(pdfDict); ok {
+			if stream, ok := readStreamBody(lex, dict); ok {
+				value = stream
+			}
+		}
+		file.objects[num] = value
+		pos = lex.pos
+	}
+
+	// Classic trailers
+	for idx := 0; ; {
+		i := bytes.Index(data[idx:], []byte("trailer"))
+		if i < 0 {
+			break
+		}
+		lex := &pdfLexer{data: data, pos: idx + i + len("trailer")}
+		if v, err := lex.next(); err == nil {
+			if dict, ok := v.(pdfDict); ok {
+				file.trailers = append(file.trailers, dict)
+			}
+		}
+		idx += i + len("trailer")
+	}
+
+	// Cross-reference streams double as trailers; object streams hold
+	// compressed objects
+	for _, obj := range file.objects {
+		stream, ok := obj.(*pdfStream)
+		if !ok {
+			continue
+		}
+		switch stream.dict["Type"] {
+		case pdfName("XRef"):
+			file.trailers = append(file.trailers, stream.dict)
+		case pdfName("ObjStm"):
+			file.expandObjectStream(stream)
+		}
+	}
+
+	if len(file.objects) == 0 {
+		return nil, fmt.Errorf("no objects found")
+	}
+	return file, nil
+}
+
+// readStreamBody reads the stream data following a dictionary, if any
+func readStreamBody(lex *pdfLexer, dict pdfDict) (*pdfStream, bool) {
+	save := lex.pos
+	lex.skipSpace()
+	if !bytes.HasPrefix(lex.data[lex.pos:], []byte("stream")) {
+		lex.pos = save
+		return nil, false
+	}
+	lex.pos += len("stream")
+	if lex.pos < len(lex.data) && lex.data[lex.pos] == '\r' {
+		lex.pos++
+	}
+	if lex.pos < len(lex.data) && lex.data[lex.pos] == '\n' {
+		lex.pos++
+	}
+	start := lex.pos
+
+	// Trust a direct /Length only if "endstream" follows it
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end <= len(lex.data) {
+			rest := bytes.TrimLeft(lex.data[end:min(end+32, len(lex.data))], "\r\n \t")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				lex.pos = end
+				lex.skipSpace()
+				lex.pos += len("endstream")
+				return &pdfStream{dict: dict, raw: lex.data[start:end]}, true
+			}
+		}
+	}
+
+	i := bytes.Index(lex.data[start:], []byte("endstream"))
+	if i < 0 {
+		lex.pos = len(lex.data)
+		return &pdfStream{dict: dict, raw: lex.data[start:]}, true
+	}
+	end := start + i
+	lex.pos = end + len("endstream")
+	raw := bytes.TrimSuffix(lex.data[start:end], []byte("\n"))
+	raw = bytes.TrimSuffix(raw, []byte("\r"))
+	return &pdfStream{dict: dict, raw: raw}, true
+}
+
+// expandObjectStream adds the objects packed in an object stream, without
+// overriding objects defined directly in the file
+func (f *pdfFile) expandObjectStream(stream *pdfStream) {
+	data, err := f.decodeStream(stream)
+	if err != nil {
+		return
+	}
+	n, _ := f.resolve(stream.dict["N"]).(float64)
+	first, _ := f.resolve(stream.dict["First"]).(float64)
+
+	header := &pdfLexer{data: data}
+	for i := 0; i < int(n); i++ {
+		numValue, err1 := header.next()
+		offsetValue, err2 := header.next()
+		if err1 != nil || err2 != nil {
+			return
+		}
+		num, ok1 := numValue.(float64)
+		offset, ok2 := offsetValue.(float64)
+		if !ok1 || !ok2 {
+			return
+		}
+		if _, exists := f.objects[int(num)]; exists {
+			continue
+		}
+		body := &pdfLexer{data: data, pos: int(first) + int(offset)}
+		if body.pos >= len(data) {
+			continue
+		}
+		if v, err := body.next(); err == nil {
+			f.objects[int(num)] = v
+		}
+	}
+}
+
+// resolve follows indirect references
+func (f *pdfFile) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.num]
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(v interface{}) pdfDict {
+	switch d := f.resolve(v).(type) {
+	case pdfDict:
+		return d
+	case *pdfStream:
+		return d.dict
+	}
+	return nil
+}
+
+func (f *pdfFile) array(v interface{}) pdfArray {
+	a, _ := f.resolve(v).(pdfArray)
+	return a
+}
+
+func (f *pdfFile) name(v interface{}) pdfName {
+	n, _ := f.resolve(v).(pdfName)
+	return n
+}
+
+// decodeStream applies the stream's filters in order
+func (f *pdfFile) decodeStream(stream *pdfStream) ([]byte, error) {
+	data := stream.raw
+
+	var filters pdfArray
+	switch v := f.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = pdfArray{v}
+	case pdfArray:
+		filters = v
+	}
+
+	var parms pdfArray
+	switch v := f.resolve(stream.dict["DecodeParms"]).(type) {
+	case pdfDict:
+		parms = pdfArray{v}
+	case pdfArray:
+		parms = v
+	}
+
+	for i, filterValue := range filters {
+		var parm pdfDict
+		if i < len(parms) {
+			parm = f.dict(parms[i])
+		}
+
+		var err error
+		switch f.name(filterValue) {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+			if err == nil {
+				data, err = f.applyPredictor(data, parm)
+			}
+		case "LZWDecode", "LZW":
+			earlyChange := true
+			if v, ok := f.resolve(parm["EarlyChange"]).(float64); ok && v == 0 {
+				earlyChange = false
+			}
+			data, err = lzwDecode(data, earlyChange)
+			if err == nil {
+				data, err = f.applyPredictor(data, parm)
+			}
+		case "ASCIIHexDecode", "AHx":
+			data, err = asciiHexDecode(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		case "RunLengthDecode", "RL":
+			data = runLengthDecode(data)
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", filterValue)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// inflate decompresses zlib data, keeping whatever was recovered from a
+// truncated or slightly corrupt stream
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(r)
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// applyPredictor reverses PNG (10-15) and TIFF (2) predictors
+func (f *pdfFile) applyPredictor(data []byte, parm pdfDict) ([]byte, error) {
+	if parm == nil {
+		return data, nil
+	}
+	predictor, _ := f.resolve(parm["Predictor"]).(float64)
+	if predictor < 2 {
+		return data, nil
+	}
+
+	colors, bits, columns := 1.0, 8.0, 1.0
+	if v, ok := f.resolve(parm["Colors"]).(float64); ok {
+		colors = v
+	}
+	if v, ok := f.resolve(parm["BitsPerComponent"]).(float64); ok {
+		bits = v
+	}
+	if v, ok := f.resolve(parm["Columns"]).(float64); ok {
+		columns = v
+	}
+	bpp := int((colors*bits + 7) / 8)
+	rowLen := int((colors*bits*columns + 7) / 8)
+
+	if predictor == 2 {
+		if bits != 8 {
+			return data, nil
+		}
+		out := append([]byte(nil), data...)
+		for row := 0; row*rowLen < len(out); row++ {
+			line := out[row*rowLen : min((row+1)*rowLen, len(out))]
+			for i := bpp; i < len(line); i++ {
+				line[i] += line[i-bpp]
+			}
+		}
+		return out, nil
+	}
+
+	var out []byte
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+1+rowLen <= len(data); pos += rowLen + 1 {
+		filterType := data[pos]
+		row := append([]byte(nil), data[pos+1:pos+1+rowLen]...)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch filterType {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func asciiHexDecode(data []byte) ([]byte, error) {
+	var digits []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isHexDigit(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = byte(v)
+	}
+	return out, nil
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	return io.ReadAll(ascii85.NewDecoder(bytes.NewReader(data)))
+}
+
+func runLengthDecode(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := int(data[i])
+		i++
+		switch {
+		case n == 128:
+			return out
+		case n < 128:
+			end := min(i+n+1, len(data))
+			out = append(out, data[i:end]...)
+			i = end
+		default:
+			if i < len(data) {
+				out = append(out, bytes.Repeat(data[i:i+1], 257-n)...)
+			}
+			i++
+		}
+	}
+	return out
+}
+
+// lzwDecode implements the PDF variant of LZW, whose code width grows one
+// code earlier than compress/lzw expects when EarlyChange is set
+func lzwDecode(data []byte, earlyChange bool) ([]byte, error) {
+	const clearCode, eodCode = 256, 257
+
+	var out []byte
+	table := make([][]byte, 258, 4096)
+	reset := func() {
+		table = table[:258]
+		for i := 0; i < 256; i++ {
+			table[i] = []byte{byte(i)}
+		}
+	}
+	reset()
+
+	width := 9
+	var bitBuf uint32
+	bitCount := 0
+	var prev []byte
+	early := 0
+	if earlyChange {
+		early = 1
+	}
+
+	for _, b := range data {
+		bitBuf = bitBuf<<8 | uint32(b)
+		bitCount += 8
+		for bitCount >= width {
+			code := int(bitBuf>>(bitCount-width)) & (1<<width - 1)
+			bitCount -= width
+
+			switch {
+			case code == clearCode:
+				reset()
+				width = 9
+				prev = nil
+				continue
+			case code == eodCode:
+				return out, nil
+			}
+
+			var entry []byte
+			switch {
+			case code < len(table):
+				entry = table[code]
+			case code == len(table) && prev != nil:
+				entry = append(append([]byte(nil), prev...), prev[0])
+			default:
+				return out, fmt.Errorf("invalid LZW code %d", code)
+			}
+			out = append(out, entry...)
+
+			if prev != nil && len(table) < 4096 {
+				table = append(table, append(append([]byte(nil), prev...), entry[0]))
+			}
+			prev = entry
+
+			if len(table)+early >= 1<<width && width < 12 {
+				width++
+			}
+		}
+	}
+	return out, nil
+}
+
+// decodeTextString decodes a PDF text string (UTF-16BE with BOM or
+// PDFDocEncoding, which matches Latin-1 for printable text)
+func decodeTextString(s pdfString) string {
+	b := []byte(s)
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		units := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = winAnsiEncoding[c]
+		if runes[i] == 0 {
+			runes[i] = rune(c)
+		}
+	}
+	return string(runes)
+}
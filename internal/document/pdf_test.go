@@ -0,0 +1,122 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildPDF returns a PDF with one page per text, each shown with a Type 1
+// font in a content stream, optionally Flate-compressed
+func buildPDF(texts []string, compress bool) []byte {
+	var objects []string
+	kids := make([]string, len(texts))
+	for i := range texts {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(texts)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	)
+	for i, text := range texts {
+		content := []byte(fmt.Sprintf("BT /F1 12 Tf 72 712 Td (%s) Tj ET", text))
+		filter := ""
+		if compress {
+			var b bytes.Buffer
+			w := zlib.NewWriter(&b)
+			w.Write(content)
+			w.Close()
+			content, filter = b.Bytes(), " /Filter /FlateDecode"
+		}
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d%s >>\nstream\n%s\nendstream", len(content), filter, content),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+func writeTemp(t testing.TB, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPDF(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			path := writeTemp(t, "test.pdf", buildPDF([]string{"First page text", "Second page text"}, compress))
+
+			doc, err := LoadPDF(path)
+			if err != nil {
+				t.Fatalf("LoadPDF: %v", err)
+			}
+			if len(doc.Pages) != 2 {
+				t.Fatalf("got %d pages, want 2", len(doc.Pages))
+			}
+			for i, want := range []string{"First page text", "Second page text"} {
+				span := doc.Pages[i]
+				if got := strings.TrimSpace(doc.Content[span.Start:span.End]); got != want {
+					t.Errorf("page %d: got %q, want %q", span.Number, got, want)
+				}
+			}
+			if doc.Metadata["page_count"] != 2 {
+				t.Errorf("page_count = %v, want 2", doc.Metadata["page_count"])
+			}
+		})
+	}
+}
+
+func TestLoadPDFRejectsOtherFiles(t *testing.T) {
+	path := writeTemp(t, "test.pdf", []byte("just some text"))
+	if _, err := LoadPDF(path); err == nil {
+		t.Error("expected an error for a file that isn't a PDF")
+	}
+}
+
+// FuzzLoadPDF checks that malformed PDFs return errors rather than panic or
+// hang
+func FuzzLoadPDF(f *testing.F) {
+	f.Add(buildPDF([]string{"Hello (nested) world"}, false))
+	f.Add(buildPDF([]string{"Compressed", "pages"}, true))
+	f.Add([]byte("%PDF-1.7\n1 0 obj\n<< /Type /ObjStm /N 1 /First 4 /Length 10 >>\nstream\n2 0 << >>\nendstream\nendobj\n"))
+	f.Add([]byte("%PDF-1.4\n1 0 obj << /Length 99999 >> stream\nBT (unterminated"))
+
+	path := filepath.Join(f.TempDir(), "fuzz.pdf")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		doc, err := LoadPDF(path)
+		if err != nil {
+			return
+		}
+		for _, span := range doc.Pages {
+			if span.Start < 0 || span.Start > span.End || span.End > len(doc.Content) {
+				t.Fatalf("page %d span [%d, %d) outside content of length %d", span.Number, span.Start, span.End, len(doc.Content))
+			}
+		}
+	})
+}
@@ -3,10 +3,8 @@ package document
 import (
 	"crypto/md5"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
-	"unicode/utf8"
 )
 
 // Document represents a loaded document
@@ -14,45 +12,50 @@ type Document struct {
 	ID       string                 `json:"id"`
 	Content  string                 `json:"content"`
 	Metadata map[string]interface{} `json:"metadata"`
+	// Pages maps content offsets to the pages, slides or sheets of formats
+	// that have them; it is empty for plain text
+	Pages []PageSpan `json:"pages,omitempty"`
+	// PageUnit names what Pages counts ("page", "slide" or "sheet") and is
+	// the metadata key chunks cite them under; empty means "page"
+	PageUnit string `json:"page_unit,omitempty"`
 }
 
-// Chunk represents a chunk of a document
-type Chunk struct {
-	ID       string                 `json:"id"`
-	Content  string                 `json:"content"`
-	Metadata map[string]interface{} `json:"metadata"`
+// PageSpan records where one page of the source file lies in Document.Content
+type PageSpan struct {
+	Number int    `json:"number"`         // 1-based page number
+	Name   string `json:"name,omitempty"` // optional label, such as a sheet name
+	Start  int    `json:"start"`          // byte offset of the page's first character
+	End    int    `json:"end"`            // byte offset just past the page's last character
 }
 
-// LoadFromFile loads a document from a file
-func LoadFromFile(filePath string) (*Document, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
-	}
+// pageUnits are the metadata keys annotatePages may record, in the order
+// PageLabel looks for them
+var pageUnits = []string{"page", "slide", "sheet"}
 
-	// Validate UTF-8 encoding
-	if !utf8.Valid(content) {
-		return nil, fmt.Errorf("file %s contains invalid UTF-8", filePath)
-	}
-
-	// Generate document ID based on file path and content
-	hasher := md5.New()
-	hasher.Write([]byte(filePath))
-	hasher.Write(content)
-	docID := fmt.Sprintf("%x", hasher.Sum(nil))
-
-	doc := &Document{
-		ID:      docID,
-		Content: string(content),
-		Metadata: map[string]interface{}{
-			"file":      filePath,
-			"filename":  filepath.Base(filePath),
-			"extension": filepath.Ext(filePath),
-			"size":      len(content),
-		},
+// PageLabel formats the location stored in chunk metadata, such as "page 4",
+// "slides 2-3" or "sheet Budget", or returns "" for chunks without one
+func PageLabel(metadata map[string]interface{}) string {
+	for _, unit := range pageUnits {
+		number, ok := metadata[unit]
+		if !ok {
+			continue
+		}
+		if name, ok := metadata[unit+"_name"]; ok {
+			return fmt.Sprintf("%s %v", unit, name)
+		}
+		if last, ok := metadata[unit+"_end"]; ok {
+			return fmt.Sprintf("%ss %v-%v", unit, number, last)
+		}
+		return fmt.Sprintf("%s %v", unit, number)
 	}
+	return ""
+}
 
-	return doc, nil
+// Chunk represents a chunk of a document
+type Chunk struct {
+	ID       string                 `json:"id"`
+	Content  string                 `json:"content"`
+	Metadata map[string]interface{} `json:"metadata"`
 }
 
 // LoadFromString creates a document from a string
@@ -108,14 +111,12 @@ func ChunkDocument(doc *Document, chunkSize int, overlap int) []*Chunk {
 		}
 
 		// Create chunk metadata
-		chunkMetadata := make(map[string]interface{})
-		for k, v := range doc.Metadata {
-			chunkMetadata[k] = v
-		}
+		chunkMetadata := newChunkMetadata(doc)
 		chunkMetadata["chunk_index"] = chunkIndex
 		chunkMetadata["chunk_start"] = start
 		chunkMetadata["chunk_end"] = end
 		chunkMetadata["parent_id"] = doc.ID
+		annotatePages(doc, chunkMetadata, start, end)
 
 		chunk := &Chunk{
 			ID:       fmt.Sprintf("%s_chunk_%d", doc.ID, chunkIndex),
@@ -165,10 +166,7 @@ func ChunkByLines(doc *Document, maxLines int, overlap int) []*Chunk {
 		}
 
 		// Create chunk metadata
-		chunkMetadata := make(map[string]interface{})
-		for k, v := range doc.Metadata {
-			chunkMetadata[k] = v
-		}
+		chunkMetadata := newChunkMetadata(doc)
 		chunkMetadata["chunk_index"] = chunkIndex
 		chunkMetadata["line_start"] = start + 1 // 1-based line numbers
 		chunkMetadata["line_end"] = end
@@ -182,6 +180,9 @@ func ChunkByLines(doc *Document, maxLines int, overlap int) []*Chunk {
 
 		chunks = append(chunks, chunk)
 		chunkIndex++
+		if end == len(lines) {
+			break
+		}
 
 		// Move start position, accounting for overlap
 		start = end - overlap
@@ -196,57 +197,25 @@ func ChunkByLines(doc *Document, maxLines int, overlap int) []*Chunk {
 	return chunks
 }
 
-// GetFileType determines the type of file based on extension
+// GetFileType determines the type of file based on extension: the language
+// for source files, otherwise the name of the registered loader
 func GetFileType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".md", ".markdown":
-		return "markdown"
-	case ".txt":
-		return "text"
-	case ".go":
-		return "go"
-	case ".py":
-		return "python"
-	case ".js", ".jsx":
-		return "javascript"
-	case ".ts", ".tsx":
-		return "typescript"
-	case ".java":
-		return "java"
-	case ".cpp", ".cc", ".cxx", ".c++":
-		return "cpp"
-	case ".c":
-		return "c"
-	case ".h", ".hpp":
-		return "header"
-	case ".rs":
-		return "rust"
-	case ".rb":
-		return "ruby"
-	case ".php":
-		return "php"
-	case ".sh", ".bash":
-		return "shell"
-	case ".sql":
-		return "sql"
-	case ".json":
-		return "json"
-	case ".yaml", ".yml":
-		return "yaml"
-	case ".xml":
-		return "xml"
-	case ".html", ".htm":
-		return "html"
-	case ".css":
-		return "css"
-	default:
-		return "text"
+	if language, ok := codeLanguages[ext]; ok {
+		return language
+	}
+	if loader, ok := LoaderFor(filename); ok {
+		return loader.Name()
 	}
+	return "text"
 }
 
 // ChunkSemanticDocument splits a document using semantic boundaries
 func ChunkSemanticDocument(doc *Document, maxChunkSize int, overlap int) []*Chunk {
+	return chunkSemantic(doc, maxChunkSize, overlap, CountChars)
+}
+
+func chunkSemantic(doc *Document, maxChunkSize int, overlap int, size SizeFunc) []*Chunk {
 	content := doc.Content
 	if len(content) == 0 {
 		return []*Chunk{}
@@ -269,33 +238,44 @@ func ChunkSemanticDocument(doc *Document, maxChunkSize int, overlap int) []*Chun
 			continue
 		}
 
-		// If adding this paragraph would exceed max size, finalize current chunk
-		if currentChunk.Len() > 0 && currentChunk.Len()+len(paragraph)+2 > maxChunkSize {
-			// Create chunk from current content
-			chunkContent := strings.TrimSpace(currentChunk.String())
-			if len(chunkContent) > 0 {
-				chunk := createChunk(doc, chunkIndex, chunkContent, chunkStart, globalOffset-1)
-				chunks = append(chunks, chunk)
-				chunkIndex++
+		// Paragraphs too large for one chunk are split at sentences, then words
+		pieceOffset := globalOffset
+		for i, piece := range splitToFit(paragraph, maxChunkSize, size) {
+			separator := "\n\n"
+			if i > 0 {
+				separator = " "
 			}
 
-			// Start new chunk with overlap
-			currentChunk.Reset()
-			if overlap > 0 && len(chunkContent) > overlap {
-				overlapText := chunkContent[len(chunkContent)-overlap:]
-				currentChunk.WriteString(overlapText)
-				chunkStart = globalOffset - overlap
-			} else {
-				chunkStart = globalOffset
+			// If adding this piece would exceed max size, finalize current chunk
+			if currentChunk.Len() > 0 && size(currentChunk.String()+separator+piece) > maxChunkSize {
+				// Create chunk from current content
+				chunkContent := strings.TrimSpace(currentChunk.String())
+				if len(chunkContent) > 0 {
+					chunk := createChunk(doc, chunkIndex, chunkContent, chunkStart, pieceOffset-1)
+					chunks = append(chunks, chunk)
+					chunkIndex++
+				}
+
+				// Start new chunk with overlap, when it fits alongside the piece
+				currentChunk.Reset()
+				chunkStart = pieceOffset
+				if overlap > 0 && size(chunkContent) > overlap {
+					overlapText := tailWithin(chunkContent, overlap, size)
+					if overlapText != "" && size(overlapText+separator+piece) <= maxChunkSize {
+						currentChunk.WriteString(overlapText)
+						chunkStart = pieceOffset - len(overlapText)
+					}
+				}
 			}
-		}
 
-		// Add paragraph to current chunk
-		if currentChunk.Len() > 0 {
-			currentChunk.WriteString("\n\n")
+			// Add piece to current chunk
+			if currentChunk.Len() > 0 {
+				currentChunk.WriteString(separator)
+			}
+			currentChunk.WriteString(piece)
+			pieceOffset += len(piece) + 1
 		}
-		currentChunk.WriteString(paragraph)
-		
+
 		globalOffset += len(paragraph) + 2 // account for \n\n after each paragraph
 	}
 
@@ -313,22 +293,34 @@ func ChunkSemanticDocument(doc *Document, maxChunkSize int, overlap int) []*Chun
 
 // ChunkSmartDocument uses intelligent splitting based on content structure
 func ChunkSmartDocument(doc *Document, maxChunkSize int, overlap int) []*Chunk {
+	return chunkSmart(doc, maxChunkSize, overlap, CountChars)
+}
+
+func chunkSmart(doc *Document, maxChunkSize int, overlap int, size SizeFunc) []*Chunk {
 	content := doc.Content
 	if len(content) == 0 {
 		return []*Chunk{}
 	}
 
-	// For markdown files, split on headers first
-	if isMarkdown(doc) {
-		return chunkMarkdownSections(doc, maxChunkSize, overlap)
+	// For source files with a structural chunker, split on declarations
+	if chunks, ok := chunkSourceCode(doc, maxChunkSize, overlap, size); ok {
+		return chunks
+	}
+
+	// For markdown files, and formats whose loaders render markdown
+	// headings, split on headers first
+	if isMarkdown(doc) || hasRenderedHeadings(doc) {
+		return chunkMarkdownSections(doc, maxChunkSize, overlap, size)
 	}
 
 	// For other files, use semantic paragraph splitting
-	return ChunkSemanticDocument(doc, maxChunkSize, overlap)
+	return chunkSemantic(doc, maxChunkSize, overlap, size)
 }
 
-// chunkMarkdownSections splits markdown content on header boundaries
-func chunkMarkdownSections(doc *Document, maxChunkSize int, overlap int) []*Chunk {
+// chunkMarkdownSections splits markdown content on header boundaries. Each
+// chunk records the heading of its section, its level and its path of
+// enclosing headings ("Deploy Guide > Configuration").
+func chunkMarkdownSections(doc *Document, maxChunkSize int, overlap int, size SizeFunc) []*Chunk {
 	content := doc.Content
 	lines := strings.Split(content, "\n")
 	
@@ -337,31 +329,56 @@ func chunkMarkdownSections(doc *Document, maxChunkSize int, overlap int) []*Chun
 	var sectionStart int = 0
 	chunkIndex := 0
 	globalOffset := 0
+	var fence markdownFence
+	var outline headingOutline
+	sectionLevel := 0
+
+	// addSection chunks the current section, labelled with the heading it
+	// sits under
+	addSection := func() {
+		sectionContent := strings.TrimSpace(currentSection.String())
+		if len(sectionContent) == 0 {
+			return
+		}
+		var sectionChunks []*Chunk
+		// If section is too large, split it further
+		if size(sectionContent) > maxChunkSize {
+			sectionChunks = splitLargeSection(doc, sectionContent, maxChunkSize, overlap, sectionStart, &chunkIndex, size)
+		} else {
+			sectionChunks = []*Chunk{createChunk(doc, chunkIndex, sectionContent, sectionStart, globalOffset)}
+			chunkIndex++
+		}
+		if sectionLevel > 0 {
+			for _, chunk := range sectionChunks {
+				chunk.Metadata["heading_path"] = outline.paths[len(outline.paths)-1]
+				chunk.Metadata["heading"] = outline.names[len(outline.names)-1]
+				chunk.Metadata["heading_level"] = sectionLevel
+			}
+		}
+		chunks = append(chunks, sectionChunks...)
+	}
 
 	for _, line := range lines {
 		lineLen := len(line) + 1 // +1 for newline
 		
-		// Check if this is a header (starts with #)
-		isHeader := strings.HasPrefix(strings.TrimSpace(line), "#")
+		// Check if this is a header, ignoring comment lines inside fenced
+		// code blocks
+		level, text, isHeader := 0, "", false
+		if !fence.inCode(line) {
+			level, text, isHeader = parseMarkdownHeading(line)
+		}
 		
-		// If we hit a header and have content, finalize current section
-		if isHeader && currentSection.Len() > 0 {
-			sectionContent := strings.TrimSpace(currentSection.String())
-			if len(sectionContent) > 0 {
-				// If section is too large, split it further
-				if len(sectionContent) > maxChunkSize {
-					subChunks := splitLargeSection(doc, sectionContent, maxChunkSize, overlap, sectionStart, &chunkIndex)
-					chunks = append(chunks, subChunks...)
-				} else {
-					chunk := createChunk(doc, chunkIndex, sectionContent, sectionStart, globalOffset)
-					chunks = append(chunks, chunk)
-					chunkIndex++
-				}
+		// If we hit a header, finalize current section
+		if isHeader {
+			if currentSection.Len() > 0 {
+				addSection()
 			}
 			
 			// Start new section
 			currentSection.Reset()
 			sectionStart = globalOffset
+			outline.add(level, text)
+			sectionLevel = level
 		}
 		
 		// Add line to current section
@@ -374,23 +391,14 @@ func chunkMarkdownSections(doc *Document, maxChunkSize int, overlap int) []*Chun
 
 	// Add final section
 	if currentSection.Len() > 0 {
-		sectionContent := strings.TrimSpace(currentSection.String())
-		if len(sectionContent) > 0 {
-			if len(sectionContent) > maxChunkSize {
-				subChunks := splitLargeSection(doc, sectionContent, maxChunkSize, overlap, sectionStart, &chunkIndex)
-				chunks = append(chunks, subChunks...)
-			} else {
-				chunk := createChunk(doc, chunkIndex, sectionContent, sectionStart, globalOffset)
-				chunks = append(chunks, chunk)
-			}
-		}
+		addSection()
 	}
 
 	return chunks
 }
 
 // splitLargeSection splits a large section into smaller semantic chunks
-func splitLargeSection(doc *Document, content string, maxChunkSize int, overlap int, baseOffset int, chunkIndex *int) []*Chunk {
+func splitLargeSection(doc *Document, content string, maxChunkSize int, overlap int, baseOffset int, chunkIndex *int, size SizeFunc) []*Chunk {
 	// First try splitting by double newlines (paragraphs)
 	paragraphs := strings.Split(content, "\n\n")
 	var chunks []*Chunk
@@ -404,23 +412,31 @@ func splitLargeSection(doc *Document, content string, maxChunkSize int, overlap
 			continue
 		}
 
-		// If adding this paragraph exceeds size, finalize current chunk
-		if currentChunk.Len() > 0 && currentChunk.Len()+len(paragraph)+2 > maxChunkSize {
-			chunkContent := strings.TrimSpace(currentChunk.String())
-			if len(chunkContent) > 0 {
-				chunk := createChunk(doc, *chunkIndex, chunkContent, baseOffset+localOffset-len(chunkContent), baseOffset+localOffset)
-				chunks = append(chunks, chunk)
-				(*chunkIndex)++
+		// Paragraphs too large for one chunk are split at sentences, then words
+		for i, piece := range splitToFit(paragraph, maxChunkSize, size) {
+			separator := "\n\n"
+			if i > 0 {
+				separator = " "
 			}
-			currentChunk.Reset()
-		}
 
-		// Add paragraph
-		if currentChunk.Len() > 0 {
-			currentChunk.WriteString("\n\n")
+			// If adding this piece exceeds size, finalize current chunk
+			if currentChunk.Len() > 0 && size(currentChunk.String()+separator+piece) > maxChunkSize {
+				chunkContent := strings.TrimSpace(currentChunk.String())
+				if len(chunkContent) > 0 {
+					chunk := createChunk(doc, *chunkIndex, chunkContent, baseOffset+localOffset-len(chunkContent), baseOffset+localOffset)
+					chunks = append(chunks, chunk)
+					(*chunkIndex)++
+				}
+				currentChunk.Reset()
+			}
+
+			// Add piece
+			if currentChunk.Len() > 0 {
+				currentChunk.WriteString(separator)
+			}
+			currentChunk.WriteString(piece)
+			localOffset += len(piece) + len(separator)
 		}
-		currentChunk.WriteString(paragraph)
-		localOffset += len(paragraph) + 2
 	}
 
 	// Add final chunk
@@ -436,17 +452,37 @@ func splitLargeSection(doc *Document, content string, maxChunkSize int, overlap
 	return chunks
 }
 
-// createChunk helper function to create a chunk with metadata
-func createChunk(doc *Document, chunkIndex int, content string, start, end int) *Chunk {
-	chunkMetadata := make(map[string]interface{})
+// documentOnlyMetadata are document metadata keys that describe the whole
+// document, such as the outline of all its headings, and are left off its
+// chunks so each chunk's stored metadata stays small
+var documentOnlyMetadata = map[string]bool{
+	"headings": true,
+}
+
+// newChunkMetadata returns a copy of a document's metadata, without the
+// document-only keys, for one of its chunks to add its own keys to
+func newChunkMetadata(doc *Document) map[string]interface{} {
+	chunkMetadata := make(map[string]interface{}, len(doc.Metadata))
 	for k, v := range doc.Metadata {
-		chunkMetadata[k] = v
+		if !documentOnlyMetadata[k] {
+			chunkMetadata[k] = v
+		}
 	}
+	return chunkMetadata
+}
+
+// createChunk helper function to create a chunk with metadata
+func createChunk(doc *Document, chunkIndex int, content string, start, end int) *Chunk {
+	chunkMetadata := newChunkMetadata(doc)
 	chunkMetadata["chunk_index"] = chunkIndex
 	chunkMetadata["chunk_start"] = start
 	chunkMetadata["chunk_end"] = end
 	chunkMetadata["parent_id"] = doc.ID
 	chunkMetadata["chunk_type"] = "semantic"
+	if len(doc.Pages) > 0 {
+		start = locateChunk(doc.Content, content, start)
+		annotatePages(doc, chunkMetadata, start, start+len(content))
+	}
 
 	return &Chunk{
 		ID:       fmt.Sprintf("%s_semantic_%d", doc.ID, chunkIndex),
@@ -455,10 +491,93 @@ func createChunk(doc *Document, chunkIndex int, content string, start, end int)
 	}
 }
 
+// headingOutline records each heading as a "Parent > Child" path of its
+// enclosing headings
+type headingOutline struct {
+	stack []int
+	names []string
+	paths []string
+}
+
+func (o *headingOutline) add(level int, text string) {
+	for len(o.stack) > 0 && o.stack[len(o.stack)-1] >= level {
+		o.stack = o.stack[:len(o.stack)-1]
+		o.names = o.names[:len(o.names)-1]
+	}
+	o.stack = append(o.stack, level)
+	o.names = append(o.names, text)
+	o.paths = append(o.paths, strings.Join(o.names, " > "))
+}
+
 // isMarkdown checks if the document is a markdown file
 func isMarkdown(doc *Document) bool {
 	if ext, ok := doc.Metadata["extension"].(string); ok {
 		return ext == ".md" || ext == ".markdown"
 	}
 	return false
-} 
\ No newline at end of file
+} 
+
+// annotatePages records the page, and the last page when the chunk crosses a
+// page break, for documents that carry page spans. Slides and sheets are
+// recorded the same way under their own keys.
+func annotatePages(doc *Document, metadata map[string]interface{}, start, end int) {
+	unit := doc.PageUnit
+	if unit == "" {
+		unit = "page"
+	}
+
+	var first, last *PageSpan
+	for i := range doc.Pages {
+		page := &doc.Pages[i]
+		if page.End <= start || page.Start >= end {
+			continue
+		}
+		if first == nil {
+			first = page
+		}
+		last = page
+	}
+	if first == nil {
+		return
+	}
+	metadata[unit] = first.Number
+	if first.Name != "" {
+		metadata[unit+"_name"] = first.Name
+	}
+	if last.Number != first.Number {
+		metadata[unit+"_end"] = last.Number
+	}
+}
+
+// locateChunk finds where a chunk's text starts in content. The semantic
+// chunkers track offsets approximately, so the hint is refined by searching
+// for the chunk's opening text from slightly before it.
+func locateChunk(content, chunk string, hint int) int {
+	prefix := chunk
+	if len(prefix) > 64 {
+		prefix = prefix[:64]
+	}
+	from := hint - 256
+	if from < 0 {
+		from = 0
+	}
+	if from > len(content) {
+		return hint
+	}
+	if i := strings.Index(content[from:], prefix); i >= 0 {
+		return from + i
+	}
+	return hint
+}
+
+// hasRenderedHeadings checks if the document was loaded from a format the
+// loaders convert to markdown-style headings (HTML and Office documents)
+func hasRenderedHeadings(doc *Document) bool {
+	if ext, ok := doc.Metadata["extension"].(string); ok {
+		switch strings.ToLower(ext) {
+		case ".html", ".htm", ".docx", ".pptx", ".xlsx":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package document
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	pythonFuncPattern  = regexp.MustCompile(`^(?:async\s+)?def\s+([A-Za-z_]\w*)`)
+	pythonClassPattern = regexp.MustCompile(`^class\s+([A-Za-z_]\w*)`)
+)
+
+var pythonLanguage = codeLanguage{
+	classify: func(line string, member bool) (string, string) {
+		if m := pythonFuncPattern.FindStringSubmatch(line); m != nil {
+			return "function", m[1]
+		}
+		if m := pythonClassPattern.FindStringSubmatch(line); m != nil {
+			return "class", m[1]
+		}
+		return "", ""
+	},
+	attaches: func(line string) bool {
+		return strings.HasPrefix(line, "@")
+	},
+	continues: func(line string) bool {
+		for _, keyword := range []string{"else", "elif", "except", "finally"} {
+			if strings.HasPrefix(line, keyword) {
+				rest := line[len(keyword):]
+				if rest == "" || rest[0] == ':' || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '*' {
+					return true
+				}
+			}
+		}
+		return false
+	},
+	comment: "#",
+}
+
+// ChunkPythonSource splits Python source into one chunk per top-level
+// function and class, using indentation to find where each ends. Decorators
+// and comments directly above a definition stay with it; module-level
+// statements between definitions are grouped. Classes larger than
+// maxChunkSize are split into a header chunk and one chunk per method, and
+// long functions are split between statements.
+func ChunkPythonSource(doc *Document, maxChunkSize int) ([]*Chunk, error) {
+	return chunkPythonSource(doc, maxChunkSize, CountChars)
+}
+
+func chunkPythonSource(doc *Document, maxChunkSize int, size SizeFunc) ([]*Chunk, error) {
+	lines, info, err := lexPython(doc.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Python source: %w", err)
+	}
+	return chunkStructured(doc, pythonLanguage, lines, info, maxChunkSize, size), nil
+}
+
+// lexPython finds the lines that begin statements, skipping those inside
+// strings, brackets and backslash continuations
+func lexPython(src string) ([]string, []lineInfo, error) {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	info := make([]lineInfo, len(lines))
+
+	var (
+		quote        string // open string delimiter: ', ", ''' or """
+		depth        int    // open brackets
+		continuation bool   // previous line ended in a backslash
+	)
+	for n, line := range lines {
+		info[n].start = quote == "" && depth == 0 && !continuation
+		info[n].level = pythonIndent(line)
+		continuation = false
+
+		for i := 0; i < len(line); i++ {
+			ch := line[i]
+			if quote != "" {
+				info[n].code = true
+				switch {
+				case ch == '\\':
+					i++
+				case strings.HasPrefix(line[i:], quote):
+					i += len(quote) - 1
+					quote = ""
+				}
+				continue
+			}
+
+			switch ch {
+			case ' ', '\t', '\f':
+				continue
+			case '#':
+				i = len(line)
+				continue
+			case '\\':
+				if i == len(line)-1 {
+					continuation = true
+					continue
+				}
+			case '\'', '"':
+				quote = string(ch)
+				if strings.HasPrefix(line[i:], strings.Repeat(quote, 3)) {
+					quote = strings.Repeat(quote, 3)
+					i += 2
+				}
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+				if depth < 0 {
+					return nil, nil, fmt.Errorf("unbalanced %q on line %d", ch, n+1)
+				}
+			}
+			info[n].code = true
+		}
+
+		// A single-quoted string can't span lines without a backslash
+		if len(quote) == 1 && !strings.HasSuffix(line, "\\") {
+			quote = ""
+		}
+	}
+
+	if quote != "" {
+		return nil, nil, fmt.Errorf("unterminated string")
+	}
+	if depth != 0 {
+		return nil, nil, fmt.Errorf("unclosed bracket")
+	}
+	return lines, info, nil
+}
+
+// pythonIndent measures a line's indentation, with tabs advancing to the
+// next multiple of eight as in the Python tokenizer
+func pythonIndent(line string) int {
+	width := 0
+	for _, ch := range line {
+		switch ch {
+		case ' ':
+			width++
+		case '\t':
+			width = (width/8 + 1) * 8
+		default:
+			return width
+		}
+	}
+	return width
+}
@@ -0,0 +1,219 @@
+package document
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviations are words commonly followed by a period that doesn't end
+// the sentence, compared lowercase without the period
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true,
+	"jr": true, "st": true, "mt": true, "rev": true, "gen": true, "gov": true,
+	"sgt": true, "capt": true, "lt": true, "col": true, "hon": true,
+	"vs": true, "etc": true, "cf": true, "al": true, "approx": true, "ca": true,
+	"inc": true, "ltd": true, "co": true, "corp": true, "dept": true, "univ": true,
+	"no": true, "nos": true, "fig": true, "figs": true, "eq": true, "eqs": true,
+	"vol": true, "vols": true, "pp": true, "p": true, "ch": true, "sec": true,
+	"ed": true, "eds": true, "est": true, "min": true, "max": true, "avg": true,
+	"jan": true, "feb": true, "mar": true, "apr": true, "jun": true, "jul": true,
+	"aug": true, "sep": true, "sept": true, "oct": true, "nov": true, "dec": true,
+	"mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true, "sun": true,
+}
+
+// sentenceProtected matches spans that never contain a sentence boundary:
+// URLs, email addresses and inline code
+var sentenceProtected = regexp.MustCompile("`[^`\n]+`|(?i:https?://|ftp://|www\\.)[^\\s<>\"]+|[\\w.+-]+@[\\w-]+(?:\\.[\\w-]+)+")
+
+// SplitSentences splits text into sentences. A sentence ends at '.', '!' or
+// '?' (and their CJK forms), optionally followed by closing quotes or
+// brackets, when whitespace and then something other than a lowercase
+// letter follow. Periods after known abbreviations, initials and dotted
+// abbreviations such as "e.g." are not boundaries, and neither is anything
+// inside URLs, email addresses or `code spans`. Decimals never qualify
+// because no whitespace follows their point.
+func SplitSentences(text string) []string {
+	protected := sentenceProtected.FindAllStringIndex(text, -1)
+	inProtected := func(i int) bool {
+		for _, span := range protected {
+			end := span[1]
+			// A URL's trailing punctuation belongs to the sentence
+			for end > span[0] && strings.ContainsRune(".,;:!?)", rune(text[end-1])) {
+				end--
+			}
+			if i >= span[0] && i < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	var sentences []string
+	start := 0
+	emit := func(end int) {
+		if s := strings.TrimSpace(text[start:end]); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = end
+	}
+
+	for i := 0; i < len(text); {
+		r, width := utf8.DecodeRuneInString(text[i:])
+		switch r {
+		case '。', '！', '？':
+			// CJK text has no spaces between sentences
+			emit(i + width)
+			i += width
+			continue
+		case '.', '!', '?', '…':
+		default:
+			i += width
+			continue
+		}
+		if inProtected(i) {
+			i += width
+			continue
+		}
+
+		// Take the whole run of terminators and closing punctuation
+		end := i + width
+		for end < len(text) {
+			next, w := utf8.DecodeRuneInString(text[end:])
+			if !strings.ContainsRune(".!?…\"'”’)]»", next) {
+				break
+			}
+			end += w
+		}
+
+		if end < len(text) {
+			next, _ := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsSpace(next) {
+				i = end
+				continue
+			}
+			rest := strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+			if following, _ := utf8.DecodeRuneInString(rest); rest != "" && unicode.IsLower(following) {
+				i = end
+				continue
+			}
+		}
+		if r == '.' && end == i+1 && isAbbreviation(text[start:i]) {
+			i = end
+			continue
+		}
+
+		emit(end)
+		i = end
+	}
+	emit(len(text))
+	return sentences
+}
+
+// isAbbreviation reports whether the word ending before a period is an
+// abbreviation rather than the end of a sentence
+func isAbbreviation(before string) bool {
+	word := before
+	if i := strings.LastIndexFunc(before, unicode.IsSpace); i >= 0 {
+		word = before[i+1:]
+	}
+	word = strings.TrimLeft(word, "(\"'“‘[")
+	if word == "" {
+		return false
+	}
+
+	// Initials such as the "J" of "J. Smith"
+	if r, size := utf8.DecodeRuneInString(word); size == len(word) && unicode.IsUpper(r) {
+		return true
+	}
+	// Dotted abbreviations such as "e.g", "i.e" or "U.S"
+	if strings.Contains(word, ".") && !strings.Contains(word, "..") {
+		dotted := true
+		for _, part := range strings.Split(word, ".") {
+			if utf8.RuneCountInString(part) > 2 || strings.IndexFunc(part, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+				dotted = false
+			}
+		}
+		if dotted {
+			return true
+		}
+	}
+	return abbreviations[strings.ToLower(word)]
+}
+
+// splitToFit breaks text that measures more than maxSize into pieces that
+// fit: whole sentences where possible, then words, and as a last resort
+// pieces of a single overlong word
+func splitToFit(text string, maxSize int, size SizeFunc) []string {
+	if size(text) <= maxSize {
+		return []string{text}
+	}
+
+	var pieces []string
+	for _, sentence := range SplitSentences(text) {
+		if size(sentence) <= maxSize {
+			pieces = append(pieces, sentence)
+			continue
+		}
+		pieces = append(pieces, packWithin(splitWords(sentence, maxSize, size), " ", maxSize, size)...)
+	}
+	return packWithin(pieces, " ", maxSize, size)
+}
+
+// splitWords splits text at whitespace, cutting any word that alone exceeds
+// maxSize
+func splitWords(text string, maxSize int, size SizeFunc) []string {
+	var words []string
+	for _, word := range strings.Fields(text) {
+		for size(word) > maxSize {
+			// Longest prefix that fits, at least one character
+			lo, hi := 1, utf8.RuneCountInString(word)-1
+			for lo < hi {
+				mid := (lo + hi + 1) / 2
+				if size(prefixRunes(word, mid)) <= maxSize {
+					lo = mid
+				} else {
+					hi = mid - 1
+				}
+			}
+			head := prefixRunes(word, lo)
+			words = append(words, head)
+			word = word[len(head):]
+		}
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+func prefixRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// packWithin joins consecutive pieces with sep while the result still fits
+func packWithin(pieces []string, sep string, maxSize int, size SizeFunc) []string {
+	var packed []string
+	current := ""
+	for _, piece := range pieces {
+		if current != "" && size(current+sep+piece) <= maxSize {
+			current += sep + piece
+			continue
+		}
+		if current != "" {
+			packed = append(packed, current)
+		}
+		current = piece
+	}
+	if current != "" {
+		packed = append(packed, current)
+	}
+	return packed
+}
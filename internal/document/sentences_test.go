@@ -0,0 +1,40 @@
+package document
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"no terminator", "No terminator here", []string{"No terminator here"}},
+		{"simple", "Hello world. This is a test.", []string{"Hello world.", "This is a test."}},
+		{"question and exclamation", "Is it done? Yes! Ship it.", []string{"Is it done?", "Yes!", "Ship it."}},
+		{"title abbreviation", "Dr. Smith arrived. He sat down!", []string{"Dr. Smith arrived.", "He sat down!"}},
+		{"dotted abbreviation", "Use e.g. a hammer. Then stop?", []string{"Use e.g. a hammer.", "Then stop?"}},
+		{"initials", "J. R. R. Tolkien wrote books. They sold well.", []string{"J. R. R. Tolkien wrote books.", "They sold well."}},
+		{"decimal", "Pi is 3.14 today. Yes.", []string{"Pi is 3.14 today.", "Yes."}},
+		{"lowercase continuation", "one. two. Three.", []string{"one. two.", "Three."}},
+		{"url", "See https://example.com/a.b. Then go.", []string{"See https://example.com/a.b.", "Then go."}},
+		{"email", "Mail me at john.doe@example.com. Thanks.", []string{"Mail me at john.doe@example.com.", "Thanks."}},
+		{"code span", "Run `make build. Now` first. Done.", []string{"Run `make build. Now` first.", "Done."}},
+		{"closing quote", `He said "Stop." Then left.`, []string{`He said "Stop."`, "Then left."}},
+		{"closing bracket", "(See Fig. 3.) Next sentence.", []string{"(See Fig. 3.)", "Next sentence."}},
+		{"ellipsis", "Wait... What happened?", []string{"Wait...", "What happened?"}},
+		{"cjk", "今天很好。明天也好！", []string{"今天很好。", "明天也好！"}},
+		{"newlines", "First line.\nSecond line.", []string{"First line.", "Second line."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitSentences(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitSentences(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package document
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SizeFunc measures text in the unit chunk sizes are given in
+type SizeFunc func(text string) int
+
+// CountChars measures text in bytes, the default chunk size unit
+func CountChars(text string) int {
+	return len(text)
+}
+
+// ChunkOptions configures ChunkWithOptions
+type ChunkOptions struct {
+	MaxSize  int // largest chunk, in the unit Size measures
+	Overlap  int // text repeated between consecutive chunks, in the same unit
+	Semantic bool
+	// Size measures chunks; nil counts characters. Pass the embedding
+	// model's token counter to keep chunks within its sequence length.
+	Size SizeFunc
+}
+
+// ChunkWithOptions splits a document into fixed-size chunks, or with
+// Semantic set into chunks following its structure, measuring sizes with
+// opts.Size
+func ChunkWithOptions(doc *Document, opts ChunkOptions) []*Chunk {
+	size := opts.Size
+	if size == nil {
+		size = CountChars
+		if !opts.Semantic {
+			return ChunkDocument(doc, opts.MaxSize, opts.Overlap)
+		}
+	}
+	if opts.Semantic {
+		return chunkSmart(doc, opts.MaxSize, opts.Overlap, size)
+	}
+	return chunkBySize(doc, opts.MaxSize, opts.Overlap, size)
+}
+
+// chunkBySize cuts fixed-size chunks like ChunkDocument, measuring with
+// size. Each chunk is extended as far as it fits and then pulled back to a
+// word boundary, and the next one starts early enough to repeat overlap
+// units.
+func chunkBySize(doc *Document, maxSize int, overlap int, size SizeFunc) []*Chunk {
+	content := doc.Content
+	var chunks []*Chunk
+	start := 0
+	for start < len(content) {
+		end := fitEnd(content, start, maxSize, size)
+
+		chunkContent := strings.TrimSpace(content[start:end])
+		if len(chunkContent) > 0 {
+			chunkMetadata := newChunkMetadata(doc)
+			chunkMetadata["chunk_index"] = len(chunks)
+			chunkMetadata["chunk_start"] = start
+			chunkMetadata["chunk_end"] = end
+			chunkMetadata["parent_id"] = doc.ID
+			annotatePages(doc, chunkMetadata, start, end)
+
+			chunks = append(chunks, &Chunk{
+				ID:       fmt.Sprintf("%s_chunk_%d", doc.ID, len(chunks)),
+				Content:  chunkContent,
+				Metadata: chunkMetadata,
+			})
+		}
+		if end >= len(content) {
+			break
+		}
+
+		next := end
+		if overlap > 0 {
+			next = end - len(tailWithin(content[start:end], overlap, size))
+		}
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// fitBytesPerUnit is the first guess at how many bytes of text one size
+// unit covers. fitEnd only measures text within a window this many bytes per
+// unit of maxSize, doubling it while the text still fits, so no measurement
+// runs to the end of a long document.
+const fitBytesPerUnit = 8
+
+// fitEnd returns the furthest word boundary after start at which
+// content[start:end] still measures at most maxSize. At least one word is
+// always taken so chunking makes progress.
+func fitEnd(content string, start int, maxSize int, size SizeFunc) int {
+	// Grow a window until the text in it no longer fits, then binary
+	// search the byte length inside it and back off to whitespace
+	lo, hi := start+1, len(content)
+	window := maxSize * fitBytesPerUnit
+	if window < 1 {
+		window = 1
+	}
+	for {
+		end := start + window
+		if end >= len(content) {
+			if size(content[start:]) <= maxSize {
+				return len(content)
+			}
+			break
+		}
+		if size(content[start:end]) > maxSize {
+			hi = end - 1
+			break
+		}
+		lo = end
+		window *= 2
+	}
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if size(content[start:mid]) <= maxSize {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	end := lo
+	if i := strings.LastIndexAny(content[start:end], " \t\n"); i > 0 {
+		return start + i
+	}
+
+	// A single word longer than maxSize: take the whole word
+	if i := strings.IndexAny(content[end:], " \t\n"); i >= 0 {
+		return end + i
+	}
+	return len(content)
+}
+
+// tailWithin returns the longest suffix of text that starts at a word and
+// measures at most limit, binary searching the word starts
+func tailWithin(text string, limit int, size SizeFunc) string {
+	// Word starts from the end of text backwards, so suffixes grow
+	var starts []int
+	for i := len(text) - 1; i > 0; i-- {
+		if text[i-1] == ' ' || text[i-1] == '\n' || text[i-1] == '\t' {
+			starts = append(starts, i)
+		}
+	}
+	k := sort.Search(len(starts), func(k int) bool {
+		return size(text[starts[k]:]) > limit
+	})
+	if k == 0 {
+		return ""
+	}
+	return text[starts[k-1]:]
+}
@@ -0,0 +1,74 @@
+package document
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// countWords measures text in words, standing in for a token counter
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestChunkBySize(t *testing.T) {
+	var words []string
+	for i := 0; i < 20000; i++ {
+		words = append(words, fmt.Sprintf("w%d", i))
+	}
+	doc := &Document{ID: "doc", Content: strings.Join(words, " "), Metadata: map[string]interface{}{}}
+
+	longest := 0
+	size := func(text string) int {
+		if len(text) > longest {
+			longest = len(text)
+		}
+		return countWords(text)
+	}
+	chunks := chunkBySize(doc, 50, 10, size)
+
+	for i, chunk := range chunks {
+		if n := countWords(chunk.Content); n != 50 && i != len(chunks)-1 {
+			t.Fatalf("chunk %d has %d words, want 50", i, n)
+		}
+		if i > 0 {
+			prev := strings.Fields(chunks[i-1].Content)
+			if !strings.HasPrefix(chunk.Content, strings.Join(prev[len(prev)-10:], " ")) {
+				t.Fatalf("chunk %d doesn't repeat the last 10 words of chunk %d", i, i-1)
+			}
+		}
+	}
+	if !strings.HasSuffix(chunks[len(chunks)-1].Content, words[len(words)-1]) {
+		t.Error("last chunk doesn't end the document")
+	}
+	// Measuring is bounded by the chunk size, not the document length
+	if longest > 50*fitBytesPerUnit*2 {
+		t.Errorf("measured %d bytes at once for 50-word chunks", longest)
+	}
+}
+
+func TestFitEndLongWord(t *testing.T) {
+	content := strings.Repeat("x", 1000) + " tail"
+	if end := fitEnd(content, 0, 10, CountChars); end != 1000 {
+		t.Errorf("fitEnd = %d, want the whole first word (1000)", end)
+	}
+}
+
+func TestTailWithin(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"one two three four", 2, "three four"},
+		{"one two three four", 3, "two three four"},
+		{"one two three four", 0, ""},
+		{"one two\nthree", 10, "two\nthree"},
+		{"single", 5, ""},
+	}
+	for _, tt := range tests {
+		if got := tailWithin(tt.text, tt.limit, countWords); got != tt.want {
+			t.Errorf("tailWithin(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package document
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SentenceEmbedder embeds a batch of texts, returning one vector per text in
+// order. The embedding service satisfies it.
+type SentenceEmbedder interface {
+	GetEmbeddings(texts []string) ([][]float32, error)
+}
+
+// TopicOptions configures ChunkByTopic
+type TopicOptions struct {
+	MaxSize int // largest chunk, in the unit Size measures
+	MinSize int // smallest chunk a topic shift may end, in the same unit
+	// Window is how many sentences on each side of a gap are averaged
+	// before comparing them; defaults to 2
+	Window int
+	// Threshold places a boundary wherever the cosine distance between
+	// adjacent windows reaches it. When zero, boundaries go at distances
+	// at or above the Percentile of all of a document's distances.
+	Threshold float64
+	// Percentile defaults to 90
+	Percentile float64
+	// Size measures chunks; nil counts characters
+	Size SizeFunc
+}
+
+// topicSentence is a sentence with its place in the document
+type topicSentence struct {
+	text       string
+	start, end int
+	paragraph  bool // first sentence of a paragraph
+}
+
+// ChunkByTopic splits a document where its topic changes. Sentences are
+// embedded and, at each gap between sentences, the average embedding of the
+// Window sentences before is compared with that of the Window sentences
+// after. Chunks end at the largest drops in similarity, provided they have
+// reached MinSize; a chunk about to exceed MaxSize is ended at the largest
+// drop within it instead.
+func ChunkByTopic(doc *Document, embedder SentenceEmbedder, opts TopicOptions) ([]*Chunk, error) {
+	size := opts.Size
+	if size == nil {
+		size = CountChars
+	}
+	if opts.Window <= 0 {
+		opts.Window = 2
+	}
+	if opts.Percentile <= 0 || opts.Percentile > 100 {
+		opts.Percentile = 90
+	}
+
+	sentences := topicSentences(doc.Content, opts.MaxSize, size)
+	if len(sentences) == 0 {
+		return []*Chunk{}, nil
+	}
+
+	texts := make([]string, len(sentences))
+	for i, sentence := range sentences {
+		texts[i] = sentence.text
+	}
+	embeddings, err := embedder.GetEmbeddings(texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed sentences: %w", err)
+	}
+	if len(embeddings) != len(sentences) {
+		return nil, fmt.Errorf("got %d embeddings for %d sentences", len(embeddings), len(sentences))
+	}
+
+	// distances[i] is the topic shift between sentences i and i+1
+	distances := windowDistances(embeddings, opts.Window)
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = percentile(distances, opts.Percentile)
+	}
+
+	var chunks []*Chunk
+	emit := func(from, to int) {
+		chunks = append(chunks, newTopicChunk(doc, len(chunks), sentences[from:to], distances, to))
+	}
+
+	from := 0
+	for i := 1; i < len(sentences); i++ {
+		text := joinSentences(sentences[from : i+1])
+		if size(text) > opts.MaxSize {
+			// Too big: end the chunk at its largest topic shift that
+			// leaves MinSize before it, or right before sentence i
+			cut := i
+			best := -1.0
+			for gap := from; gap < i-1; gap++ {
+				if distances[gap] > best && size(joinSentences(sentences[from:gap+1])) >= opts.MinSize {
+					best, cut = distances[gap], gap+1
+				}
+			}
+			emit(from, cut)
+			from = cut
+			// Grow the next chunk again from the cut
+			i = cut
+			continue
+		}
+
+		gap := i - 1
+		if distances[gap] >= threshold && size(joinSentences(sentences[from:i])) >= opts.MinSize {
+			emit(from, i)
+			from = i
+		}
+	}
+	emit(from, len(sentences))
+	return chunks, nil
+}
+
+// topicSentences splits content into paragraphs and then sentences,
+// breaking any sentence larger than maxSize
+func topicSentences(content string, maxSize int, size SizeFunc) []topicSentence {
+	var sentences []topicSentence
+	offset := 0
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		first := true
+		cursor := offset
+		for _, sentence := range SplitSentences(paragraph) {
+			for _, piece := range splitToFit(sentence, maxSize, size) {
+				start := offset + strings.Index(content[offset:offset+len(paragraph)], piece)
+				if start < cursor {
+					start = cursor
+				}
+				sentences = append(sentences, topicSentence{
+					text:      piece,
+					start:     start,
+					end:       start + len(piece),
+					paragraph: first,
+				})
+				cursor = start + len(piece)
+				first = false
+			}
+		}
+		offset += len(paragraph) + 2
+	}
+	return sentences
+}
+
+// joinSentences rebuilds the text of consecutive sentences, keeping
+// paragraph breaks
+func joinSentences(sentences []topicSentence) string {
+	var b strings.Builder
+	for i, sentence := range sentences {
+		if i > 0 {
+			if sentence.paragraph {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(sentence.text)
+	}
+	return b.String()
+}
+
+// windowDistances returns, for each gap between adjacent sentences, the
+// cosine distance between the mean embeddings of the window sentences on
+// either side
+func windowDistances(embeddings [][]float32, window int) []float64 {
+	distances := make([]float64, len(embeddings)-1)
+	for gap := range distances {
+		lo := gap + 1 - window
+		if lo < 0 {
+			lo = 0
+		}
+		hi := gap + 1 + window
+		if hi > len(embeddings) {
+			hi = len(embeddings)
+		}
+		distances[gap] = 1 - cosine(meanVector(embeddings[lo:gap+1]), meanVector(embeddings[gap+1:hi]))
+	}
+	return distances
+}
+
+func meanVector(vectors [][]float32) []float64 {
+	mean := make([]float64, len(vectors[0]))
+	for _, v := range vectors {
+		for i, x := range v {
+			mean[i] += float64(x)
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(vectors))
+	}
+	return mean
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentile returns the p-th percentile of values, interpolating linearly
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return math.Inf(1)
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// newTopicChunk creates a chunk from sentences, recording the topic shift
+// at the gap that ends it
+func newTopicChunk(doc *Document, chunkIndex int, sentences []topicSentence, distances []float64, next int) *Chunk {
+	start, end := sentences[0].start, sentences[len(sentences)-1].end
+
+	chunkMetadata := newChunkMetadata(doc)
+	chunkMetadata["chunk_index"] = chunkIndex
+	chunkMetadata["chunk_start"] = start
+	chunkMetadata["chunk_end"] = end
+	chunkMetadata["parent_id"] = doc.ID
+	chunkMetadata["chunk_type"] = "topic"
+	chunkMetadata["sentence_count"] = len(sentences)
+	if next-1 < len(distances) {
+		chunkMetadata["topic_shift"] = math.Round(distances[next-1]*1000) / 1000
+	}
+	annotatePages(doc, chunkMetadata, start, end)
+
+	return &Chunk{
+		ID:       fmt.Sprintf("%s_topic_%d", doc.ID, chunkIndex),
+		Content:  joinSentences(sentences),
+		Metadata: chunkMetadata,
+	}
+}
@@ -18,19 +18,49 @@ type Service struct {
 	cmd        *exec.Cmd
 	stdin      io.WriteCloser
 	stdout     *bufio.Scanner
+	info       *ModelInfo
 }
 
-// EmbeddingRequest represents the request structure for the Python script
+// EmbeddingRequest represents the request structure for the Python script.
+// Op selects the operation: "embed" (the default), "embed_batch" (for
+// Texts), "info" or "count_tokens".
 type EmbeddingRequest struct {
-	Text  string `json:"text"`
-	Model string `json:"model"`
+	Op    string   `json:"op,omitempty"`
+	Text  string   `json:"text"`
+	Texts []string `json:"texts,omitempty"`
+	Model string   `json:"model"`
 }
 
 // EmbeddingResponse represents the response structure from the Python script
 type EmbeddingResponse struct {
-	Embedding []float32 `json:"embedding"`
-	Error     string    `json:"error,omitempty"`
-	Status    string    `json:"status,omitempty"`
+	Embedding  []float32   `json:"embedding"`
+	Embeddings [][]float32 `json:"embeddings,omitempty"`
+	TokenCount int         `json:"token_count,omitempty"`
+	Info       *ModelInfo  `json:"info,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Status     string      `json:"status,omitempty"`
+}
+
+// ModelInfo describes the embedding model loaded by the worker
+type ModelInfo struct {
+	// MaxSeqLength is the number of tokens the model reads; longer texts
+	// are truncated before they are embedded
+	MaxSeqLength int           `json:"max_seq_length"`
+	Dimension    int           `json:"dimension"`
+	Tokenizer    TokenizerInfo `json:"tokenizer"`
+}
+
+// TokenizerInfo describes the model's tokenizer. For WordPiece tokenizers
+// it carries everything needed to count tokens in Go.
+type TokenizerInfo struct {
+	Type            string   `json:"type"` // "wordpiece" or the name of another tokenizer model
+	Vocab           []string `json:"vocab,omitempty"`
+	Lowercase       bool     `json:"lowercase"`
+	StripAccents    bool     `json:"strip_accents"`
+	UnknownToken    string   `json:"unk_token,omitempty"`
+	SubwordPrefix   string   `json:"continuing_subword_prefix,omitempty"`
+	MaxCharsPerWord int      `json:"max_input_chars_per_word,omitempty"`
+	SpecialTokens   int      `json:"special_tokens"` // added to every text, such as [CLS] and [SEP]
 }
 
 // NewService creates a new embedding service
@@ -78,6 +108,8 @@ func (s *Service) start() error {
 		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 	s.stdout = bufio.NewScanner(stdout)
+	// Model info responses carry the whole tokenizer vocabulary
+	s.stdout.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
 	
 	// Start the process
 	if err := s.cmd.Start(); err != nil {
@@ -103,11 +135,109 @@ func (s *Service) start() error {
 
 // GetEmbedding generates an embedding for the given text
 func (s *Service) GetEmbedding(text string) ([]float32, error) {
-	request := EmbeddingRequest{
+	response, err := s.send(EmbeddingRequest{
+		Text:  text,
+		Model: s.model,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("embedding error: %s", response.Error)
+	}
+
+	return response.Embedding, nil
+}
+
+// ModelInfo returns the model's sequence length, dimension and tokenizer
+func (s *Service) ModelInfo() (*ModelInfo, error) {
+	if s.info != nil {
+		return s.info, nil
+	}
+
+	response, err := s.send(EmbeddingRequest{
+		Op:    "info",
+		Model: s.model,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("model info error: %s", response.Error)
+	}
+	if response.Info == nil {
+		return nil, fmt.Errorf("embedding worker does not report model info")
+	}
+
+	s.info = response.Info
+	return s.info, nil
+}
+
+// CountTokens asks the worker how many tokens the model sees for text,
+// special tokens included
+func (s *Service) CountTokens(text string) (int, error) {
+	response, err := s.send(EmbeddingRequest{
+		Op:    "count_tokens",
 		Text:  text,
 		Model: s.model,
+	})
+	if err != nil {
+		return 0, err
 	}
+	if response.Error != "" {
+		return 0, fmt.Errorf("token count error: %s", response.Error)
+	}
+	return response.TokenCount, nil
+}
+
+// TokenCounter counts the tokens the model sees for texts
+type TokenCounter struct {
+	count   func(text string) (int, error)
+	special int
+	err     error
+}
+
+// TokenCounter returns a counter of the tokens the model sees for a text.
+// WordPiece tokenizers are run in Go; other tokenizers are asked through
+// the worker, one request per count.
+func (s *Service) TokenCounter() (*TokenCounter, error) {
+	info, err := s.ModelInfo()
+	if err != nil {
+		return nil, err
+	}
+	counter := &TokenCounter{count: s.CountTokens, special: info.Tokenizer.SpecialTokens}
+	if info.Tokenizer.Type == "wordpiece" && len(info.Tokenizer.Vocab) > 0 {
+		wordPiece := NewWordPiece(info.Tokenizer)
+		counter.count = func(text string) (int, error) {
+			return wordPiece.Count(text), nil
+		}
+	}
+	return counter, nil
+}
+
+// Count returns the number of tokens the model sees for text. It has no
+// error result so it can size chunks directly; when the worker fails to
+// count, Count estimates four characters per token and records the error,
+// which Err reports. Callers must check Err before trusting the counts.
+func (c *TokenCounter) Count(text string) int {
+	count, err := c.count(text)
+	if err != nil {
+		if c.err == nil {
+			c.err = fmt.Errorf("failed to count tokens: %w", err)
+		}
+		return len(text)/4 + c.special
+	}
+	return count
+}
+
+// Err returns the first error counting tokens, after which counts were
+// estimates, or nil if every count was exact
+func (c *TokenCounter) Err() error {
+	return c.err
+}
 
+// send writes a request to the Python process and reads its response
+func (s *Service) send(request EmbeddingRequest) (*EmbeddingResponse, error) {
 	requestJSON, err := json.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -141,23 +271,38 @@ func (s *Service) GetEmbedding(text string) ([]float32, error) {
 		return nil, fmt.Errorf("embedding generation timed out after 2 minutes")
 	}
 
-	if response.Error != "" {
-		return nil, fmt.Errorf("embedding error: %s", response.Error)
-	}
-
-	return response.Embedding, nil
+	return &response, nil
 }
 
-// GetEmbeddings generates embeddings for multiple texts
+// maxBatchSize bounds how many texts GetEmbeddings sends in one request
+const maxBatchSize = 64
+
+// GetEmbeddings generates embeddings for multiple texts, sending them to the
+// worker in batches
 func (s *Service) GetEmbeddings(texts []string) ([][]float32, error) {
-	embeddings := make([][]float32, len(texts))
+	embeddings := make([][]float32, 0, len(texts))
 	
-	for i, text := range texts {
-		embedding, err := s.GetEmbedding(text)
+	for start := 0; start < len(texts); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		response, err := s.send(EmbeddingRequest{
+			Op:    "embed_batch",
+			Texts: texts[start:end],
+			Model: s.model,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get embedding for text %d: %w", i, err)
+			return nil, err
+		}
+		if response.Error != "" {
+			return nil, fmt.Errorf("embedding error for texts %d-%d: %s", start, end-1, response.Error)
+		}
+		if len(response.Embeddings) != end-start {
+			return nil, fmt.Errorf("embedding worker returned %d embeddings for %d texts", len(response.Embeddings), end-start)
 		}
-		embeddings[i] = embedding
+		embeddings = append(embeddings, response.Embeddings...)
 	}
 	
 	return embeddings, nil
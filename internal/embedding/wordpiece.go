@@ -0,0 +1,185 @@
+package embedding
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// WordPiece reproduces the BERT tokenizer used by most sentence-transformers
+// models: text is cleaned, optionally lowercased and stripped of accents,
+// split on whitespace and punctuation, and each word is broken into the
+// longest vocabulary pieces, greedily from the left
+type WordPiece struct {
+	vocab           map[string]struct{}
+	lowercase       bool
+	stripAccents    bool
+	unknown         string
+	subwordPrefix   string
+	maxCharsPerWord int
+	special         int
+}
+
+// NewWordPiece creates a WordPiece tokenizer from the settings the embedding
+// worker reports
+func NewWordPiece(info TokenizerInfo) *WordPiece {
+	w := &WordPiece{
+		vocab:           make(map[string]struct{}, len(info.Vocab)),
+		lowercase:       info.Lowercase,
+		stripAccents:    info.StripAccents,
+		unknown:         info.UnknownToken,
+		subwordPrefix:   info.SubwordPrefix,
+		maxCharsPerWord: info.MaxCharsPerWord,
+		special:         info.SpecialTokens,
+	}
+	for _, token := range info.Vocab {
+		w.vocab[token] = struct{}{}
+	}
+	if w.unknown == "" {
+		w.unknown = "[UNK]"
+	}
+	if w.subwordPrefix == "" {
+		w.subwordPrefix = "##"
+	}
+	if w.maxCharsPerWord <= 0 {
+		w.maxCharsPerWord = 100
+	}
+	return w
+}
+
+// Tokenize returns the word pieces of text, without special tokens
+func (w *WordPiece) Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range w.basicTokens(text) {
+		tokens = append(tokens, w.wordPieces(word)...)
+	}
+	return tokens
+}
+
+// Count returns the number of tokens the model sees for text, including the
+// special tokens added around it such as [CLS] and [SEP]
+func (w *WordPiece) Count(text string) int {
+	count := w.special
+	for _, word := range w.basicTokens(text) {
+		count += len(w.wordPieces(word))
+	}
+	return count
+}
+
+// basicTokens splits text into words and punctuation marks
+func (w *WordPiece) basicTokens(text string) []string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == 0 || r == utf8.RuneError || isControl(r):
+			continue
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		case isCJK(r):
+			// Chinese characters are tokenized one by one
+			b.WriteByte(' ')
+			b.WriteRune(r)
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	var tokens []string
+	for _, word := range strings.Fields(b.String()) {
+		if w.lowercase {
+			word = strings.ToLower(word)
+		}
+		if w.stripAccents {
+			word = stripAccents(word)
+		}
+
+		// Every punctuation mark is a token of its own
+		start := 0
+		for i, r := range word {
+			if isPunctuation(r) {
+				if i > start {
+					tokens = append(tokens, word[start:i])
+				}
+				tokens = append(tokens, string(r))
+				start = i + utf8.RuneLen(r)
+			}
+		}
+		if start < len(word) {
+			tokens = append(tokens, word[start:])
+		}
+	}
+	return tokens
+}
+
+// wordPieces breaks a word into vocabulary pieces, or the unknown token when
+// it can't be covered
+func (w *WordPiece) wordPieces(word string) []string {
+	if utf8.RuneCountInString(word) > w.maxCharsPerWord {
+		return []string{w.unknown}
+	}
+
+	var pieces []string
+	for start := 0; start < len(word); {
+		end := len(word)
+		var piece string
+		for end > start {
+			candidate := word[start:end]
+			if start > 0 {
+				candidate = w.subwordPrefix + candidate
+			}
+			if _, ok := w.vocab[candidate]; ok {
+				piece = candidate
+				break
+			}
+			// Step back one character
+			_, size := utf8.DecodeLastRuneInString(word[start:end])
+			end -= size
+		}
+		if piece == "" {
+			return []string{w.unknown}
+		}
+		pieces = append(pieces, piece)
+		start = end
+	}
+	return pieces
+}
+
+func stripAccents(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isControl(r rune) bool {
+	if r == '\t' || r == '\n' || r == '\r' {
+		return false
+	}
+	return unicode.In(r, unicode.Cc, unicode.Cf)
+}
+
+// isPunctuation follows BERT in treating all non-alphanumeric ASCII as
+// punctuation, along with the Unicode punctuation categories
+func isPunctuation(r rune) bool {
+	if r >= 33 && r <= 47 || r >= 58 && r <= 64 || r >= 91 && r <= 96 || r >= 123 && r <= 126 {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+func isCJK(r rune) bool {
+	return r >= 0x4E00 && r <= 0x9FFF ||
+		r >= 0x3400 && r <= 0x4DBF ||
+		r >= 0x20000 && r <= 0x2A6DF ||
+		r >= 0x2A700 && r <= 0x2B73F ||
+		r >= 0x2B740 && r <= 0x2B81F ||
+		r >= 0x2B820 && r <= 0x2CEAF ||
+		r >= 0xF900 && r <= 0xFAFF ||
+		r >= 0x2F800 && r <= 0x2FA1F
+}
@@ -0,0 +1,53 @@
+package embedding
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWordPiece(t *testing.T) {
+	vocab := []string{
+		"[UNK]", "the", "un", "##aff", "##able", "cafe", "run", "##ning", "hello",
+		",", "!", ".", "'", "s", "中", "文", "go",
+	}
+
+	tests := []struct {
+		name      string
+		lowercase bool
+		text      string
+		want      []string
+	}{
+		{"whole words", true, "the cafe", []string{"the", "cafe"}},
+		{"subwords", true, "unaffable running", []string{"un", "##aff", "##able", "run", "##ning"}},
+		{"lowercase", true, "Hello THE", []string{"hello", "the"}},
+		{"case kept", false, "Hello", []string{"[UNK]"}},
+		{"accents stripped", true, "Café", []string{"cafe"}},
+		{"punctuation split", true, "hello, the cafe's!", []string{"hello", ",", "the", "cafe", "'", "s", "!"}},
+		{"cjk per character", true, "中文", []string{"中", "文"}},
+		{"unknown word", true, "xyz", []string{"[UNK]"}},
+		{"partly covered word is unknown", true, "runx", []string{"[UNK]"}},
+		{"too long", true, strings.Repeat("go", 60), []string{"[UNK]"}},
+		{"control characters dropped", true, "the\x00\u200b cafe", []string{"the", "cafe"}},
+		{"whitespace", true, " \t\nthe\n", []string{"the"}},
+		{"empty", true, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWordPiece(TokenizerInfo{
+				Vocab:         vocab,
+				Lowercase:     tt.lowercase,
+				StripAccents:  tt.lowercase,
+				SpecialTokens: 2,
+			})
+			got := w.Tokenize(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if count := w.Count(tt.text); count != len(tt.want)+2 {
+				t.Errorf("Count(%q) = %d, want %d", tt.text, count, len(tt.want)+2)
+			}
+		})
+	}
+}
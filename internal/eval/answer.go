@@ -0,0 +1,91 @@
+package eval
+
+import "edgerag/internal/rag"
+
+// AnswerMetrics score generated answers, each in [0, 1]
+type AnswerMetrics struct {
+	// Faithfulness is the fraction of answer sentences supported by the
+	// retrieved chunks
+	Faithfulness float64 `json:"faithfulness"`
+	// Relevance is how well the answer addresses the question
+	Relevance float64 `json:"relevance"`
+}
+
+// AnswerResult is the judged answer to one question
+type AnswerResult struct {
+	ID        string   `json:"id"`
+	Question  string   `json:"question"`
+	Answer    string   `json:"answer"`
+	Retrieved []string `json:"retrieved"`
+	// Abstained is set when the grounding policy declined to answer, for
+	// AbstainReason; such answers are left out of the mean metrics. Answer
+	// then holds the withheld answer, if one was generated.
+	Abstained     bool                  `json:"abstained,omitempty"`
+	AbstainReason rag.AbstainReason     `json:"abstain_reason,omitempty"`
+	Sentences     []rag.SentenceSupport `json:"sentences,omitempty"`
+	Metrics       AnswerMetrics         `json:"metrics"`
+	// RelevanceMethod is the judge that scored relevance
+	RelevanceMethod rag.JudgeMode `json:"relevance_method,omitempty"`
+	// Error is set when answering or judging failed
+	Error string `json:"error,omitempty"`
+}
+
+// Scored reports whether the result counts towards the mean metrics
+func (r *AnswerResult) Scored() bool {
+	return !r.Abstained && r.Error == ""
+}
+
+// NewAnswerResult records the judgement of an answer
+func NewAnswerResult(q Question, answer string, retrieved []string, judgement *rag.Judgement) AnswerResult {
+	return AnswerResult{
+		ID:        q.ID,
+		Question:  q.Question,
+		Answer:    answer,
+		Retrieved: retrieved,
+		Sentences: judgement.Sentences,
+		Metrics: AnswerMetrics{
+			Faithfulness: judgement.Faithfulness,
+			Relevance:    judgement.Relevance,
+		},
+		RelevanceMethod: judgement.RelevanceMethod,
+	}
+}
+
+// AnswerReport is the answer-quality evaluation of one configuration
+type AnswerReport struct {
+	Name  string        `json:"name"`
+	Judge rag.JudgeMode `json:"judge"`
+	// JudgeModel is the LLM that judged, when Judge is llm
+	JudgeModel string `json:"judge_model,omitempty"`
+	// SupportThreshold is the similarity above which the embedding judge
+	// counts a sentence as supported
+	SupportThreshold float64        `json:"support_threshold"`
+	Metrics          AnswerMetrics  `json:"metrics"`
+	Answered         int            `json:"answered"`
+	Abstained        int            `json:"abstained"`
+	Failed           int            `json:"failed"`
+	Results          []AnswerResult `json:"results"`
+}
+
+// Summarize computes the mean metrics and the answered, abstained and failed
+// counts of the report's results
+func (r *AnswerReport) Summarize() {
+	r.Metrics = AnswerMetrics{}
+	r.Answered, r.Abstained, r.Failed = 0, 0, 0
+	for _, result := range r.Results {
+		switch {
+		case result.Error != "":
+			r.Failed++
+		case result.Abstained:
+			r.Abstained++
+		default:
+			r.Answered++
+			r.Metrics.Faithfulness += result.Metrics.Faithfulness
+			r.Metrics.Relevance += result.Metrics.Relevance
+		}
+	}
+	if r.Answered > 0 {
+		r.Metrics.Faithfulness /= float64(r.Answered)
+		r.Metrics.Relevance /= float64(r.Answered)
+	}
+}
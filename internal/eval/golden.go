@@ -0,0 +1,94 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Question is one entry of a golden set: a question and what a good
+// retrieval for it contains. Golden sets are JSONL files with one question
+// per line, for example
+//
+//	{"id": "deploy-1", "question": "How do I roll back a release?", "expected_files": ["docs/deploy.md"]}
+type Question struct {
+	ID       string `json:"id,omitempty"`
+	Question string `json:"question"`
+	// ExpectedFiles are source files, as stored in the file metadata of
+	// their chunks, that should be retrieved. A path matches a file stored
+	// with a longer path ending in it.
+	ExpectedFiles []string `json:"expected_files,omitempty"`
+	// ExpectedChunks are IDs of chunks that should be retrieved
+	ExpectedChunks []string `json:"expected_chunks,omitempty"`
+	// Answer is an optional reference answer
+	Answer string `json:"answer,omitempty"`
+}
+
+// HasExpectations reports whether the question names any expected sources,
+// without which its retrieval can't be scored
+func (q *Question) HasExpectations() bool {
+	return len(q.ExpectedFiles) > 0 || len(q.ExpectedChunks) > 0
+}
+
+// LoadGoldenSet reads a JSONL golden set. Blank lines and lines starting
+// with # are skipped; questions without an id are numbered by line.
+func LoadGoldenSet(path string) ([]Question, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open golden set: %w", err)
+	}
+	defer file.Close()
+
+	var questions []Question
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var q Question
+		if err := json.Unmarshal([]byte(text), &q); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid JSON: %w", path, line, err)
+		}
+		if strings.TrimSpace(q.Question) == "" {
+			return nil, fmt.Errorf("%s:%d: missing question", path, line)
+		}
+		if q.ID == "" {
+			q.ID = fmt.Sprintf("line-%d", line)
+		}
+		questions = append(questions, q)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read golden set: %w", err)
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("golden set %s has no questions", path)
+	}
+	return questions, nil
+}
+
+// WriteGoldenSet writes questions as a JSONL golden set
+func WriteGoldenSet(path string, questions []Question) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create golden set: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, q := range questions {
+		if err := encoder.Encode(q); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write question %s: %w", q.ID, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write golden set: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,187 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"edgerag/internal/vectorstore"
+)
+
+// RetrievalMetrics are the standard ranking metrics at a cutoff k. Each
+// expected file or chunk of a question is one relevant item; a retrieved
+// chunk is relevant if it matches any of them.
+type RetrievalMetrics struct {
+	// Recall is the fraction of expected items found in the top k
+	Recall float64 `json:"recall"`
+	// Precision is the fraction of the top k that is relevant
+	Precision float64 `json:"precision"`
+	// MRR is the reciprocal rank of the first relevant chunk, 0 if none
+	MRR float64 `json:"mrr"`
+	// NDCG is the normalized discounted cumulative gain, with a gain of 1
+	// for the first chunk matching each expected item
+	NDCG float64 `json:"ndcg"`
+}
+
+// RetrievalResult is the scored retrieval for one question
+type RetrievalResult struct {
+	ID        string   `json:"id"`
+	Question  string   `json:"question"`
+	Retrieved []string `json:"retrieved"`
+	// RelevantRanks are the 1-based ranks of the relevant chunks
+	RelevantRanks []int            `json:"relevant_ranks"`
+	Missed        []string         `json:"missed,omitempty"`
+	Metrics       RetrievalMetrics `json:"metrics"`
+	// Error is set when retrieval failed; the metrics are then zero
+	Error string `json:"error,omitempty"`
+}
+
+// RetrievalReport is the evaluation of one configuration on a golden set
+type RetrievalReport struct {
+	Name string `json:"name"`
+	// Settings describe the configuration, as the caller chooses
+	Settings interface{}       `json:"settings"`
+	K        int               `json:"k"`
+	Metrics  RetrievalMetrics  `json:"metrics"`
+	Results  []RetrievalResult `json:"results"`
+}
+
+// Report is the outcome of an evaluation run, written as JSON to track
+// results over time
+type Report struct {
+	GoldenSet string `json:"golden_set"`
+	CreatedAt string `json:"created_at"`
+	Questions int    `json:"questions"`
+	// Skipped counts questions without expected sources, which are not
+	// scored for retrieval
+	Skipped        int                `json:"skipped"`
+	Configurations []*RetrievalReport `json:"configurations"`
+	// Answers holds the answer-quality evaluation of each configuration,
+	// when answers were generated
+	Answers []*AnswerReport `json:"answers,omitempty"`
+}
+
+// ScoreRetrieval scores the top k results retrieved for a question
+func ScoreRetrieval(q Question, results []*vectorstore.SearchResult, k int) RetrievalResult {
+	if len(results) > k {
+		results = results[:k]
+	}
+
+	type item struct {
+		key   string
+		match func(*vectorstore.SearchResult) bool
+	}
+	var items []item
+	for _, file := range q.ExpectedFiles {
+		file := file
+		items = append(items, item{"file:" + file, func(r *vectorstore.SearchResult) bool { return matchesFile(r, file) }})
+	}
+	for _, id := range q.ExpectedChunks {
+		id := id
+		items = append(items, item{"chunk:" + id, func(r *vectorstore.SearchResult) bool { return matchesChunk(r, id) }})
+	}
+
+	scored := RetrievalResult{ID: q.ID, Question: q.Question, Retrieved: make([]string, len(results))}
+	found := make(map[string]bool)
+	dcg := 0.0
+	for rank, result := range results {
+		scored.Retrieved[rank] = result.ID
+
+		relevant, gain := false, 0.0
+		for _, it := range items {
+			if !it.match(result) {
+				continue
+			}
+			relevant = true
+			if !found[it.key] {
+				found[it.key] = true
+				gain = 1
+			}
+		}
+		if !relevant {
+			continue
+		}
+
+		scored.RelevantRanks = append(scored.RelevantRanks, rank+1)
+		if scored.Metrics.MRR == 0 {
+			scored.Metrics.MRR = 1 / float64(rank+1)
+		}
+		dcg += gain / math.Log2(float64(rank+2))
+	}
+
+	for _, it := range items {
+		if !found[it.key] {
+			scored.Missed = append(scored.Missed, it.key)
+		}
+	}
+	if len(items) > 0 {
+		scored.Metrics.Recall = float64(len(found)) / float64(len(items))
+	}
+	if k > 0 {
+		scored.Metrics.Precision = float64(len(scored.RelevantRanks)) / float64(k)
+	}
+	ideal := 0.0
+	for rank := 0; rank < len(items) && rank < k; rank++ {
+		ideal += 1 / math.Log2(float64(rank+2))
+	}
+	if ideal > 0 {
+		scored.Metrics.NDCG = dcg / ideal
+	}
+	return scored
+}
+
+// MeanRetrievalMetrics averages the metrics of scored questions
+func MeanRetrievalMetrics(results []RetrievalResult) RetrievalMetrics {
+	var mean RetrievalMetrics
+	if len(results) == 0 {
+		return mean
+	}
+	for _, r := range results {
+		mean.Recall += r.Metrics.Recall
+		mean.Precision += r.Metrics.Precision
+		mean.MRR += r.Metrics.MRR
+		mean.NDCG += r.Metrics.NDCG
+	}
+	n := float64(len(results))
+	mean.Recall /= n
+	mean.Precision /= n
+	mean.MRR /= n
+	mean.NDCG /= n
+	return mean
+}
+
+// matchesFile reports whether a result comes from the expected file: the
+// stored path equals it or ends with it at a path separator
+func matchesFile(result *vectorstore.SearchResult, expected string) bool {
+	stored, ok := result.Metadata["file"].(string)
+	if !ok {
+		return false
+	}
+	stored = filepath.ToSlash(filepath.Clean(stored))
+	expected = filepath.ToSlash(filepath.Clean(expected))
+	return stored == expected || strings.HasSuffix(stored, "/"+expected)
+}
+
+// matchesChunk reports whether a result is the expected chunk or, in a
+// hierarchical index, the coarse chunk containing it
+func matchesChunk(result *vectorstore.SearchResult, expected string) bool {
+	if result.ID == expected {
+		return true
+	}
+	switch children := result.Metadata["child_ids"].(type) {
+	case []string:
+		for _, id := range children {
+			if id == expected {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, id := range children {
+			if fmt.Sprint(id) == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
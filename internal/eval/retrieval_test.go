@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"edgerag/internal/vectorstore"
+)
+
+// result returns a search result for a chunk of file
+func result(id, file string) *vectorstore.SearchResult {
+	return &vectorstore.SearchResult{Vector: vectorstore.Vector{ID: id, Metadata: map[string]interface{}{"file": file}}}
+}
+
+func TestScoreRetrieval(t *testing.T) {
+	coarse := result("coarse_0", "docs/guide.md")
+	coarse.Metadata["child_ids"] = []interface{}{"fine_0", "fine_1"}
+
+	tests := []struct {
+		name      string
+		question  Question
+		results   []*vectorstore.SearchResult
+		k         int
+		want      RetrievalMetrics
+		wantRanks []int
+		wantMiss  []string
+	}{
+		{
+			name:      "chunk at rank 1",
+			question:  Question{ExpectedChunks: []string{"a"}},
+			results:   []*vectorstore.SearchResult{result("a", "x.md"), result("b", "x.md"), result("c", "y.md")},
+			k:         3,
+			want:      RetrievalMetrics{Recall: 1, Precision: 1.0 / 3, MRR: 1, NDCG: 1},
+			wantRanks: []int{1},
+		},
+		{
+			name:      "chunk at rank 2",
+			question:  Question{ExpectedChunks: []string{"b"}},
+			results:   []*vectorstore.SearchResult{result("a", "x.md"), result("b", "x.md"), result("c", "y.md")},
+			k:         3,
+			want:      RetrievalMetrics{Recall: 1, Precision: 1.0 / 3, MRR: 0.5, NDCG: 1 / math.Log2(3)},
+			wantRanks: []int{2},
+		},
+		{
+			name:      "two files at ranks 1 and 3",
+			question:  Question{ExpectedFiles: []string{"x.md", "z.md"}},
+			results:   []*vectorstore.SearchResult{result("a", "x.md"), result("b", "y.md"), result("c", "z.md")},
+			k:         3,
+			want:      RetrievalMetrics{Recall: 1, Precision: 2.0 / 3, MRR: 1, NDCG: (1 + 1/math.Log2(4)) / (1 + 1/math.Log2(3))},
+			wantRanks: []int{1, 3},
+		},
+		{
+			name:      "repeated file counts once for recall and nDCG",
+			question:  Question{ExpectedFiles: []string{"x.md"}},
+			results:   []*vectorstore.SearchResult{result("a", "x.md"), result("b", "x.md")},
+			k:         2,
+			want:      RetrievalMetrics{Recall: 1, Precision: 1, MRR: 1, NDCG: 1},
+			wantRanks: []int{1, 2},
+		},
+		{
+			name:     "miss",
+			question: Question{ExpectedFiles: []string{"z.md"}, ExpectedChunks: []string{"q"}},
+			results:  []*vectorstore.SearchResult{result("a", "x.md")},
+			k:        3,
+			wantMiss: []string{"file:z.md", "chunk:q"},
+		},
+		{
+			name:     "results beyond k are ignored",
+			question: Question{ExpectedChunks: []string{"d"}},
+			results:  []*vectorstore.SearchResult{result("a", "x.md"), result("b", "x.md"), result("c", "x.md"), result("d", "x.md")},
+			k:        3,
+			wantMiss: []string{"chunk:d"},
+		},
+		{
+			name:      "file matches a trailing part of the stored path",
+			question:  Question{ExpectedFiles: []string{"docs/deploy.md"}},
+			results:   []*vectorstore.SearchResult{result("a", "/home/me/mydocs/deploy.md"), result("b", "/home/me/docs/deploy.md")},
+			k:         2,
+			want:      RetrievalMetrics{Recall: 1, Precision: 0.5, MRR: 0.5, NDCG: 1 / math.Log2(3)},
+			wantRanks: []int{2},
+		},
+		{
+			name:      "coarse chunk matches its fine chunks",
+			question:  Question{ExpectedChunks: []string{"fine_1"}},
+			results:   []*vectorstore.SearchResult{coarse},
+			k:         1,
+			want:      RetrievalMetrics{Recall: 1, Precision: 1, MRR: 1, NDCG: 1},
+			wantRanks: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScoreRetrieval(tt.question, tt.results, tt.k)
+			metrics := []struct {
+				name      string
+				got, want float64
+			}{
+				{"recall", got.Metrics.Recall, tt.want.Recall},
+				{"precision", got.Metrics.Precision, tt.want.Precision},
+				{"MRR", got.Metrics.MRR, tt.want.MRR},
+				{"nDCG", got.Metrics.NDCG, tt.want.NDCG},
+			}
+			for _, m := range metrics {
+				if math.Abs(m.got-m.want) > 1e-9 {
+					t.Errorf("%s = %.4f, want %.4f", m.name, m.got, m.want)
+				}
+			}
+			if !reflect.DeepEqual(got.RelevantRanks, tt.wantRanks) {
+				t.Errorf("relevant ranks = %v, want %v", got.RelevantRanks, tt.wantRanks)
+			}
+			if !reflect.DeepEqual(got.Missed, tt.wantMiss) {
+				t.Errorf("missed = %v, want %v", got.Missed, tt.wantMiss)
+			}
+		})
+	}
+}
+
+func TestMeanRetrievalMetrics(t *testing.T) {
+	results := []RetrievalResult{
+		{Metrics: RetrievalMetrics{Recall: 1, Precision: 0.5, MRR: 1, NDCG: 1}},
+		{Metrics: RetrievalMetrics{Recall: 0, Precision: 0, MRR: 0, NDCG: 0}},
+	}
+	want := RetrievalMetrics{Recall: 0.5, Precision: 0.25, MRR: 0.5, NDCG: 0.5}
+	if got := MeanRetrievalMetrics(results); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := MeanRetrievalMetrics(nil); got != (RetrievalMetrics{}) {
+		t.Errorf("empty: got %+v, want zero", got)
+	}
+}
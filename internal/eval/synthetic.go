@@ -0,0 +1,218 @@
+package eval
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+
+	"edgerag/internal/llm"
+	"edgerag/internal/vectorstore"
+)
+
+// SampleOptions select the chunks questions are generated from
+type SampleOptions struct {
+	// Count is the number of chunks to sample
+	Count int
+	// MinLength is the shortest chunk, in characters, worth asking about
+	MinLength int
+	// Filter restricts sampling to chunks whose metadata matches
+	Filter vectorstore.Filter
+	// Seed makes the sample reproducible
+	Seed int64
+}
+
+// SampleChunks picks up to Count chunks at random from the store. The
+// sample depends only on the seed and the stored IDs, not on the order the
+// store lists them in. Fine chunks of a hierarchical index are skipped,
+// since questions about them are matched through their coarse chunks.
+func SampleChunks(store vectorstore.VectorStore, options SampleOptions) ([]*vectorstore.Vector, error) {
+	ids := store.List()
+	sort.Strings(ids)
+
+	rng := rand.New(rand.NewSource(options.Seed))
+	var sample []*vectorstore.Vector
+	for _, i := range rng.Perm(len(ids)) {
+		if len(sample) == options.Count {
+			break
+		}
+		vector, err := store.Get(ids[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chunk %s: %w", ids[i], err)
+		}
+		if len(strings.TrimSpace(vector.Content)) < options.MinLength {
+			continue
+		}
+		if level, _ := vector.Metadata["chunk_level"].(string); level == "fine" {
+			continue
+		}
+		if len(options.Filter) > 0 && !options.Filter.Matches(vector.Metadata) {
+			continue
+		}
+		sample = append(sample, vector)
+	}
+	return sample, nil
+}
+
+// Rejection records a generated question dropped by the quality filter
+type Rejection struct {
+	Question string `json:"question"`
+	ChunkID  string `json:"chunk_id"`
+	Reason   string `json:"reason"`
+}
+
+const questionPrompt = `Write %d different questions that a user of this documentation might ask and that the following passage answers.
+
+Each question must:
+- be answerable from the passage alone
+- make sense on its own, without having seen the passage: name the specific subject instead of saying "the passage", "the text" or "this section"
+- ask about a fact, step or explanation in the passage, not about its wording
+- not copy whole sentences from the passage
+
+Passage:
+%s
+
+Reply with one question per line and nothing else.`
+
+// Question length bounds, in words
+const (
+	minQuestionWords = 4
+	maxQuestionWords = 40
+)
+
+// copiedSpanWords is the length of a run of words copied from the passage
+// that makes a question too easy to retrieve by string matching
+const copiedSpanWords = 8
+
+// passageReference matches questions that only make sense next to the passage
+var passageReference = regexp.MustCompile(`(?i)\b(?:the|this|that|above|given) (?:passage|text|excerpt|section|document|paragraph|context|snippet|author)\b|\baccording to (?:the|this)\b|\bmentioned above\b`)
+
+// questionNumber matches the numbering of a question in a list
+var questionNumber = regexp.MustCompile(`^(?:Q(?:uestion)?\s*)?\d+[.):]\s*`)
+
+// QuestionGenerator writes questions answerable by a chunk with the LLM and
+// filters out low-quality ones
+type QuestionGenerator struct {
+	llm      *llm.OllamaClient
+	perChunk int
+	seen     map[string]bool
+}
+
+// NewQuestionGenerator creates a generator asking for perChunk questions
+// per chunk. Questions are deduplicated across all chunks it is given.
+func NewQuestionGenerator(llmClient *llm.OllamaClient, perChunk int) *QuestionGenerator {
+	return &QuestionGenerator{
+		llm:      llmClient,
+		perChunk: perChunk,
+		seen:     make(map[string]bool),
+	}
+}
+
+// Generate returns the accepted questions for a chunk, each expecting the
+// chunk itself, and the rejected ones with the reason
+func (g *QuestionGenerator) Generate(chunk *vectorstore.Vector) ([]Question, []Rejection, error) {
+	reply, err := g.llm.Generate(fmt.Sprintf(questionPrompt, g.perChunk, strings.TrimSpace(chunk.Content)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate questions: %w", err)
+	}
+
+	var accepted []Question
+	var rejected []Rejection
+	for _, line := range strings.Split(reply, "\n") {
+		question := cleanQuestion(line)
+		if question == "" {
+			continue
+		}
+		if len(accepted) == g.perChunk {
+			break
+		}
+		if reason := g.reject(question, chunk.Content); reason != "" {
+			rejected = append(rejected, Rejection{Question: question, ChunkID: chunk.ID, Reason: reason})
+			continue
+		}
+		g.seen[normalizeQuestion(question)] = true
+		accepted = append(accepted, Question{Question: question, ExpectedChunks: []string{chunk.ID}})
+	}
+	return accepted, rejected, nil
+}
+
+// reject returns why a question fails the quality filter, or "" if it passes
+func (g *QuestionGenerator) reject(question, passage string) string {
+	words := strings.Fields(question)
+	switch {
+	case !strings.HasSuffix(question, "?"):
+		return "not a question"
+	case len(words) < minQuestionWords:
+		return "too short"
+	case len(words) > maxQuestionWords:
+		return "too long"
+	case passageReference.MatchString(question):
+		return "refers to the passage"
+	case g.seen[normalizeQuestion(question)]:
+		return "duplicate"
+	}
+
+	passageWords := contentWords(passage)
+	shared := false
+	for word := range contentWords(question) {
+		if passageWords[word] {
+			shared = true
+			break
+		}
+	}
+	if !shared {
+		return "shares no terms with the passage"
+	}
+	if copiesSpan(question, passage, copiedSpanWords) {
+		return "copies the passage"
+	}
+	return ""
+}
+
+// cleanQuestion strips list markers, labels and quotes from a reply line
+func cleanQuestion(line string) string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimLeft(line, "-*• ")
+	line = questionNumber.ReplaceAllString(line, "")
+	if label, rest, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(label), "question") {
+		line = rest
+	}
+	return strings.Trim(line, " \"'`*")
+}
+
+// normalizeQuestion returns the form questions are deduplicated by
+func normalizeQuestion(question string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.TrimRight(question, "?!. "))), " ")
+}
+
+// wordPattern matches the words compared between questions and passages
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// contentWords returns the lowercased words of text long enough to carry
+// meaning
+func contentWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if len(word) >= 4 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// copiesSpan reports whether question contains a run of n consecutive
+// words of the passage
+func copiesSpan(question, passage string, n int) bool {
+	questionWords := wordPattern.FindAllString(strings.ToLower(question), -1)
+	if len(questionWords) < n {
+		return false
+	}
+	passageText := " " + strings.Join(wordPattern.FindAllString(strings.ToLower(passage), -1), " ") + " "
+	for i := 0; i+n <= len(questionWords); i++ {
+		if strings.Contains(passageText, " "+strings.Join(questionWords[i:i+n], " ")+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -15,13 +16,68 @@ type OllamaClient struct {
 	baseURL string
 	model   string
 	client  *http.Client
+	options *GenerateOptions
 }
 
 // OllamaRequest represents a request to the Ollama API
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model     string           `json:"model"`
+	Prompt    string           `json:"prompt"`
+	Stream    bool             `json:"stream"`
+	Options   *GenerateOptions `json:"options,omitempty"`
+	KeepAlive interface{}      `json:"keep_alive,omitempty"`
+}
+
+// GenerateOptions are model parameters sent with requests. Unset fields
+// keep the model's defaults.
+type GenerateOptions struct {
+	// Temperature controls randomness; 0 always picks the likeliest token
+	Temperature *float64 `json:"temperature,omitempty"`
+	// TopP samples from the smallest set of tokens whose probabilities add
+	// up to at least this
+	TopP *float64 `json:"top_p,omitempty"`
+	// NumCtx is the context window in tokens, prompt included
+	NumCtx *int `json:"num_ctx,omitempty"`
+	// NumPredict is the maximum number of tokens to generate (-1 for no limit)
+	NumPredict *int `json:"num_predict,omitempty"`
+	// Seed makes generation reproducible for the same model and prompt
+	Seed *int `json:"seed,omitempty"`
+	// Stop sequences end generation when the model produces one of them
+	Stop []string `json:"stop,omitempty"`
+	// KeepAlive is how long Ollama keeps the model loaded after a request,
+	// as a duration ("10m") or a number of seconds (-1 for ever). It is
+	// sent alongside the options rather than among them.
+	KeepAlive string `json:"-"`
+}
+
+// Message is one message of a chat
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Chat message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ChatRequest represents a request to the Ollama chat API
+type ChatRequest struct {
+	Model     string           `json:"model"`
+	Messages  []Message        `json:"messages"`
+	Stream    bool             `json:"stream"`
+	Options   *GenerateOptions `json:"options,omitempty"`
+	KeepAlive interface{}      `json:"keep_alive,omitempty"`
+}
+
+// ChatResponse represents a response from the Ollama chat API
+type ChatResponse struct {
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Message   Message   `json:"message"`
+	Done      bool      `json:"done"`
 }
 
 // OllamaResponse represents a response from the Ollama API
@@ -54,27 +110,19 @@ func NewOllamaClient(baseURL, model string) (*OllamaClient, error) {
 // Generate generates text using the Ollama model
 func (c *OllamaClient) Generate(prompt string) (string, error) {
 	request := OllamaRequest{
-		Model:  c.model,
-		Prompt: prompt,
-		Stream: false,
-	}
-
-	requestBody, err := json.Marshal(request)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		Model:     c.model,
+		Prompt:    prompt,
+		Stream:    false,
+		Options:   c.samplingOptions(),
+		KeepAlive: c.keepAlive(),
 	}
 
-	resp, err := c.client.Post(c.baseURL+"/api/generate", "application/json", bytes.NewBuffer(requestBody))
+	resp, err := c.post("/api/generate", request)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
 	var response OllamaResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
@@ -86,30 +134,84 @@ func (c *OllamaClient) Generate(prompt string) (string, error) {
 // GenerateStream generates text using the Ollama model with streaming
 func (c *OllamaClient) GenerateStream(prompt string, callback func(string)) error {
 	request := OllamaRequest{
-		Model:  c.model,
-		Prompt: prompt,
-		Stream: true,
+		Model:     c.model,
+		Prompt:    prompt,
+		Stream:    true,
+		Options:   c.samplingOptions(),
+		KeepAlive: c.keepAlive(),
 	}
 
-	requestBody, err := json.Marshal(request)
+	resp, err := c.post("/api/generate", request)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return err
 	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var response OllamaResponse
+		if err := decoder.Decode(&response); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to decode streaming response: %w", err)
+		}
 
-	resp, err := c.client.Post(c.baseURL+"/api/generate", "application/json", bytes.NewBuffer(requestBody))
+		if response.Response != "" {
+			callback(response.Response)
+		}
+
+		if response.Done {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Chat generates the next assistant message of a chat
+func (c *OllamaClient) Chat(messages []Message) (string, error) {
+	request := ChatRequest{
+		Model:     c.model,
+		Messages:  messages,
+		Stream:    false,
+		Options:   c.options,
+		KeepAlive: c.keepAlive(),
+	}
+
+	resp, err := c.post("/api/chat", request)
 	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	var response ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	return response.Message.Content, nil
+}
+
+// ChatStream generates the next assistant message of a chat with streaming
+func (c *OllamaClient) ChatStream(messages []Message, callback func(string)) error {
+	request := ChatRequest{
+		Model:     c.model,
+		Messages:  messages,
+		Stream:    true,
+		Options:   c.options,
+		KeepAlive: c.keepAlive(),
+	}
+
+	resp, err := c.post("/api/chat", request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
 	decoder := json.NewDecoder(resp.Body)
 	for {
-		var response OllamaResponse
+		var response ChatResponse
 		if err := decoder.Decode(&response); err != nil {
 			if err == io.EOF {
 				break
@@ -117,8 +219,8 @@ func (c *OllamaClient) GenerateStream(prompt string, callback func(string)) erro
 			return fmt.Errorf("failed to decode streaming response: %w", err)
 		}
 
-		if response.Response != "" {
-			callback(response.Response)
+		if response.Message.Content != "" {
+			callback(response.Message.Content)
 		}
 
 		if response.Done {
@@ -129,6 +231,40 @@ func (c *OllamaClient) GenerateStream(prompt string, callback func(string)) erro
 	return nil
 }
 
+// post sends a request to an API endpoint, returning the response if it
+// succeeded
+func (c *OllamaClient) post(path string, request interface{}) (*http.Response, error) {
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.client.Post(c.baseURL+path, "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+// keepAlive returns the keep_alive of a request: Ollama takes a number of
+// seconds as a number and anything else as a duration string
+func (c *OllamaClient) keepAlive() interface{} {
+	if c.options == nil || c.options.KeepAlive == "" {
+		return nil
+	}
+	if seconds, err := strconv.ParseFloat(c.options.KeepAlive, 64); err == nil {
+		return seconds
+	}
+	return c.options.KeepAlive
+}
+
 // ping tests the connection to the Ollama server
 func (c *OllamaClient) ping() error {
 	resp, err := c.client.Get(c.baseURL + "/api/tags")
@@ -174,6 +310,27 @@ func (c *OllamaClient) ListModels() ([]string, error) {
 	return models, nil
 }
 
+// SetOptions sets the model parameters. Chat and ChatStream, which answer
+// the user, send all of them. Generate and GenerateStream, which serve the
+// internal prompts whose replies are parsed, send only the sampling options
+// (see samplingOptions).
+func (c *OllamaClient) SetOptions(options GenerateOptions) {
+	c.options = &options
+}
+
+// samplingOptions returns the options without stop sequences or length and
+// context limits, which could cut short replies that are parsed line by line
+func (c *OllamaClient) samplingOptions() *GenerateOptions {
+	if c.options == nil {
+		return nil
+	}
+	return &GenerateOptions{
+		Temperature: c.options.Temperature,
+		TopP:        c.options.TopP,
+		Seed:        c.options.Seed,
+	}
+}
+
 // SetModel changes the model used for generation
 func (c *OllamaClient) SetModel(model string) {
 	c.model = model
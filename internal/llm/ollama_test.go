@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptionsPerCall(t *testing.T) {
+	sent := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		options, _ := request["options"].(map[string]interface{})
+		sent[r.URL.Path] = options
+		switch r.URL.Path {
+		case "/api/generate":
+			json.NewEncoder(w).Encode(OllamaResponse{Response: "ok", Done: true})
+		case "/api/chat":
+			json.NewEncoder(w).Encode(ChatResponse{Message: Message{Role: RoleAssistant, Content: "ok"}, Done: true})
+		default:
+			w.Write([]byte(`{"models": []}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewOllamaClient(server.URL, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	temperature, numPredict, numCtx := 0.2, 5, 512
+	client.SetOptions(GenerateOptions{Temperature: &temperature, NumPredict: &numPredict, NumCtx: &numCtx, Stop: []string{"\n"}})
+
+	if _, err := client.Chat([]Message{{Role: RoleUser, Content: "q"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Generate("q"); err != nil {
+		t.Fatal(err)
+	}
+
+	chat := sent["/api/chat"]
+	for _, key := range []string{"temperature", "num_predict", "num_ctx", "stop"} {
+		if _, ok := chat[key]; !ok {
+			t.Errorf("chat request lacks %s: %v", key, chat)
+		}
+	}
+	generate := sent["/api/generate"]
+	if generate["temperature"] != temperature {
+		t.Errorf("generate request temperature = %v, want %v", generate["temperature"], temperature)
+	}
+	for _, key := range []string{"num_predict", "num_ctx", "stop"} {
+		if _, ok := generate[key]; ok {
+			t.Errorf("generate request has %s: %v", key, generate)
+		}
+	}
+}
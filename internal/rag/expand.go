@@ -0,0 +1,299 @@
+package rag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"edgerag/internal/vectorstore"
+)
+
+// ExpansionMode selects how matched chunks are widened before they are given
+// to the LLM. Small chunks match questions precisely but often lack the
+// surrounding text needed to answer them.
+type ExpansionMode string
+
+const (
+	// ExpandNone passes the matched chunks as they are
+	ExpandNone ExpansionMode = "none"
+	// ExpandNeighbors adds the chunks up to a window either side of each match
+	ExpandNeighbors ExpansionMode = "neighbors"
+	// ExpandParent replaces each match with its enclosing section, for
+	// chunks that record a heading path, or else its whole document, up to
+	// a character budget around the match
+	ExpandParent ExpansionMode = "parent"
+)
+
+// DefaultParentMaxChars bounds the text ExpandParent takes around a match
+// when no budget is set
+const DefaultParentMaxChars = 4000
+
+// minMergeOverlap is the shortest repeated text between consecutive chunks
+// that is treated as chunk overlap and removed when they are joined
+const minMergeOverlap = 16
+
+// ParseExpansionMode converts a mode name (as used in flags and config files)
+// to an ExpansionMode
+func ParseExpansionMode(name string) (ExpansionMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "none", "off":
+		return ExpandNone, nil
+	case "neighbors", "neighbours", "window":
+		return ExpandNeighbors, nil
+	case "parent", "section", "document":
+		return ExpandParent, nil
+	default:
+		return "", fmt.Errorf("unknown context expansion %q (expected none, neighbors or parent)", name)
+	}
+}
+
+// expandResults widens each result according to the pipeline's expansion
+// mode. Results from the same document whose expansions overlap or touch
+// are merged into one passage, scored by its best match and placed where
+// that match ranked. Results without parent_id and chunk_index metadata are
+// kept as they are.
+func (p *Pipeline) expandResults(results []*vectorstore.SearchResult) ([]*vectorstore.SearchResult, error) {
+	if p.expansion == ExpandNone || p.expansion == "" {
+		return results, nil
+	}
+
+	type group struct {
+		key      string
+		best     *vectorstore.SearchResult   // best match in the document
+		siblings map[int]*vectorstore.Vector // the document's chunks by index
+		selected map[int]bool
+	}
+	groups := make(map[string]*group)
+	var order []*group
+	var passages []*vectorstore.SearchResult
+	var ranks []int
+
+	for rank, result := range results {
+		parentID, _ := result.Metadata["parent_id"].(string)
+		index, ok := metadataInt(result.Metadata["chunk_index"])
+		if parentID == "" || !ok {
+			passages = append(passages, result)
+			ranks = append(ranks, rank)
+			continue
+		}
+
+		key := expansionKey(result.Metadata)
+		g, ok := groups[key]
+		if !ok {
+			// In a hierarchical index, only chunks of the same level
+			siblingFilter := vectorstore.Filter{"parent_id": parentID}
+			if level, ok := result.Metadata["chunk_level"]; ok {
+				siblingFilter["chunk_level"] = level
+			}
+			vectors, err := vectorstore.FindByMetadata(p.vectorStore, siblingFilter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up chunks of %s: %w", parentID, err)
+			}
+			g = &group{
+				key:      key,
+				best:     result,
+				siblings: make(map[int]*vectorstore.Vector, len(vectors)),
+				selected: make(map[int]bool),
+			}
+			for _, vector := range vectors {
+				if i, ok := metadataInt(vector.Metadata["chunk_index"]); ok {
+					g.siblings[i] = vector
+				}
+			}
+			groups[key] = g
+			order = append(order, g)
+		}
+
+		selected := []int{index}
+		switch p.expansion {
+		case ExpandNeighbors:
+			for i := index - p.expansionWindow; i <= index+p.expansionWindow; i++ {
+				selected = append(selected, i)
+			}
+		case ExpandParent:
+			maxChars := p.expansionMaxChars
+			if maxChars <= 0 {
+				maxChars = DefaultParentMaxChars
+			}
+			path, _ := result.Metadata["heading_path"].(string)
+			selected = append(selected, sectionAround(g.siblings, index, path, len(result.Content), maxChars)...)
+		}
+		for _, i := range selected {
+			if _, ok := g.siblings[i]; ok || i == index {
+				g.selected[i] = true
+			}
+		}
+	}
+
+	// Each run of consecutive selected chunks becomes one passage, ranked by
+	// the best match it contains
+	for _, g := range order {
+		indexes := make([]int, 0, len(g.selected))
+		for i := range g.selected {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+
+		for start := 0; start < len(indexes); {
+			end := start + 1
+			for end < len(indexes) && indexes[end] == indexes[end-1]+1 {
+				end++
+			}
+			run := indexes[start:end]
+			start = end
+
+			var best *vectorstore.SearchResult
+			rank := len(results)
+			for r, result := range results {
+				if expansionKey(result.Metadata) != g.key {
+					continue
+				}
+				if i, _ := metadataInt(result.Metadata["chunk_index"]); i >= run[0] && i <= run[len(run)-1] && r < rank {
+					best, rank = result, r
+				}
+			}
+			if best == nil {
+				// A stretch of the section with no match of its own
+				best = g.best
+			}
+			passages = append(passages, mergeRun(best, run, g.siblings))
+			ranks = append(ranks, rank)
+		}
+	}
+
+	byRank := make([]int, len(passages))
+	for i := range byRank {
+		byRank[i] = i
+	}
+	sort.SliceStable(byRank, func(a, b int) bool {
+		return ranks[byRank[a]] < ranks[byRank[b]]
+	})
+	expanded := make([]*vectorstore.SearchResult, len(byRank))
+	for i, j := range byRank {
+		expanded[i] = passages[j]
+	}
+	return expanded, nil
+}
+
+// mergeRun joins a run of consecutive chunks into one passage with the score
+// of the best match, and its metadata when the match lies within the run
+func mergeRun(best *vectorstore.SearchResult, run []int, siblings map[int]*vectorstore.Vector) *vectorstore.SearchResult {
+	index, _ := metadataInt(best.Metadata["chunk_index"])
+	if len(run) == 1 && run[0] == index {
+		return best
+	}
+	base := best.Vector
+	if index < run[0] || index > run[len(run)-1] {
+		base = *siblings[run[0]]
+	}
+
+	var content string
+	for i, index := range run {
+		text := best.Content
+		if sibling, ok := siblings[index]; ok {
+			text = sibling.Content
+		}
+		if i == 0 {
+			content = text
+			continue
+		}
+		content = joinOverlapping(content, text)
+	}
+
+	metadata := make(map[string]interface{}, len(base.Metadata)+1)
+	for k, v := range base.Metadata {
+		metadata[k] = v
+	}
+	if len(run) > 1 {
+		metadata["chunk_range"] = fmt.Sprintf("%d-%d", run[0], run[len(run)-1])
+	}
+
+	return &vectorstore.SearchResult{
+		Vector: vectorstore.Vector{
+			ID:       base.ID,
+			Content:  content,
+			Metadata: metadata,
+		},
+		Score: best.Score,
+	}
+}
+
+// joinOverlapping appends next to text, dropping the start of next when it
+// repeats the end of text, as consecutive chunks do when indexed with overlap
+func joinOverlapping(text, next string) string {
+	longest := len(next)
+	if len(text) < longest {
+		longest = len(text)
+	}
+	for n := longest; n >= minMergeOverlap; n-- {
+		if strings.HasSuffix(text, next[:n]) {
+			return text + next[n:]
+		}
+	}
+	return text + "\n\n" + next
+}
+
+// expansionKey identifies the chunks a result can be expanded with: those of
+// the same document and, in a hierarchical index, the same level
+func expansionKey(metadata map[string]interface{}) string {
+	parentID, _ := metadata["parent_id"].(string)
+	level, _ := metadata["chunk_level"].(string)
+	return parentID + "\x00" + level
+}
+
+// sectionAround returns the indexes of the chunks in the section under path
+// around the chunk at index, taken outward from it alternately before and
+// after while their text, with the used characters of the chunk itself,
+// fits in maxChars. A section ends at its first chunk outside the path.
+func sectionAround(siblings map[int]*vectorstore.Vector, index int, path string, used int, maxChars int) []int {
+	var span []int
+	before, after := index-1, index+1
+	beforeOpen, afterOpen := true, true
+	take := func(i int) bool {
+		sibling, ok := siblings[i]
+		if !ok || !inSection(sibling.Metadata, path) || used+len(sibling.Content) > maxChars {
+			return false
+		}
+		used += len(sibling.Content)
+		span = append(span, i)
+		return true
+	}
+	for beforeOpen || afterOpen {
+		if beforeOpen {
+			if beforeOpen = take(before); beforeOpen {
+				before--
+			}
+		}
+		if afterOpen {
+			if afterOpen = take(after); afterOpen {
+				after++
+			}
+		}
+	}
+	return span
+}
+
+// inSection reports whether a chunk falls under the heading path, including
+// its subsections. Every chunk is in the section of a chunk without one.
+func inSection(metadata map[string]interface{}, path string) bool {
+	if path == "" {
+		return true
+	}
+	own, _ := metadata["heading_path"].(string)
+	return own == path || strings.HasPrefix(own, path+" > ")
+}
+
+// metadataInt reads an integer metadata value, which is a float64 once the
+// metadata has been through JSON
+func metadataInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
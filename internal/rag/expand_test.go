@@ -0,0 +1,45 @@
+package rag
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"edgerag/internal/vectorstore"
+)
+
+func TestSectionAround(t *testing.T) {
+	paths := []string{"Title", "Title > A", "Title > A", "Title > A > Deep", "Title > B", "Title > B", ""}
+	siblings := make(map[int]*vectorstore.Vector)
+	for i, path := range paths {
+		metadata := map[string]interface{}{}
+		if path != "" {
+			metadata["heading_path"] = path
+		}
+		siblings[i] = &vectorstore.Vector{Content: strings.Repeat("x", 100), Metadata: metadata}
+	}
+
+	tests := []struct {
+		name     string
+		index    int
+		path     string
+		maxChars int
+		want     []int
+	}{
+		{"section with subsection", 2, "Title > A", 1000, []int{1, 3}},
+		{"top-level title capped", 0, "Title", 300, []int{1, 2}},
+		{"budget alternates sides", 3, "Title", 300, []int{2, 4}},
+		{"no heading path takes the document", 6, "", 500, []int{2, 3, 4, 5}},
+		{"budget too small", 2, "Title > A", 150, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sectionAround(siblings, tt.index, tt.path, 100, tt.maxChars)
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sectionAround(%d, %q, %d) = %v, want %v", tt.index, tt.path, tt.maxChars, got, tt.want)
+			}
+		})
+	}
+}
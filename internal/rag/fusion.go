@@ -0,0 +1,94 @@
+package rag
+
+import (
+	"sort"
+
+	"edgerag/internal/vectorstore"
+)
+
+// rrfK is the reciprocal rank fusion constant: a result ranked r in a list
+// contributes 1/(rrfK+r), which keeps the top few ranks of any one list from
+// dominating the fused order
+const rrfK = 60
+
+// multiQueryDepth is how many results per wanted result each query of a
+// multi-query search retrieves, so that chunks ranked lower by every query
+// can still be fused into the top
+const multiQueryDepth = 2
+
+// search retrieves with each query embedding and, when there are several,
+// fuses their rankings with reciprocal rank fusion. The query text, for
+// hybrid retrieval, goes with the first embedding.
+func (p *Pipeline) search(query string, embeddings [][]float32, topK int, threshold float32) ([]*vectorstore.SearchResult, error) {
+	if len(embeddings) == 1 {
+		return p.retrieve(query, embeddings[0], topK, threshold)
+	}
+
+	lists := make([][]*vectorstore.SearchResult, 0, len(embeddings))
+	for i, embedding := range embeddings {
+		text := ""
+		if i == 0 {
+			text = query
+		}
+		results, err := p.retrieve(text, embedding, topK*multiQueryDepth, threshold)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, results)
+	}
+	return fuseRankings(lists, topK), nil
+}
+
+// fuseRankings merges ranked lists by reciprocal rank fusion: each result
+// scores the sum of 1/(rrfK+rank) over the lists it appears in, and the topK
+// best are returned. A fused result keeps its highest similarity as Score
+// and records its fusion score as rrf_score.
+func fuseRankings(lists [][]*vectorstore.SearchResult, topK int) []*vectorstore.SearchResult {
+	type fused struct {
+		result *vectorstore.SearchResult
+		score  float64
+		first  int // position of first appearance, to break ties stably
+	}
+	byID := make(map[string]*fused)
+	var order []*fused
+
+	for _, list := range lists {
+		for rank, result := range list {
+			f, ok := byID[result.ID]
+			if !ok {
+				f = &fused{result: result, first: len(order)}
+				byID[result.ID] = f
+				order = append(order, f)
+			}
+			f.score += 1 / float64(rrfK+rank+1)
+			if result.Score > f.result.Score {
+				f.result = result
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].score != order[j].score {
+			return order[i].score > order[j].score
+		}
+		return order[i].first < order[j].first
+	})
+	if len(order) > topK {
+		order = order[:topK]
+	}
+
+	results := make([]*vectorstore.SearchResult, len(order))
+	for i, f := range order {
+		// Metadata maps may be shared with the store, so annotate a copy
+		metadata := make(map[string]interface{}, len(f.result.Metadata)+1)
+		for k, v := range f.result.Metadata {
+			metadata[k] = v
+		}
+		metadata["rrf_score"] = f.score
+
+		result := *f.result
+		result.Metadata = metadata
+		results[i] = &result
+	}
+	return results
+}
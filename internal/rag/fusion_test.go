@@ -0,0 +1,45 @@
+package rag
+
+import (
+	"reflect"
+	"testing"
+
+	"edgerag/internal/vectorstore"
+)
+
+func TestRetrieveHybrid(t *testing.T) {
+	store, err := vectorstore.Open(vectorstore.BackendSQLite, t.TempDir(), vectorstore.Options{Lock: vectorstore.LockExclusive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Add("deploy", []float32{1, 0}, "Deployments run nightly.", nil)
+	store.Add("rollout", []float32{0.9, 0.1}, "Rollouts are staged.", nil)
+	store.Add("e1042", []float32{0, 1}, "Error E1042 means the token expired.", nil)
+
+	ids := func(results []*vectorstore.SearchResult) []string {
+		var got []string
+		for _, result := range results {
+			got = append(got, result.ID)
+		}
+		return got
+	}
+
+	p := &Pipeline{vectorStore: store}
+	results, err := p.retrieve("error E1042", []float32{1, 0}, 2, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(results), []string{"deploy", "rollout"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("similarity only: got %v, want %v", got, want)
+	}
+
+	p.SetHybrid(true)
+	results, err = p.retrieve("error E1042", []float32{1, 0}, 2, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(results), []string{"deploy", "e1042"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hybrid: got %v, want %v", got, want)
+	}
+}
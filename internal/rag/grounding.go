@@ -0,0 +1,165 @@
+package rag
+
+import (
+	"errors"
+	"fmt"
+
+	"edgerag/internal/vectorstore"
+)
+
+// GroundingPolicy decides when the retrieved context is too weak to answer
+// from. Scores are on the store metric's scale, like the search threshold.
+// The zero policy only declines when nothing is retrieved.
+type GroundingPolicy struct {
+	// MinTopScore declines to answer when the best match scores below it
+	MinTopScore float32
+	// MinMargin declines to answer when the best match leads the mean of
+	// the other matches by less than this, i.e. when nothing stands out
+	// from a handful of equally weak matches
+	MinMargin float32
+	// Verify checks each sentence of the generated answer against the
+	// context the LLM was given, as JudgeAnswer does, and declines when too
+	// few are supported
+	Verify bool
+	// VerifyJudge is the judge the verifier uses
+	VerifyJudge JudgeMode
+	// MinSupported is the fraction of answer sentences the verifier must
+	// find supported
+	MinSupported float64
+	// SupportThreshold applies to embedding verdicts of the verifier;
+	// 0 means DefaultSupportThreshold
+	SupportThreshold float64
+}
+
+// DefaultMinSupported is the fraction of answer sentences the verifier
+// requires to be supported by default; a little below 1 so that framing
+// sentences don't void an answer
+const DefaultMinSupported = 0.75
+
+// AbstainReason identifies the grounding check that failed
+type AbstainReason string
+
+const (
+	// AbstainNoResults means no chunk matched above the search threshold
+	AbstainNoResults AbstainReason = "no_results"
+	// AbstainLowScore means the best match scored below MinTopScore
+	AbstainLowScore AbstainReason = "low_score"
+	// AbstainLowMargin means the best match didn't lead by MinMargin
+	AbstainLowMargin AbstainReason = "low_margin"
+	// AbstainUnsupported means the verifier rejected the generated answer
+	AbstainUnsupported AbstainReason = "unsupported_answer"
+)
+
+// ErrInsufficientContext matches, with errors.Is, the error Query and
+// QueryStream return when the grounding policy declines to answer
+var ErrInsufficientContext = errors.New("insufficient context to answer")
+
+// InsufficientContextError describes why the pipeline declined to answer.
+// The results retrieved are still returned alongside it.
+type InsufficientContextError struct {
+	Reason AbstainReason `json:"reason"`
+	// TopScore is the score of the best match
+	TopScore float32 `json:"top_score"`
+	// Margin is the lead of the best match over the mean of the others, or
+	// 0 when there was only one match
+	Margin float32 `json:"margin"`
+	// Policy is the policy that was applied
+	Policy GroundingPolicy `json:"-"`
+	// Answer is the answer the verifier rejected
+	Answer string `json:"answer,omitempty"`
+	// Judgement is the verifier's verdict on Answer
+	Judgement *Judgement `json:"judgement,omitempty"`
+}
+
+func (e *InsufficientContextError) Error() string {
+	switch e.Reason {
+	case AbstainNoResults:
+		return "no indexed content matched the question"
+	case AbstainLowScore:
+		return fmt.Sprintf("the best match scores %.3f, below the minimum of %.3f", e.TopScore, e.Policy.MinTopScore)
+	case AbstainLowMargin:
+		return fmt.Sprintf("the best match leads the others by only %.3f, below the minimum margin of %.3f", e.Margin, e.Policy.MinMargin)
+	case AbstainUnsupported:
+		return fmt.Sprintf("only %.0f%% of the answer is supported by the retrieved context, below the minimum of %.0f%%",
+			100*e.Judgement.Faithfulness, 100*e.Policy.MinSupported)
+	default:
+		return ErrInsufficientContext.Error()
+	}
+}
+
+// Is makes the error match ErrInsufficientContext
+func (e *InsufficientContextError) Is(target error) bool {
+	return target == ErrInsufficientContext
+}
+
+// SetGroundingPolicy sets the checks Query and QueryStream apply before and
+// after generating an answer
+func (p *Pipeline) SetGroundingPolicy(policy GroundingPolicy) {
+	p.grounding = policy
+}
+
+// checkRetrieval applies the retrieval checks of the grounding policy,
+// returning an *InsufficientContextError if the results are too weak
+func (p *Pipeline) checkRetrieval(results []*vectorstore.SearchResult) error {
+	if len(results) == 0 {
+		return &InsufficientContextError{Reason: AbstainNoResults, Policy: p.grounding}
+	}
+
+	top, margin := scoreMargin(results)
+	insufficient := &InsufficientContextError{TopScore: top, Margin: margin, Policy: p.grounding}
+	switch {
+	case p.grounding.MinTopScore != 0 && top < p.grounding.MinTopScore:
+		insufficient.Reason = AbstainLowScore
+	case p.grounding.MinMargin > 0 && len(results) > 1 && margin < p.grounding.MinMargin:
+		insufficient.Reason = AbstainLowMargin
+	default:
+		return nil
+	}
+	return insufficient
+}
+
+// verifyAnswer applies the verifier of the grounding policy to an answer
+// generated from passages, the expanded results, returning an
+// *InsufficientContextError if it is not supported
+func (p *Pipeline) verifyAnswer(answer string, passages, results []*vectorstore.SearchResult) error {
+	if !p.grounding.Verify {
+		return nil
+	}
+	threshold := p.grounding.SupportThreshold
+	if threshold == 0 {
+		threshold = DefaultSupportThreshold
+	}
+	judgement, err := p.judgeFaithfulness(answer, passages, p.grounding.VerifyJudge, threshold)
+	if err != nil {
+		return fmt.Errorf("failed to verify answer: %w", err)
+	}
+	if len(judgement.Sentences) == 0 || judgement.Faithfulness >= p.grounding.MinSupported {
+		return nil
+	}
+
+	top, margin := scoreMargin(results)
+	return &InsufficientContextError{
+		Reason:    AbstainUnsupported,
+		TopScore:  top,
+		Margin:    margin,
+		Policy:    p.grounding,
+		Answer:    answer,
+		Judgement: judgement,
+	}
+}
+
+// scoreMargin returns the best score among results and its lead over the
+// mean score of the others, which is 0 for a single result
+func scoreMargin(results []*vectorstore.SearchResult) (top, margin float32) {
+	var sum float32
+	for i, result := range results {
+		sum += result.Score
+		if i == 0 || result.Score > top {
+			top = result.Score
+		}
+	}
+	if len(results) < 2 {
+		return top, 0
+	}
+	return top, top - (sum-top)/float32(len(results)-1)
+}
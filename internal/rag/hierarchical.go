@@ -0,0 +1,124 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+
+	"edgerag/internal/document"
+	"edgerag/internal/vectorstore"
+)
+
+// hierarchicalOversample is how many fine chunks are searched per coarse
+// chunk wanted, since several of the best fine matches often share a parent
+const hierarchicalOversample = 4
+
+// retrieve finds the chunks to answer from: the best matches, or with
+// hierarchical retrieval the coarse parents of the best fine matches.
+// Without hierarchical retrieval, fine chunks in the store are skipped, since
+// they repeat the text of their coarse chunks. The query text is used by
+// hybrid retrieval, and may be empty to search by embedding only.
+func (p *Pipeline) retrieve(query string, questionEmbedding []float32, topK int, threshold float32) ([]*vectorstore.SearchResult, error) {
+	if p.hierarchical {
+		return p.searchHierarchical(query, questionEmbedding, topK, threshold)
+	}
+
+	filter := p.filter
+	if _, ok := filter["chunk_level"]; !ok {
+		hasFine, err := p.hasFineChunks(questionEmbedding)
+		if err != nil {
+			return nil, err
+		}
+		if hasFine {
+			filter = vectorstore.Filter{"chunk_level": vectorstore.Not{Value: document.LevelFine}}
+			for key, value := range p.filter {
+				filter[key] = value
+			}
+		}
+	}
+	return p.searchWithFilter(query, questionEmbedding, topK, threshold, filter)
+}
+
+// searchWithFilter runs a filtered similarity search and, with hybrid
+// retrieval and a query text, a keyword search fused with it
+func (p *Pipeline) searchWithFilter(query string, questionEmbedding []float32, topK int, threshold float32, filter vectorstore.Filter) ([]*vectorstore.SearchResult, error) {
+	results, err := vectorstore.SearchWithFilter(p.vectorStore, questionEmbedding, topK, threshold, filter)
+	if err != nil || !p.hybrid || query == "" {
+		return results, err
+	}
+
+	lexical, err := vectorstore.LexicalSearch(p.vectorStore, query, questionEmbedding, topK, threshold, filter)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", err)
+	}
+	return fuseRankings([][]*vectorstore.SearchResult{results, lexical}, topK), nil
+}
+
+// hasFineChunks reports whether the store holds fine chunks of a
+// hierarchical index, looking once per pipeline
+func (p *Pipeline) hasFineChunks(questionEmbedding []float32) (bool, error) {
+	if p.fineChunks == nil {
+		filter := vectorstore.Filter{"chunk_level": document.LevelFine}
+		matches, err := vectorstore.SearchWithFilter(p.vectorStore, questionEmbedding, 1, float32(math.Inf(-1)), filter)
+		if err != nil {
+			return false, err
+		}
+		hasFine := len(matches) > 0
+		p.fineChunks = &hasFine
+	}
+	return *p.fineChunks, nil
+}
+
+// searchHierarchical searches only fine chunks and returns up to topK of
+// their coarse parents, in the order of each parent's best fine match and
+// with that match's score. Each parent records how many of the fine matches
+// fell within it as matched_chunks. Fine matches whose parent isn't in the
+// store are skipped.
+func (p *Pipeline) searchHierarchical(query string, questionEmbedding []float32, topK int, threshold float32) ([]*vectorstore.SearchResult, error) {
+	filter := vectorstore.Filter{"chunk_level": document.LevelFine}
+	for key, value := range p.filter {
+		filter[key] = value
+	}
+
+	matches, err := p.searchWithFilter(query, questionEmbedding, topK*hierarchicalOversample, threshold, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var parents []*vectorstore.SearchResult
+	byID := make(map[string]*vectorstore.SearchResult)
+	for _, match := range matches {
+		parentID, _ := match.Metadata["parent_chunk_id"].(string)
+		if parentID == "" {
+			continue
+		}
+		if parent, ok := byID[parentID]; ok {
+			parent.Metadata["matched_chunks"] = parent.Metadata["matched_chunks"].(int) + 1
+			continue
+		}
+		if len(parents) == topK {
+			continue
+		}
+
+		vector, err := p.vectorStore.Get(parentID)
+		if err != nil {
+			continue
+		}
+		metadata := make(map[string]interface{}, len(vector.Metadata)+1)
+		for k, v := range vector.Metadata {
+			metadata[k] = v
+		}
+		metadata["matched_chunks"] = 1
+
+		parent := &vectorstore.SearchResult{
+			Vector: vectorstore.Vector{
+				ID:       vector.ID,
+				Content:  vector.Content,
+				Metadata: metadata,
+			},
+			Score: match.Score,
+		}
+		parents = append(parents, parent)
+		byID[parentID] = parent
+	}
+	return parents, nil
+}
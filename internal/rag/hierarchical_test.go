@@ -0,0 +1,53 @@
+package rag
+
+import (
+	"reflect"
+	"testing"
+
+	"edgerag/internal/document"
+	"edgerag/internal/vectorstore"
+)
+
+func TestRetrieveHierarchicalIndex(t *testing.T) {
+	store := vectorstore.NewMemoryStore()
+	add := func(id string, embedding []float32, metadata map[string]interface{}) {
+		if err := store.Add(id, embedding, id, metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("coarse_0", []float32{1, 0.2}, map[string]interface{}{"chunk_level": document.LevelCoarse})
+	add("fine_0", []float32{1, 0}, map[string]interface{}{"chunk_level": document.LevelFine, "parent_chunk_id": "coarse_0"})
+	add("fine_1", []float32{1, 0.1}, map[string]interface{}{"chunk_level": document.LevelFine, "parent_chunk_id": "coarse_0"})
+	// A fine chunk whose coarse chunk failed to be stored
+	add("orphan", []float32{1, 0.05}, map[string]interface{}{"chunk_level": document.LevelFine, "parent_chunk_id": "missing"})
+	add("plain", []float32{1, 0.3}, nil)
+
+	ids := func(results []*vectorstore.SearchResult) []string {
+		var got []string
+		for _, result := range results {
+			got = append(got, result.ID)
+		}
+		return got
+	}
+
+	p := &Pipeline{vectorStore: store}
+	results, err := p.retrieve("", []float32{1, 0}, 10, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(results), []string{"coarse_0", "plain"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without hierarchical retrieval: got %v, want %v", got, want)
+	}
+
+	p = &Pipeline{vectorStore: store, hierarchical: true}
+	results, err = p.retrieve("", []float32{1, 0}, 10, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(results), []string{"coarse_0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with hierarchical retrieval: got %v, want %v", got, want)
+	}
+	if matched := results[0].Metadata["matched_chunks"]; matched != 2 {
+		t.Errorf("matched_chunks = %v, want 2", matched)
+	}
+}
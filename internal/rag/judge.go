@@ -0,0 +1,296 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"edgerag/internal/document"
+	"edgerag/internal/llm"
+	"edgerag/internal/vectorstore"
+)
+
+// JudgeMode selects how JudgeAnswer scores an answer
+type JudgeMode string
+
+const (
+	// JudgeLLM asks the LLM whether each answer sentence is supported by the
+	// context and how well the answer addresses the question, falling back
+	// to JudgeEmbedding for whatever its replies leave unscored
+	JudgeLLM JudgeMode = "llm"
+	// JudgeEmbedding compares embeddings: a sentence is supported when it is
+	// similar enough to one of the retrieved chunks, and relevance is the
+	// similarity of the answer to the question
+	JudgeEmbedding JudgeMode = "embedding"
+)
+
+// ParseJudgeMode converts a judge name to a JudgeMode
+func ParseJudgeMode(name string) (JudgeMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "llm":
+		return JudgeLLM, nil
+	case "embedding", "embeddings":
+		return JudgeEmbedding, nil
+	default:
+		return "", fmt.Errorf("unknown judge %q (expected llm or embedding)", name)
+	}
+}
+
+// DefaultSupportThreshold is the cosine similarity to a retrieved chunk above
+// which the embedding judge counts a sentence as supported
+const DefaultSupportThreshold = 0.5
+
+// minJudgedWords is the length below which a line of an answer, such as a
+// list heading, is not judged as a claim
+const minJudgedWords = 3
+
+// SentenceSupport is the verdict on one sentence of an answer
+type SentenceSupport struct {
+	Sentence  string `json:"sentence"`
+	Supported bool   `json:"supported"`
+	// Score is 1 or 0 for an LLM verdict, or the similarity to the closest
+	// chunk for an embedding verdict
+	Score float64 `json:"score"`
+	// Source is the 1-based rank of the closest chunk, for embedding verdicts
+	Source int `json:"source,omitempty"`
+	// Method is the judge that gave the verdict
+	Method JudgeMode `json:"method"`
+}
+
+// Judgement scores how well an answer is grounded in the retrieved chunks
+// and how well it addresses the question, both in [0, 1]
+type Judgement struct {
+	// Faithfulness is the fraction of answer sentences the context supports
+	Faithfulness float64           `json:"faithfulness"`
+	Relevance    float64           `json:"relevance"`
+	Sentences    []SentenceSupport `json:"sentences"`
+	// RelevanceMethod is the judge that scored relevance
+	RelevanceMethod JudgeMode `json:"relevance_method"`
+}
+
+// Unsupported returns the sentences the context does not support
+func (j *Judgement) Unsupported() []string {
+	var unsupported []string
+	for _, s := range j.Sentences {
+		if !s.Supported {
+			unsupported = append(unsupported, s.Sentence)
+		}
+	}
+	return unsupported
+}
+
+const faithfulnessPrompt = `You are checking whether statements are supported by a context. A statement is supported if the context states it or it follows directly from the context; general knowledge does not count.
+
+Context:
+%s
+
+Statements:
+%s
+
+For each statement, reply on its own line with its number and SUPPORTED or UNSUPPORTED, for example "1. SUPPORTED". Reply with nothing else.`
+
+const relevancePrompt = `Rate how well the answer addresses the question, whether or not it is correct, on a scale from 1 (does not address the question at all) to 5 (directly and completely answers it).
+
+Question: %s
+
+Answer: %s
+
+Reply with the number only.`
+
+// verdictLine matches a numbered verdict in the faithfulness judge's reply
+var verdictLine = regexp.MustCompile(`(?i)^\W*(\d+)\W+(unsupported|not supported|supported|yes|no)\b`)
+
+// ratingDigit matches the rating in the relevance judge's reply
+var ratingDigit = regexp.MustCompile(`[1-5]`)
+
+// SetJudge sets the LLM used by JudgeAnswer, when it should differ from the
+// one that generates answers
+func (p *Pipeline) SetJudge(judge *llm.OllamaClient) {
+	p.judge = judge
+}
+
+// JudgeAnswer scores an answer against the chunks retrieved for it: each of
+// its sentences is checked for support by the chunks, and the whole answer
+// for relevance to the question. supportThreshold applies to embedding
+// verdicts.
+func (p *Pipeline) JudgeAnswer(question, answer string, results []*vectorstore.SearchResult, mode JudgeMode, supportThreshold float64) (*Judgement, error) {
+	judgement, err := p.judgeFaithfulness(answer, results, mode, supportThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.judgeRelevance(question, answer, judgement, mode); err != nil {
+		return nil, err
+	}
+	return judgement, nil
+}
+
+// judgeLLM returns the LLM that judges answers
+func (p *Pipeline) judgeLLM() *llm.OllamaClient {
+	if p.judge != nil {
+		return p.judge
+	}
+	return p.llm
+}
+
+// judgeFaithfulness checks each sentence of an answer for support by the
+// chunks. Without any chunks nothing is supported.
+func (p *Pipeline) judgeFaithfulness(answer string, results []*vectorstore.SearchResult, mode JudgeMode, supportThreshold float64) (*Judgement, error) {
+	judgement := &Judgement{}
+	for _, sentence := range answerSentences(answer) {
+		judgement.Sentences = append(judgement.Sentences, SentenceSupport{Sentence: sentence, Method: mode})
+	}
+	if len(judgement.Sentences) == 0 {
+		return judgement, nil
+	}
+
+	judged := make([]bool, len(judgement.Sentences))
+	if len(results) > 0 {
+		if mode == JudgeLLM && p.judgeLLM() != nil {
+			if err := p.judgeSupport(judgement.Sentences, results, judged); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.judgeSupportByEmbedding(judgement.Sentences, results, judged, supportThreshold); err != nil {
+			return nil, err
+		}
+	}
+
+	supported := 0
+	for _, s := range judgement.Sentences {
+		if s.Supported {
+			supported++
+		}
+	}
+	judgement.Faithfulness = float64(supported) / float64(len(judgement.Sentences))
+	return judgement, nil
+}
+
+// judgeSupport asks the LLM for a verdict on every sentence in one prompt,
+// marking those it gives one for in judged
+func (p *Pipeline) judgeSupport(sentences []SentenceSupport, results []*vectorstore.SearchResult, judged []bool) error {
+	var statements strings.Builder
+	for i, s := range sentences {
+		fmt.Fprintf(&statements, "%d. %s\n", i+1, s.Sentence)
+	}
+	reply, err := p.judgeLLM().Generate(fmt.Sprintf(faithfulnessPrompt, formatContext(results), strings.TrimSpace(statements.String())))
+	if err != nil {
+		return fmt.Errorf("failed to judge answer faithfulness: %w", err)
+	}
+
+	for _, line := range strings.Split(reply, "\n") {
+		match := verdictLine.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(match[1])
+		if n < 1 || n > len(sentences) || judged[n-1] {
+			continue
+		}
+		verdict := strings.ToLower(match[2])
+		supported := verdict == "supported" || verdict == "yes"
+		sentences[n-1].Supported = supported
+		sentences[n-1].Score = 0
+		if supported {
+			sentences[n-1].Score = 1
+		}
+		sentences[n-1].Method = JudgeLLM
+		judged[n-1] = true
+	}
+	return nil
+}
+
+// judgeSupportByEmbedding gives the sentences not yet judged the verdict of
+// their similarity to the closest chunk. Sentences and chunks are embedded
+// in one batch.
+func (p *Pipeline) judgeSupportByEmbedding(sentences []SentenceSupport, results []*vectorstore.SearchResult, judged []bool, supportThreshold float64) error {
+	var pending []int
+	var texts []string
+	for i := range sentences {
+		if !judged[i] {
+			pending = append(pending, i)
+			texts = append(texts, sentences[i].Sentence)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	for _, result := range results {
+		texts = append(texts, result.Content)
+	}
+
+	embeddings, err := p.embedder.GetEmbeddings(texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed answer for judging: %w", err)
+	}
+	chunks := embeddings[len(pending):]
+	for j, i := range pending {
+		best, source := 0.0, 0
+		for rank, chunk := range chunks {
+			if score := float64(vectorstore.MetricCosine.Similarity(embeddings[j], chunk)); score > best {
+				best, source = score, rank+1
+			}
+		}
+		sentences[i] = SentenceSupport{
+			Sentence:  sentences[i].Sentence,
+			Supported: best >= supportThreshold,
+			Score:     clampUnit(best),
+			Source:    source,
+			Method:    JudgeEmbedding,
+		}
+	}
+	return nil
+}
+
+// judgeRelevance rates how well an answer addresses the question, by the
+// LLM's rating or, failing that, the similarity of their embeddings
+func (p *Pipeline) judgeRelevance(question, answer string, judgement *Judgement, mode JudgeMode) error {
+	if mode == JudgeLLM && p.judgeLLM() != nil {
+		rating, err := p.judgeLLM().Generate(fmt.Sprintf(relevancePrompt, question, answer))
+		if err != nil {
+			return fmt.Errorf("failed to judge answer relevance: %w", err)
+		}
+		if digit := ratingDigit.FindString(rating); digit != "" {
+			n, _ := strconv.Atoi(digit)
+			judgement.Relevance = float64(n-1) / 4
+			judgement.RelevanceMethod = JudgeLLM
+			return nil
+		}
+	}
+
+	embeddings, err := p.embedder.GetEmbeddings([]string{question, answer})
+	if err != nil {
+		return fmt.Errorf("failed to embed answer for judging: %w", err)
+	}
+	judgement.Relevance = clampUnit(float64(vectorstore.MetricCosine.Similarity(embeddings[0], embeddings[1])))
+	judgement.RelevanceMethod = JudgeEmbedding
+	return nil
+}
+
+// answerSentences splits an answer into the sentences that are judged as
+// claims. Lines are split separately so that list items stay apart, and
+// fragments too short to claim anything are dropped.
+func answerSentences(answer string) []string {
+	var sentences []string
+	for _, line := range strings.Split(answer, "\n") {
+		line = listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		line = strings.TrimLeft(line, "#> ")
+		for _, sentence := range document.SplitSentences(line) {
+			sentence = strings.TrimSpace(sentence)
+			if len(strings.Fields(sentence)) >= minJudgedWords {
+				sentences = append(sentences, sentence)
+			}
+		}
+	}
+	return sentences
+}
+
+func clampUnit(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	if x > 1 {
+		return 1
+	}
+	return x
+}
@@ -3,7 +3,9 @@ package rag
 import (
 	"fmt"
 	"strings"
+	"text/template"
 
+	"edgerag/internal/document"
 	"edgerag/internal/embedding"
 	"edgerag/internal/llm"
 	"edgerag/internal/vectorstore"
@@ -14,7 +16,21 @@ type Pipeline struct {
 	embedder     *embedding.Service
 	vectorStore  vectorstore.VectorStore
 	llm          *llm.OllamaClient
-	promptTemplate string
+	promptTemplate *template.Template
+	filter       vectorstore.Filter
+	expansion       ExpansionMode
+	expansionWindow int
+	expansionMaxChars int
+	hierarchical    bool
+	hybrid          bool
+	fineChunks      *bool // whether the store holds fine chunks, once known
+	transforms      QueryTransforms
+	traceFunc       func(stage, text string)
+	judge           *llm.OllamaClient
+	grounding       GroundingPolicy
+	history         []Turn
+	historyInPrompt bool
+	systemPrompt    string
 }
 
 // NewPipeline creates a new RAG pipeline
@@ -23,87 +39,139 @@ func NewPipeline(embedder *embedding.Service, vectorStore vectorstore.VectorStor
 		embedder:    embedder,
 		vectorStore: vectorStore,
 		llm:         llmClient,
-		promptTemplate: `You are a helpful assistant that answers questions based on the provided context. Use only the information given in the context to answer the question. If the context doesn't contain enough information to answer the question, say so.
+		promptTemplate: template.Must(ParsePromptTemplate("default", DefaultPromptTemplate)),
+	}
+}
 
-Context:
-{{.Context}}
+// SetFilter restricts retrieval to chunks whose metadata matches filter
+func (p *Pipeline) SetFilter(filter vectorstore.Filter) {
+	p.filter = filter
+}
 
-Question: {{.Question}}
+// SetContextExpansion widens retrieved chunks before they are passed to the
+// LLM: with ExpandNeighbors each match brings the window chunks either side
+// of it, and with ExpandParent as much of its enclosing section or document
+// around it as fits in maxChars (DefaultParentMaxChars if not positive)
+func (p *Pipeline) SetContextExpansion(mode ExpansionMode, window int, maxChars int) {
+	p.expansion = mode
+	p.expansionWindow = window
+	p.expansionMaxChars = maxChars
+}
 
-Answer:`,
-	}
+// SetHierarchical switches retrieval to a hierarchical index (built with
+// document.ChunkHierarchy): questions are matched against the fine chunks
+// and the LLM is given their coarse parents
+func (p *Pipeline) SetHierarchical(hierarchical bool) {
+	p.hierarchical = hierarchical
 }
 
-// SetPromptTemplate sets a custom prompt template
-func (p *Pipeline) SetPromptTemplate(template string) {
-	p.promptTemplate = template
+// SetHybrid adds keyword search to retrieval: the store's full-text index
+// is searched for the question as well, and the two rankings are fused.
+// The store must implement vectorstore.LexicalSearcher.
+func (p *Pipeline) SetHybrid(hybrid bool) {
+	p.hybrid = hybrid
 }
 
-// Query performs a RAG query: retrieve relevant documents and generate an answer
-func (p *Pipeline) Query(question string, topK int, threshold float32) (string, []*vectorstore.SearchResult, error) {
-	// Step 1: Generate embedding for the question
-	questionEmbedding, err := p.embedder.GetEmbedding(question)
+// Retrieve runs the retrieval half of a query, with any query
+// transformations, and returns the matched chunks without generating an
+// answer
+func (p *Pipeline) Retrieve(question string, topK int, threshold float32) ([]*vectorstore.SearchResult, error) {
+	// Step 1: Generate embeddings for the question
+	query, questionEmbeddings, err := p.embedQuestions(question)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to generate question embedding: %w", err)
+		return nil, err
 	}
 
 	// Step 2: Retrieve relevant documents
-	results, err := p.vectorStore.Search(questionEmbedding, topK, threshold)
+	results, err := p.search(query, questionEmbeddings, topK, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search vector store: %w", err)
+	}
+	return results, nil
+}
+
+// Query performs a RAG query: retrieve relevant documents and generate an
+// answer. When the grounding policy declines to answer, the error is an
+// *InsufficientContextError, matching ErrInsufficientContext, and the
+// retrieved results are still returned.
+func (p *Pipeline) Query(question string, topK int, threshold float32) (string, []*vectorstore.SearchResult, error) {
+	results, err := p.Retrieve(question, topK, threshold)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to search vector store: %w", err)
+		return "", nil, err
 	}
 
-	if len(results) == 0 {
-		return "I couldn't find any relevant information in the indexed documents to answer your question.", results, nil
+	if err := p.checkRetrieval(results); err != nil {
+		return "", results, err
 	}
 
 	// Step 3: Prepare context from retrieved documents
-	context := p.buildContext(results)
+	passages, err := p.expandResults(results)
+	if err != nil {
+		return "", results, fmt.Errorf("failed to expand context: %w", err)
+	}
 
 	// Step 4: Generate answer using LLM
-	prompt := p.buildPrompt(question, context)
-	answer, err := p.llm.Generate(prompt)
+	prompt, err := p.buildPrompt(question, passages)
+	if err != nil {
+		return "", results, err
+	}
+	answer, err := p.llm.Chat(p.chatMessages(prompt))
 	if err != nil {
 		return "", results, fmt.Errorf("failed to generate answer: %w", err)
 	}
+	answer = strings.TrimSpace(answer)
+
+	// Step 5: Check the answer against the context
+	if err := p.verifyAnswer(answer, passages, results); err != nil {
+		return "", results, err
+	}
 
-	return strings.TrimSpace(answer), results, nil
+	return answer, results, nil
 }
 
-// QueryStream performs a RAG query with streaming response
+// QueryStream performs a RAG query with streaming response. The grounding
+// policy applies as for Query, except that an answer the verifier rejects
+// has already been streamed by the time the error is returned.
 func (p *Pipeline) QueryStream(question string, topK int, threshold float32, callback func(string)) ([]*vectorstore.SearchResult, error) {
-	// Step 1: Generate embedding for the question
-	questionEmbedding, err := p.embedder.GetEmbedding(question)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate question embedding: %w", err)
-	}
-
-	// Step 2: Retrieve relevant documents
-	results, err := p.vectorStore.Search(questionEmbedding, topK, threshold)
+	results, err := p.Retrieve(question, topK, threshold)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search vector store: %w", err)
+		return nil, err
 	}
 
-	if len(results) == 0 {
-		callback("I couldn't find any relevant information in the indexed documents to answer your question.")
-		return results, nil
+	if err := p.checkRetrieval(results); err != nil {
+		return results, err
 	}
 
 	// Step 3: Prepare context from retrieved documents
-	context := p.buildContext(results)
+	passages, err := p.expandResults(results)
+	if err != nil {
+		return results, fmt.Errorf("failed to expand context: %w", err)
+	}
 
 	// Step 4: Generate answer using LLM with streaming
-	prompt := p.buildPrompt(question, context)
-	err = p.llm.GenerateStream(prompt, callback)
+	prompt, err := p.buildPrompt(question, passages)
+	if err != nil {
+		return results, err
+	}
+	var answer strings.Builder
+	err = p.llm.ChatStream(p.chatMessages(prompt), func(token string) {
+		answer.WriteString(token)
+		callback(token)
+	})
 	if err != nil {
 		return results, fmt.Errorf("failed to generate streaming answer: %w", err)
 	}
 
+	// Step 5: Check the answer against the context
+	if err := p.verifyAnswer(strings.TrimSpace(answer.String()), passages, results); err != nil {
+		return results, err
+	}
+
 	return results, nil
 }
 
-// buildContext creates a context string from search results
-func (p *Pipeline) buildContext(results []*vectorstore.SearchResult) string {
+// formatContext creates a context string from search results
+func formatContext(results []*vectorstore.SearchResult) string {
 	var contextParts []string
 
 	for i, result := range results {
@@ -112,6 +180,9 @@ func (p *Pipeline) buildContext(results []*vectorstore.SearchResult) string {
 		
 		// Add file information if available
 		if fileName, ok := result.Metadata["file"].(string); ok {
+			if page := document.PageLabel(result.Metadata); page != "" {
+				fileName = fmt.Sprintf("%s (%s)", fileName, page)
+			}
 			contextPart = fmt.Sprintf("Document %d from %s (Similarity: %.3f):\n%s",
 				i+1, fileName, result.Score, result.Content)
 		}
@@ -122,14 +193,6 @@ func (p *Pipeline) buildContext(results []*vectorstore.SearchResult) string {
 	return strings.Join(contextParts, "\n\n---\n\n")
 }
 
-// buildPrompt creates the final prompt for the LLM
-func (p *Pipeline) buildPrompt(question, context string) string {
-	prompt := p.promptTemplate
-	prompt = strings.ReplaceAll(prompt, "{{.Context}}", context)
-	prompt = strings.ReplaceAll(prompt, "{{.Question}}", question)
-	return prompt
-}
-
 // GetStats returns statistics about the RAG pipeline
 func (p *Pipeline) GetStats() map[string]interface{} {
 	stats := map[string]interface{}{
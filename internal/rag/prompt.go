@@ -0,0 +1,288 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"edgerag/internal/document"
+	"edgerag/internal/llm"
+	"edgerag/internal/vectorstore"
+)
+
+// DefaultPromptTemplate is the prompt used unless another is set
+const DefaultPromptTemplate = `You are a helpful assistant that answers questions based on the provided context. Use only the information given in the context to answer the question. If the context doesn't contain enough information to answer the question, say so.
+
+Context:
+{{.Context}}
+
+Question: {{.Question}}
+
+Answer:`
+
+// PromptData is the data model prompt templates are executed with:
+//
+//	{{.Question}}      the user's question
+//	{{.Context}}       the retrieved passages, numbered and separated as in
+//	                   the default prompt
+//	{{.Results}}       the retrieved passages, to range over, each with
+//	                   .Index (from 1), .ID, .Content, .Score, .File,
+//	                   .Location (e.g. "page 4") and .Metadata
+//	{{.History}}       earlier turns of the conversation, each with
+//	                   .Question and .Answer; empty for single questions.
+//	                   Templates using it replace the turns otherwise sent
+//	                   as earlier chat messages.
+//	{{.Date}}          today's date, as YYYY-MM-DD
+//
+// Besides the text/template builtins, templates can use the functions
+// meta (a metadata value by key, or "" if absent: {{meta .Metadata "heading"}}),
+// join, lower, upper, trim and truncate ({{truncate .Content 200}}).
+type PromptData struct {
+	Question string
+	Context  string
+	Results  []PromptResult
+	History  []Turn
+	Date     string
+}
+
+// PromptResult is one retrieved passage as seen by a prompt template
+type PromptResult struct {
+	Index    int
+	ID       string
+	Content  string
+	Score    float32
+	File     string
+	Location string
+	Metadata map[string]interface{}
+}
+
+// Turn is an earlier question and answer of a conversation
+type Turn struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// promptFuncs are the functions available to prompt templates
+var promptFuncs = template.FuncMap{
+	"meta": func(metadata map[string]interface{}, key string) string {
+		switch value := metadata[key].(type) {
+		case nil:
+			return ""
+		case []string:
+			return strings.Join(value, " > ")
+		case []interface{}:
+			parts := make([]string, len(value))
+			for i, v := range value {
+				parts[i] = fmt.Sprint(v)
+			}
+			return strings.Join(parts, " > ")
+		default:
+			return fmt.Sprint(value)
+		}
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"truncate": func(s string, n int) string {
+		runes := []rune(s)
+		if n < 0 || len(runes) <= n {
+			return s
+		}
+		return string(runes[:n]) + "..."
+	},
+}
+
+// samplePromptData exercises every field of the data model when a template
+// is validated
+var samplePromptData = PromptData{
+	Question: "<<sample question>>",
+	Results: []PromptResult{{
+		Index:    1,
+		ID:       "sample_chunk_0",
+		Content:  "<<sample passage>>",
+		Score:    0.5,
+		File:     "docs/sample.md",
+		Location: "page 1",
+		Metadata: map[string]interface{}{"file": "docs/sample.md", "heading_path": []string{"Guide", "Sample"}},
+	}},
+	History: []Turn{{Question: "<<earlier question>>", Answer: "<<earlier answer>>"}},
+	Date:    "2006-01-02",
+}
+
+// ParsePromptTemplate parses a prompt template and checks it against the
+// data model: it must execute on sample data, and refer to both the
+// question and the retrieved passages, through .Context or .Results.
+func ParsePromptTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	data := samplePromptData
+	data.Context = formatContext(sampleResults())
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	fields := templateFields(tmpl)
+	if !fields["Question"] {
+		return nil, fmt.Errorf("invalid prompt template %s: it never includes {{.Question}}", name)
+	}
+	if !fields["Context"] && !fields["Results"] {
+		return nil, fmt.Errorf("invalid prompt template %s: it never includes the retrieved passages ({{.Context}} or {{.Results}})", name)
+	}
+	return tmpl, nil
+}
+
+// templateFields returns the names of the PromptData fields a template and
+// the templates it defines refer to
+func templateFields(tmpl *template.Template) map[string]bool {
+	fields := make(map[string]bool)
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			collectFields(t.Tree.Root, fields)
+		}
+	}
+	return fields
+}
+
+// collectFields records the names of the fields a template node refers to,
+// such as Question for {{.Question}} or {{$.Question}}
+func collectFields(node parse.Node, fields map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, fields)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, fields)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFields(cmd, fields)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, fields)
+		}
+	case *parse.FieldNode:
+		fields[n.Ident[0]] = true
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 {
+			fields[n.Ident[1]] = true
+		}
+	case *parse.ChainNode:
+		collectFields(n.Node, fields)
+		if len(n.Field) > 0 {
+			fields[n.Field[0]] = true
+		}
+	case *parse.IfNode:
+		collectFields(&n.BranchNode, fields)
+	case *parse.RangeNode:
+		collectFields(&n.BranchNode, fields)
+	case *parse.WithNode:
+		collectFields(&n.BranchNode, fields)
+	case *parse.BranchNode:
+		collectFields(n.Pipe, fields)
+		collectFields(n.List, fields)
+		collectFields(n.ElseList, fields)
+	case *parse.TemplateNode:
+		collectFields(n.Pipe, fields)
+	}
+}
+
+// sampleResults returns the sample passages as search results
+func sampleResults() []*vectorstore.SearchResult {
+	results := make([]*vectorstore.SearchResult, len(samplePromptData.Results))
+	for i, r := range samplePromptData.Results {
+		results[i] = &vectorstore.SearchResult{
+			Vector: vectorstore.Vector{ID: r.ID, Content: r.Content, Metadata: r.Metadata},
+			Score:  r.Score,
+		}
+	}
+	return results
+}
+
+// SetPromptTemplate sets a custom prompt template, in text/template syntax
+// over PromptData. The template is validated as by ParsePromptTemplate.
+func (p *Pipeline) SetPromptTemplate(text string) error {
+	tmpl, err := ParsePromptTemplate("prompt", text)
+	if err != nil {
+		return err
+	}
+	p.promptTemplate = tmpl
+	p.historyInPrompt = templateFields(tmpl)["History"]
+	return nil
+}
+
+// SetSystemPrompt sets the system message sent ahead of the prompt, to
+// instruct the LLM separately from the question and its context. It is
+// empty by default, leaving the model's own system prompt in place.
+func (p *Pipeline) SetSystemPrompt(text string) {
+	p.systemPrompt = text
+}
+
+// SetHistory sets the earlier turns of a conversation. They are sent to the
+// LLM as earlier chat messages, unless the prompt template includes them
+// through {{.History}}.
+func (p *Pipeline) SetHistory(history []Turn) {
+	p.history = history
+}
+
+// buildPrompt creates the final prompt for the LLM from the passages
+// retrieved for the question
+func (p *Pipeline) buildPrompt(question string, passages []*vectorstore.SearchResult) (string, error) {
+	data := PromptData{
+		Question: question,
+		Context:  formatContext(passages),
+		Results:  make([]PromptResult, len(passages)),
+		History:  p.history,
+		Date:     time.Now().Format("2006-01-02"),
+	}
+	for i, passage := range passages {
+		file, _ := passage.Metadata["file"].(string)
+		data.Results[i] = PromptResult{
+			Index:    i + 1,
+			ID:       passage.ID,
+			Content:  passage.Content,
+			Score:    passage.Score,
+			File:     file,
+			Location: document.PageLabel(passage.Metadata),
+			Metadata: passage.Metadata,
+		}
+	}
+
+	var prompt strings.Builder
+	if err := p.promptTemplate.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("failed to build prompt: %w", err)
+	}
+	return prompt.String(), nil
+}
+
+// chatMessages returns the chat messages that ask the LLM for the answer to
+// a prompt: the system prompt, the earlier turns of the conversation as
+// alternating user and assistant messages, and the prompt
+func (p *Pipeline) chatMessages(prompt string) []llm.Message {
+	var messages []llm.Message
+	if p.systemPrompt != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: p.systemPrompt})
+	}
+	if !p.historyInPrompt {
+		for _, turn := range p.history {
+			messages = append(messages,
+				llm.Message{Role: llm.RoleUser, Content: turn.Question},
+				llm.Message{Role: llm.RoleAssistant, Content: turn.Answer},
+			)
+		}
+	}
+	return append(messages, llm.Message{Role: llm.RoleUser, Content: prompt})
+}
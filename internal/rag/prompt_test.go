@@ -0,0 +1,38 @@
+package rag
+
+import (
+	"reflect"
+	"testing"
+
+	"edgerag/internal/llm"
+)
+
+func TestChatMessages(t *testing.T) {
+	history := []Turn{{Question: "q1", Answer: "a1"}, {Question: "q2", Answer: "a2"}}
+
+	p := &Pipeline{systemPrompt: "be brief"}
+	p.SetHistory(history)
+	want := []llm.Message{
+		{Role: llm.RoleSystem, Content: "be brief"},
+		{Role: llm.RoleUser, Content: "q1"},
+		{Role: llm.RoleAssistant, Content: "a1"},
+		{Role: llm.RoleUser, Content: "q2"},
+		{Role: llm.RoleAssistant, Content: "a2"},
+		{Role: llm.RoleUser, Content: "prompt"},
+	}
+	if got := p.chatMessages("prompt"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A template including the history replaces the chat turns
+	if err := p.SetPromptTemplate("{{range .History}}{{.Question}}{{end}} {{.Context}} {{.Question}}"); err != nil {
+		t.Fatal(err)
+	}
+	want = []llm.Message{
+		{Role: llm.RoleSystem, Content: "be brief"},
+		{Role: llm.RoleUser, Content: "prompt"},
+	}
+	if got := p.chatMessages("prompt"); !reflect.DeepEqual(got, want) {
+		t.Errorf("with {{.History}} template: got %v, want %v", got, want)
+	}
+}
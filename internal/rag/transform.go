@@ -0,0 +1,264 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// QueryTransforms selects the stages that turn a question into the text that
+// is embedded for retrieval. Short questions embed poorly against long
+// chunks; each stage asks the LLM to bridge the gap. The question itself is
+// still what the LLM answers.
+type QueryTransforms struct {
+	// Rewrite asks the LLM to restate the question as a self-contained,
+	// specific search query
+	Rewrite bool
+	// Keywords asks the LLM for related keywords and synonyms and appends
+	// them to the query
+	Keywords bool
+	// HyDE asks the LLM to draft a hypothetical passage answering the
+	// query, and searches with the average of its embedding and the
+	// query's, so that the query is compared with chunks as a passage
+	// like them would be
+	HyDE bool
+	// Paraphrases asks the LLM for this many rewordings of the question.
+	// Each is searched for separately and the rankings are fused, so that
+	// chunks phrased differently from the question are found too.
+	Paraphrases int
+}
+
+// maxQueryKeywords bounds how many expansion keywords are added to a query
+const maxQueryKeywords = 10
+
+// listMarker matches a bullet or number starting an item of an LLM's list
+var listMarker = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s*`)
+
+const rewritePrompt = `Rewrite the following question as a single, self-contained search query for finding relevant passages in technical documentation. Spell out abbreviations and vague references, keep all specific names and terms, and do not answer the question.
+
+Question: %s
+
+Reply with the search query only.`
+
+const keywordsPrompt = `List up to %d keywords, synonyms and closely related technical terms that passages answering the following search query are likely to contain. Do not repeat words already in the query.
+
+Query: %s
+
+Reply with a comma-separated list only.`
+
+const hydePrompt = `Write a short passage, as it might appear in technical documentation, that answers the following question. If you are unsure of the facts, write a plausible passage anyway; it is only used to search for the real documentation.
+
+Question: %s
+
+Passage:`
+
+const paraphrasePrompt = `Write %d different rephrasings of the following question. Vary the wording and use synonyms and related terms, but keep the meaning and all specific names.
+
+Question: %s
+
+Reply with one rephrasing per line and nothing else.`
+
+// SetQueryTransforms enables query transformation stages before retrieval
+func (p *Pipeline) SetQueryTransforms(transforms QueryTransforms) {
+	p.transforms = transforms
+}
+
+// SetTraceFunc registers a function called with each intermediate query the
+// pipeline derives from a question, such as "rewrite" or "hyde", for
+// verbose output
+func (p *Pipeline) SetTraceFunc(trace func(stage, text string)) {
+	p.traceFunc = trace
+}
+
+func (p *Pipeline) trace(stage, text string) {
+	if p.traceFunc != nil {
+		p.traceFunc(stage, text)
+	}
+}
+
+// embedQuestions returns the question after the enabled query
+// transformations and the embeddings retrieval searches with: that of the
+// transformed question, followed by those of its paraphrases. All are
+// embedded in one batch.
+func (p *Pipeline) embedQuestions(question string) (string, [][]float32, error) {
+	query, err := p.transformQuery(question)
+	if err != nil {
+		return "", nil, err
+	}
+	texts := []string{query}
+
+	if p.transforms.HyDE {
+		passage, err := p.llm.Generate(fmt.Sprintf(hydePrompt, query))
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to generate hypothetical document: %w", err)
+		}
+		passage = strings.TrimSpace(passage)
+		p.trace("hyde", passage)
+		if passage == "" {
+			passage = query
+		}
+		texts = append(texts, passage)
+	}
+
+	if p.transforms.Paraphrases > 0 {
+		paraphrases, err := p.paraphrase(question, p.transforms.Paraphrases)
+		if err != nil {
+			return "", nil, err
+		}
+		texts = append(texts, paraphrases...)
+	}
+
+	if len(texts) == 1 {
+		embedding, err := p.embedder.GetEmbedding(query)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to generate question embedding: %w", err)
+		}
+		return query, [][]float32{embedding}, nil
+	}
+
+	embeddings, err := p.embedder.GetEmbeddings(texts)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate question embeddings: %w", err)
+	}
+	if p.transforms.HyDE {
+		// The query and its hypothetical passage search as one
+		embeddings = append([][]float32{averageEmbeddings(embeddings[:2])}, embeddings[2:]...)
+	}
+	return query, embeddings, nil
+}
+
+// paraphrase asks the LLM for up to n rewordings of the question, skipping
+// any that repeat it
+func (p *Pipeline) paraphrase(question string, n int) ([]string, error) {
+	reply, err := p.llm.Generate(fmt.Sprintf(paraphrasePrompt, n, question))
+	if err != nil {
+		return nil, fmt.Errorf("failed to paraphrase question: %w", err)
+	}
+
+	seen := map[string]bool{strings.ToLower(question): true}
+	var paraphrases []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.Trim(listMarker.ReplaceAllString(strings.TrimSpace(line), ""), "\"'`")
+		if line == "" || seen[strings.ToLower(line)] {
+			continue
+		}
+		seen[strings.ToLower(line)] = true
+		paraphrases = append(paraphrases, line)
+		p.trace("paraphrase", line)
+		if len(paraphrases) == n {
+			break
+		}
+	}
+	return paraphrases, nil
+}
+
+// transformQuery applies the rewrite and keyword stages to a question
+func (p *Pipeline) transformQuery(question string) (string, error) {
+	query := question
+
+	if p.transforms.Rewrite {
+		rewritten, err := p.llm.Generate(fmt.Sprintf(rewritePrompt, question))
+		if err != nil {
+			return "", fmt.Errorf("failed to rewrite query: %w", err)
+		}
+		if rewritten = firstLine(rewritten); rewritten != "" {
+			query = rewritten
+		}
+		p.trace("rewrite", query)
+	}
+
+	if p.transforms.Keywords {
+		reply, err := p.llm.Generate(fmt.Sprintf(keywordsPrompt, maxQueryKeywords, query))
+		if err != nil {
+			return "", fmt.Errorf("failed to expand query keywords: %w", err)
+		}
+		if keywords := parseKeywords(reply, query); len(keywords) > 0 {
+			query += " " + strings.Join(keywords, " ")
+		}
+		p.trace("keywords", query)
+	}
+
+	return query, nil
+}
+
+// firstLine returns the first non-empty line of an LLM reply, without any
+// label or quotes around it
+func firstLine(reply string) string {
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if label, rest, ok := strings.Cut(line, ":"); ok && strings.Contains(strings.ToLower(label), "query") {
+			line = strings.TrimSpace(rest)
+		}
+		return strings.Trim(line, "\"'`")
+	}
+	return ""
+}
+
+// parseKeywords splits a comma- or line-separated keyword list, dropping
+// list markers, duplicates and words already in the query
+func parseKeywords(reply, query string) []string {
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		seen[strings.Trim(word, ".,;:!?\"'()")] = true
+	}
+
+	var keywords []string
+	for _, item := range strings.FieldsFunc(reply, func(r rune) bool { return r == ',' || r == '\n' || r == ';' }) {
+		item = listMarker.ReplaceAllString(strings.TrimSpace(item), "")
+		item = strings.Trim(item, "\"'`.")
+		key := strings.ToLower(item)
+		if item == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keywords = append(keywords, item)
+		if len(keywords) == maxQueryKeywords {
+			break
+		}
+	}
+	return keywords
+}
+
+// averageEmbeddings returns the mean direction of embeddings, each weighted
+// equally whatever its norm, scaled to their average norm so that scores
+// under the dot and euclidean metrics stay comparable
+func averageEmbeddings(embeddings [][]float32) []float32 {
+	sum := make([]float64, len(embeddings[0]))
+	var totalNorm float64
+	for _, embedding := range embeddings {
+		norm := vectorNorm(embedding)
+		if norm == 0 {
+			continue
+		}
+		totalNorm += norm
+		for i, x := range embedding {
+			sum[i] += float64(x) / norm
+		}
+	}
+
+	mean := make([]float32, len(sum))
+	sumNorm := 0.0
+	for _, x := range sum {
+		sumNorm += x * x
+	}
+	if sumNorm == 0 {
+		return mean
+	}
+	scale := totalNorm / float64(len(embeddings)) / math.Sqrt(sumNorm)
+	for i, x := range sum {
+		mean[i] = float32(x * scale)
+	}
+	return mean
+}
+
+func vectorNorm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
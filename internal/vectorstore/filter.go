@@ -0,0 +1,118 @@
+package vectorstore
+
+import "fmt"
+
+// Filter restricts a search to vectors whose metadata matches every key/value
+// pair. Values are compared by their printed form so numbers loaded from JSON
+// match integers, and a list-valued metadata field matches if any element does.
+// A Not value inverts its condition.
+type Filter map[string]interface{}
+
+// Not is a filter value matching vectors whose metadata lacks the key or
+// doesn't match Value
+type Not struct {
+	Value interface{}
+}
+
+// FilteredSearcher is implemented by stores that can restrict a similarity
+// search by metadata before ranking
+type FilteredSearcher interface {
+	SearchFiltered(queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error)
+}
+
+// Matches reports whether metadata satisfies every condition in the filter
+func (f Filter) Matches(metadata map[string]interface{}) bool {
+	for key, want := range f {
+		got, ok := metadata[key]
+		if not, isNot := want.(Not); isNot {
+			if ok && valueMatches(got, not.Value) {
+				return false
+			}
+			continue
+		}
+		if !ok || !valueMatches(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// valueMatches compares a metadata value against a filter value
+func valueMatches(got, want interface{}) bool {
+	wantText := fmt.Sprint(want)
+	switch values := got.(type) {
+	case []interface{}:
+		for _, v := range values {
+			if fmt.Sprint(v) == wantText {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, v := range values {
+			if v == wantText {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprint(got) == wantText
+	}
+}
+
+// SearchWithFilter runs a filtered search, using the store's own filtering
+// when it implements FilteredSearcher and otherwise ranking every vector and
+// discarding those that don't match
+func SearchWithFilter(store VectorStore, queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error) {
+	if len(filter) == 0 {
+		return store.Search(queryEmbedding, topK, threshold)
+	}
+	if filtered, ok := store.(FilteredSearcher); ok {
+		return filtered.SearchFiltered(queryEmbedding, topK, threshold, filter)
+	}
+
+	candidates, err := store.Search(queryEmbedding, store.Count(), threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*SearchResult, 0, topK)
+	for _, candidate := range candidates {
+		if !filter.Matches(candidate.Metadata) {
+			continue
+		}
+		results = append(results, candidate)
+		if len(results) == topK {
+			break
+		}
+	}
+	return results, nil
+}
+
+// MetadataFinder is implemented by stores that can look vectors up by
+// metadata without ranking them against a query
+type MetadataFinder interface {
+	FindByMetadata(filter Filter) ([]*Vector, error)
+}
+
+// FindByMetadata returns every vector whose metadata matches filter, in no
+// particular order, using the store's own lookup when it implements
+// MetadataFinder and otherwise reading each vector in turn
+func FindByMetadata(store VectorStore, filter Filter) ([]*Vector, error) {
+	if finder, ok := store.(MetadataFinder); ok {
+		return finder.FindByMetadata(filter)
+	}
+
+	var vectors []*Vector
+	for _, id := range store.List() {
+		vector, err := store.Get(id)
+		if err != nil {
+			// Deleted since it was listed
+			continue
+		}
+		if filter.Matches(vector.Metadata) {
+			vectors = append(vectors, vector)
+		}
+	}
+	return vectors, nil
+}
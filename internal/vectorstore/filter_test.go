@@ -0,0 +1,53 @@
+package vectorstore
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSearchWithNotFilter(t *testing.T) {
+	for _, backend := range []Backend{BackendJSON, BackendSQLite} {
+		t.Run(string(backend), func(t *testing.T) {
+			store, err := Open(backend, t.TempDir(), Options{Lock: LockExclusive})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			vectors := map[string]map[string]interface{}{
+				"plain":  {"file": "a.md"},
+				"coarse": {"file": "b.md", "chunk_level": "coarse"},
+				"fine":   {"file": "b.md", "chunk_level": "fine"},
+			}
+			for id, metadata := range vectors {
+				if err := store.Add(id, []float32{1, 0}, id, metadata); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tests := []struct {
+				filter Filter
+				want   []string
+			}{
+				{Filter{"chunk_level": Not{Value: "fine"}}, []string{"coarse", "plain"}},
+				{Filter{"file": Not{Value: "a.md"}}, []string{"coarse", "fine"}},
+				{Filter{"file": "b.md", "chunk_level": Not{Value: "coarse"}}, []string{"fine"}},
+			}
+			for _, tt := range tests {
+				results, err := SearchWithFilter(store, []float32{1, 0}, 10, -1, tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, result := range results {
+					got = append(got, result.ID)
+				}
+				sort.Strings(got)
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("filter %v: got %v, want %v", tt.filter, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package vectorstore
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultRerankFactor is how many ADC candidates per requested result are
+// rescored against the full-precision embeddings
+const defaultRerankFactor = 4
+
+// IndexedStore answers searches through an IVF-PQ index while delegating
+// storage to an underlying VectorStore. Writes keep the index in sync.
+type IndexedStore struct {
+	VectorStore
+	index        *IVFPQIndex
+	metric       Metric
+	nprobe       int
+	rerankFactor int
+}
+
+// NewIndexedStore wraps a store with an IVF-PQ index probing nprobe lists per
+// query. The index shortlists by angular similarity; the shortlist is rescored
+// with the wrapped store's metric.
+func NewIndexedStore(store VectorStore, index *IVFPQIndex, nprobe int) *IndexedStore {
+	metric := MetricCosine
+	if withMetric, ok := store.(interface{ Metric() Metric }); ok {
+		metric = withMetric.Metric()
+	}
+
+	return &IndexedStore{
+		VectorStore:  store,
+		index:        index,
+		metric:       metric,
+		nprobe:       nprobe,
+		rerankFactor: defaultRerankFactor,
+	}
+}
+
+// SetNProbe changes how many inverted lists are scanned per query
+func (s *IndexedStore) SetNProbe(nprobe int) {
+	s.nprobe = nprobe
+}
+
+// SetRerankFactor changes how many candidates per result are rescored exactly.
+// A factor of 0 returns the raw ADC estimates, which approximate cosine
+// similarity regardless of the store's metric.
+func (s *IndexedStore) SetRerankFactor(factor int) {
+	s.rerankFactor = factor
+}
+
+// Index returns the underlying IVF-PQ index
+func (s *IndexedStore) Index() *IVFPQIndex {
+	return s.index
+}
+
+// Add stores a vector and encodes it into the index
+func (s *IndexedStore) Add(id string, embedding []float32, content string, metadata map[string]interface{}) error {
+	if err := s.VectorStore.Add(id, embedding, content, metadata); err != nil {
+		return err
+	}
+	return s.index.Add(id, embedding)
+}
+
+// Delete removes a vector from the store and the index
+func (s *IndexedStore) Delete(id string) error {
+	if err := s.VectorStore.Delete(id); err != nil {
+		return err
+	}
+	s.index.Remove(id)
+	return nil
+}
+
+// Clear removes all vectors from the store and the index
+func (s *IndexedStore) Clear() {
+	s.VectorStore.Clear()
+	s.index.Reset()
+}
+
+// Search finds approximate nearest neighbours through the index, rescoring the
+// shortlist exactly with the store's metric when rerank is enabled
+func (s *IndexedStore) Search(queryEmbedding []float32, topK int, threshold float32) ([]*SearchResult, error) {
+	shortlist := topK
+	if s.rerankFactor > 0 {
+		shortlist = topK * s.rerankFactor
+	}
+
+	candidates, err := s.index.Search(queryEmbedding, shortlist, s.nprobe)
+	if err != nil {
+		return nil, fmt.Errorf("index search failed: %w", err)
+	}
+
+	results := make([]*SearchResult, 0, len(candidates))
+	for _, candidate := range candidates {
+		vector, err := s.VectorStore.Get(candidate.ID)
+		if err != nil {
+			// The index can briefly lag the store; skip vanished entries
+			continue
+		}
+
+		score := candidate.Score
+		if s.rerankFactor > 0 {
+			score = s.metric.Similarity(queryEmbedding, vector.Embedding)
+		}
+		if score < threshold {
+			continue
+		}
+
+		results = append(results, &SearchResult{
+			Vector: *vector,
+			Score:  score,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+
+	return results, nil
+}
+
+// SearchFiltered bypasses the index and runs an exact filtered search on the
+// underlying store, since filters usually narrow the candidates far enough
+// that a scan is cheap and the index shortlist could miss matching vectors
+func (s *IndexedStore) SearchFiltered(queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error) {
+	if len(filter) == 0 {
+		return s.Search(queryEmbedding, topK, threshold)
+	}
+	return SearchWithFilter(s.VectorStore, queryEmbedding, topK, threshold, filter)
+}
+
+// LexicalSearch runs a keyword search on the underlying store
+func (s *IndexedStore) LexicalSearch(query string, queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error) {
+	return LexicalSearch(s.VectorStore, query, queryEmbedding, topK, threshold, filter)
+}
+
+// FindByMetadata looks vectors up in the underlying store
+func (s *IndexedStore) FindByMetadata(filter Filter) ([]*Vector, error) {
+	return FindByMetadata(s.VectorStore, filter)
+}
+
+// GetStats returns statistics about the store and its index
+func (s *IndexedStore) GetStats() map[string]interface{} {
+	stats := s.VectorStore.GetStats()
+	indexStats := s.index.GetStats()
+	indexStats["nprobe"] = s.nprobe
+	stats["index"] = indexStats
+	return stats
+}
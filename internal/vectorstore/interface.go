@@ -1,5 +1,7 @@
 package vectorstore
 
+import "errors"
+
 // VectorStore defines the interface for vector storage and retrieval
 type VectorStore interface {
 	// Add stores a vector in the store
@@ -25,4 +27,30 @@ type VectorStore interface {
 	
 	// GetStats returns statistics about the vector store
 	GetStats() map[string]interface{}
-} 
\ No newline at end of file
+	
+	// Close releases any resources held by the store, such as file locks
+	Close() error
+} 
+
+// LexicalSearcher is implemented by stores with a full-text index
+type LexicalSearcher interface {
+	// LexicalSearch ranks vectors matching filter by keyword relevance of
+	// their content to the query text, and returns up to topK of them in
+	// that order, each scored by its similarity to queryEmbedding as Search
+	// would score it. Vectors scoring below threshold are left out.
+	LexicalSearch(query string, queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error)
+}
+
+// ErrNoLexicalIndex is returned by LexicalSearch for stores without a
+// full-text index
+var ErrNoLexicalIndex = errors.New("vector store has no full-text index")
+
+// LexicalSearch runs a keyword search on stores implementing
+// LexicalSearcher, and returns ErrNoLexicalIndex for others
+func LexicalSearch(store VectorStore, query string, queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error) {
+	searcher, ok := store.(LexicalSearcher)
+	if !ok {
+		return nil, ErrNoLexicalIndex
+	}
+	return searcher.LexicalSearch(query, queryEmbedding, topK, threshold, filter)
+}
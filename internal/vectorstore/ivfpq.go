@@ -0,0 +1,407 @@
+package vectorstore
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+)
+
+// IVFPQIndexFile is the name of the IVF-PQ index file inside a data directory
+const IVFPQIndexFile = "ivfpq.index"
+
+// ivfpqFormatVersion is bumped whenever the on-disk layout changes
+const ivfpqFormatVersion = 1
+
+// IVFPQConfig controls how an IVF-PQ index is trained
+type IVFPQConfig struct {
+	NList      int   // Number of coarse clusters (inverted lists)
+	M          int   // Number of sub-quantizers; must divide the embedding dimension
+	NBits      int   // Bits per sub-quantizer code (1-8)
+	Iterations int   // Maximum k-means iterations
+	Seed       int64 // Random seed for reproducible training
+}
+
+// DefaultIVFPQConfig returns sensible defaults for sentence-transformer embeddings
+func DefaultIVFPQConfig() IVFPQConfig {
+	return IVFPQConfig{
+		NList:      256,
+		M:          16,
+		NBits:      8,
+		Iterations: 20,
+		Seed:       42,
+	}
+}
+
+// IVFPQIndex is an approximate nearest-neighbour index. A k-means coarse
+// quantizer partitions vectors into inverted lists, and each vector's residual
+// from its list centroid is stored as M one-byte product-quantizer codes.
+// Vectors are normalized before encoding, so scores approximate cosine similarity.
+type IVFPQIndex struct {
+	dim       int
+	nlist     int
+	m         int
+	ksub      int
+	dsub      int
+	centroids []float32 // nlist rows of dim floats
+	codebooks []float32 // m sub-quantizers of ksub rows of dsub floats
+	lists     []invertedList
+	location  map[string]int // vector ID -> inverted list
+	mutex     sync.RWMutex
+}
+
+// invertedList holds the IDs and PQ codes of the vectors assigned to one centroid
+type invertedList struct {
+	IDs   []string
+	Codes []byte // len(IDs) * m codes
+}
+
+// IVFPQCandidate is an approximate search hit
+type IVFPQCandidate struct {
+	ID    string
+	Score float32
+}
+
+// TrainIVFPQ trains the coarse quantizer and product-quantizer codebooks from
+// a sample of vectors. The returned index is empty; call Add to populate it.
+func TrainIVFPQ(samples [][]float32, config IVFPQConfig) (*IVFPQIndex, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no training vectors provided")
+	}
+	dim := len(samples[0])
+	if dim == 0 {
+		return nil, fmt.Errorf("training vectors have zero dimension")
+	}
+	if config.M <= 0 || dim%config.M != 0 {
+		return nil, fmt.Errorf("sub-quantizer count %d must divide embedding dimension %d", config.M, dim)
+	}
+	if config.NBits < 1 || config.NBits > 8 {
+		return nil, fmt.Errorf("nbits must be between 1 and 8, got %d", config.NBits)
+	}
+	ksub := 1 << config.NBits
+	if config.NList <= 0 {
+		return nil, fmt.Errorf("nlist must be positive, got %d", config.NList)
+	}
+	if len(samples) < config.NList || len(samples) < ksub {
+		return nil, fmt.Errorf("need at least %d training vectors for nlist=%d and nbits=%d, got %d",
+			max(config.NList, ksub), config.NList, config.NBits, len(samples))
+	}
+	if config.Iterations <= 0 {
+		config.Iterations = DefaultIVFPQConfig().Iterations
+	}
+
+	rng := rand.New(rand.NewSource(config.Seed))
+	n := len(samples)
+	dsub := dim / config.M
+
+	data := make([]float32, n*dim)
+	for i, sample := range samples {
+		if len(sample) != dim {
+			return nil, fmt.Errorf("training vector %d has dimension %d, expected %d", i, len(sample), dim)
+		}
+		copy(data[i*dim:(i+1)*dim], normalized(sample))
+	}
+
+	// Coarse quantizer
+	centroids := kmeans(data, dim, config.NList, config.Iterations, rng)
+
+	// Residuals relative to each vector's coarse centroid
+	residuals := make([]float32, n*dim)
+	for i := 0; i < n; i++ {
+		row := data[i*dim : (i+1)*dim]
+		c, _ := nearestCentroid(row, centroids, dim)
+		centroid := centroids[c*dim : (c+1)*dim]
+		res := residuals[i*dim : (i+1)*dim]
+		for j := range row {
+			res[j] = row[j] - centroid[j]
+		}
+	}
+
+	// One codebook per residual subspace
+	codebooks := make([]float32, config.M*ksub*dsub)
+	sub := make([]float32, n*dsub)
+	for m := 0; m < config.M; m++ {
+		for i := 0; i < n; i++ {
+			copy(sub[i*dsub:(i+1)*dsub], residuals[i*dim+m*dsub:i*dim+(m+1)*dsub])
+		}
+		codebook := kmeans(sub, dsub, ksub, config.Iterations, rng)
+		copy(codebooks[m*ksub*dsub:(m+1)*ksub*dsub], codebook)
+	}
+
+	return &IVFPQIndex{
+		dim:       dim,
+		nlist:     config.NList,
+		m:         config.M,
+		ksub:      ksub,
+		dsub:      dsub,
+		centroids: centroids,
+		codebooks: codebooks,
+		lists:     make([]invertedList, config.NList),
+		location:  make(map[string]int),
+	}, nil
+}
+
+// Add encodes a vector and appends it to its inverted list, replacing any
+// previous entry with the same ID
+func (idx *IVFPQIndex) Add(id string, embedding []float32) error {
+	if len(embedding) != idx.dim {
+		return fmt.Errorf("embedding dimension %d does not match index dimension %d", len(embedding), idx.dim)
+	}
+
+	vec := normalized(embedding)
+	c, _ := nearestCentroid(vec, idx.centroids, idx.dim)
+	centroid := idx.centroids[c*idx.dim : (c+1)*idx.dim]
+
+	residual := make([]float32, idx.dim)
+	for j := range vec {
+		residual[j] = vec[j] - centroid[j]
+	}
+
+	codes := make([]byte, idx.m)
+	for m := 0; m < idx.m; m++ {
+		codebook := idx.codebooks[m*idx.ksub*idx.dsub : (m+1)*idx.ksub*idx.dsub]
+		code, _ := nearestCentroid(residual[m*idx.dsub:(m+1)*idx.dsub], codebook, idx.dsub)
+		codes[m] = byte(code)
+	}
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.removeLocked(id)
+	list := &idx.lists[c]
+	list.IDs = append(list.IDs, id)
+	list.Codes = append(list.Codes, codes...)
+	idx.location[id] = c
+
+	return nil
+}
+
+// Remove deletes a vector from the index if present
+func (idx *IVFPQIndex) Remove(id string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	idx.removeLocked(id)
+}
+
+// removeLocked deletes id by swapping the last entry of its list into its slot
+func (idx *IVFPQIndex) removeLocked(id string) {
+	c, ok := idx.location[id]
+	if !ok {
+		return
+	}
+	delete(idx.location, id)
+
+	list := &idx.lists[c]
+	for i, listID := range list.IDs {
+		if listID != id {
+			continue
+		}
+		last := len(list.IDs) - 1
+		list.IDs[i] = list.IDs[last]
+		copy(list.Codes[i*idx.m:(i+1)*idx.m], list.Codes[last*idx.m:(last+1)*idx.m])
+		list.IDs = list.IDs[:last]
+		list.Codes = list.Codes[:last*idx.m]
+		return
+	}
+}
+
+// Reset removes every vector while keeping the trained codebooks
+func (idx *IVFPQIndex) Reset() {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	idx.lists = make([]invertedList, idx.nlist)
+	idx.location = make(map[string]int)
+}
+
+// Search scans the nprobe inverted lists closest to the query and returns up
+// to topK candidates ranked by asymmetric-distance (ADC) similarity estimates
+func (idx *IVFPQIndex) Search(queryEmbedding []float32, topK int, nprobe int) ([]IVFPQCandidate, error) {
+	if len(queryEmbedding) != idx.dim {
+		return nil, fmt.Errorf("query dimension %d does not match index dimension %d", len(queryEmbedding), idx.dim)
+	}
+	if nprobe <= 0 {
+		nprobe = 1
+	}
+	if nprobe > idx.nlist {
+		nprobe = idx.nlist
+	}
+
+	query := normalized(queryEmbedding)
+
+	// Rank coarse centroids by distance to the query
+	distances := make([]float32, idx.nlist)
+	order := make([]int, idx.nlist)
+	for c := 0; c < idx.nlist; c++ {
+		distances[c] = squaredL2(query, idx.centroids[c*idx.dim:(c+1)*idx.dim])
+		order[c] = c
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return distances[order[i]] < distances[order[j]]
+	})
+
+	// The inner product decomposes as q·c + Σ q_m·codeword_m, so the lookup
+	// table is shared by every probed list
+	table := make([]float32, idx.m*idx.ksub)
+	for m := 0; m < idx.m; m++ {
+		qsub := query[m*idx.dsub : (m+1)*idx.dsub]
+		for k := 0; k < idx.ksub; k++ {
+			offset := (m*idx.ksub + k) * idx.dsub
+			table[m*idx.ksub+k] = dotProduct(qsub, idx.codebooks[offset:offset+idx.dsub])
+		}
+	}
+
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	top := newTopKHeap[string](topK)
+	for _, c := range order[:nprobe] {
+		list := idx.lists[c]
+		base := dotProduct(query, idx.centroids[c*idx.dim:(c+1)*idx.dim])
+		for i, id := range list.IDs {
+			score := base
+			codes := list.Codes[i*idx.m : (i+1)*idx.m]
+			for m, code := range codes {
+				score += table[m*idx.ksub+int(code)]
+			}
+			top.Offer(id, score)
+		}
+	}
+
+	sorted := top.Sorted()
+	candidates := make([]IVFPQCandidate, len(sorted))
+	for i, entry := range sorted {
+		candidates[i] = IVFPQCandidate{ID: entry.Item, Score: entry.Score}
+	}
+	return candidates, nil
+}
+
+// Count returns the number of encoded vectors
+func (idx *IVFPQIndex) Count() int {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	return len(idx.location)
+}
+
+// Dimension returns the embedding dimension the index was trained on
+func (idx *IVFPQIndex) Dimension() int {
+	return idx.dim
+}
+
+// GetStats returns statistics about the index
+func (idx *IVFPQIndex) GetStats() map[string]interface{} {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	largest := 0
+	empty := 0
+	for _, list := range idx.lists {
+		if len(list.IDs) > largest {
+			largest = len(list.IDs)
+		}
+		if len(list.IDs) == 0 {
+			empty++
+		}
+	}
+
+	return map[string]interface{}{
+		"type":            "ivfpq",
+		"dimension":       idx.dim,
+		"nlist":           idx.nlist,
+		"subquantizers":   idx.m,
+		"codebook_size":   idx.ksub,
+		"encoded_vectors": len(idx.location),
+		"bytes_per_code":  idx.m,
+		"largest_list":    largest,
+		"empty_lists":     empty,
+	}
+}
+
+// ivfpqSnapshot is the gob-encoded on-disk representation of an index
+type ivfpqSnapshot struct {
+	Version   int
+	Dim       int
+	NList     int
+	M         int
+	KSub      int
+	Centroids []float32
+	Codebooks []float32
+	Lists     []invertedList
+}
+
+// Save writes the codebooks and encoded vectors to path
+func (idx *IVFPQIndex) Save(path string) error {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	snapshot := ivfpqSnapshot{
+		Version:   ivfpqFormatVersion,
+		Dim:       idx.dim,
+		NList:     idx.nlist,
+		M:         idx.m,
+		KSub:      idx.ksub,
+		Centroids: idx.centroids,
+		Codebooks: idx.codebooks,
+		Lists:     idx.lists,
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated index
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create index file: %w", err)
+	}
+
+	if err := gob.NewEncoder(file).Encode(&snapshot); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// LoadIVFPQIndex reads an index previously written by Save
+func LoadIVFPQIndex(path string) (*IVFPQIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var snapshot ivfpqSnapshot
+	if err := gob.NewDecoder(file).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode index %s: %w", path, err)
+	}
+	if snapshot.Version != ivfpqFormatVersion {
+		return nil, fmt.Errorf("unsupported index format version %d (expected %d); retrain with 'edgerag train'",
+			snapshot.Version, ivfpqFormatVersion)
+	}
+
+	idx := &IVFPQIndex{
+		dim:       snapshot.Dim,
+		nlist:     snapshot.NList,
+		m:         snapshot.M,
+		ksub:      snapshot.KSub,
+		dsub:      snapshot.Dim / snapshot.M,
+		centroids: snapshot.Centroids,
+		codebooks: snapshot.Codebooks,
+		lists:     snapshot.Lists,
+		location:  make(map[string]int),
+	}
+	if len(idx.lists) < idx.nlist {
+		idx.lists = append(idx.lists, make([]invertedList, idx.nlist-len(idx.lists))...)
+	}
+	for c, list := range idx.lists {
+		for _, id := range list.IDs {
+			idx.location[id] = c
+		}
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,190 @@
+package vectorstore
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// kmeans clusters the row-major points in data (n rows of dim floats) into k
+// centroids using k-means++ seeding followed by Lloyd iterations. The returned
+// slice holds k rows of dim floats.
+func kmeans(data []float32, dim, k, iterations int, rng *rand.Rand) []float32 {
+	n := len(data) / dim
+	centroids := kmeansPlusPlus(data, dim, k, rng)
+
+	assign := make([]int, n)
+	for i := range assign {
+		assign[i] = -1
+	}
+
+	sums := make([]float64, k*dim)
+	counts := make([]int, k)
+
+	for it := 0; it < iterations; it++ {
+		changed := assignNearest(data, dim, centroids, assign)
+		if changed == 0 {
+			break
+		}
+
+		for i := range sums {
+			sums[i] = 0
+		}
+		for i := range counts {
+			counts[i] = 0
+		}
+
+		for i := 0; i < n; i++ {
+			c := assign[i]
+			counts[c]++
+			row := data[i*dim : (i+1)*dim]
+			sum := sums[c*dim : (c+1)*dim]
+			for j, v := range row {
+				sum[j] += float64(v)
+			}
+		}
+
+		for c := 0; c < k; c++ {
+			centroid := centroids[c*dim : (c+1)*dim]
+			if counts[c] == 0 {
+				// Re-seed empty clusters with a random point so every
+				// centroid stays useful
+				p := rng.Intn(n)
+				copy(centroid, data[p*dim:(p+1)*dim])
+				continue
+			}
+			for j := range centroid {
+				centroid[j] = float32(sums[c*dim+j] / float64(counts[c]))
+			}
+		}
+	}
+
+	return centroids
+}
+
+// kmeansPlusPlus picks k initial centroids, each chosen with probability
+// proportional to its squared distance from the centroids picked so far
+func kmeansPlusPlus(data []float32, dim, k int, rng *rand.Rand) []float32 {
+	n := len(data) / dim
+	centroids := make([]float32, k*dim)
+
+	first := rng.Intn(n)
+	copy(centroids[:dim], data[first*dim:(first+1)*dim])
+
+	distances := make([]float64, n)
+	for i := 0; i < n; i++ {
+		distances[i] = float64(squaredL2(data[i*dim:(i+1)*dim], centroids[:dim]))
+	}
+
+	for c := 1; c < k; c++ {
+		var total float64
+		for _, d := range distances {
+			total += d
+		}
+
+		next := rng.Intn(n)
+		if total > 0 {
+			target := rng.Float64() * total
+			for i, d := range distances {
+				target -= d
+				if target <= 0 {
+					next = i
+					break
+				}
+			}
+		}
+
+		centroid := centroids[c*dim : (c+1)*dim]
+		copy(centroid, data[next*dim:(next+1)*dim])
+
+		for i := 0; i < n; i++ {
+			d := float64(squaredL2(data[i*dim:(i+1)*dim], centroid))
+			if d < distances[i] {
+				distances[i] = d
+			}
+		}
+	}
+
+	return centroids
+}
+
+// assignNearest assigns every point to its nearest centroid, spreading the work
+// across all CPUs, and returns how many assignments changed
+func assignNearest(data []float32, dim int, centroids []float32, assign []int) int {
+	n := len(data) / dim
+	workers := runtime.NumCPU()
+	if workers > n {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	per := (n + workers - 1) / workers
+	changed := make([]int, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start := w * per
+		end := start + per
+		if end > n {
+			end = n
+		}
+		if start >= end {
+			continue
+		}
+
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				c, _ := nearestCentroid(data[i*dim:(i+1)*dim], centroids, dim)
+				if assign[i] != c {
+					assign[i] = c
+					changed[w]++
+				}
+			}
+		}(w, start, end)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, c := range changed {
+		total += c
+	}
+	return total
+}
+
+// nearestCentroid returns the index of and squared distance to the centroid
+// closest to vec
+func nearestCentroid(vec []float32, centroids []float32, dim int) (int, float32) {
+	best := 0
+	bestDist := float32(math.MaxFloat32)
+	for c := 0; c*dim < len(centroids); c++ {
+		d := squaredL2(vec, centroids[c*dim:(c+1)*dim])
+		if d < bestDist {
+			best = c
+			bestDist = d
+		}
+	}
+	return best, bestDist
+}
+
+// normalized returns a unit-length copy of v (or a plain copy if v is zero)
+func normalized(v []float32) []float32 {
+	out := make([]float32, len(v))
+	var norm float32
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		copy(out, v)
+		return out
+	}
+	inv := 1 / float32(math.Sqrt(float64(norm)))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LockMode selects how a persistent store coordinates access to its data
+// directory with other processes
+type LockMode int
+
+const (
+	// LockNone performs no locking
+	LockNone LockMode = iota
+	// LockShared allows any number of concurrent readers but no writer
+	LockShared
+	// LockExclusive allows a single writer and no readers
+	LockExclusive
+)
+
+func (m LockMode) String() string {
+	switch m {
+	case LockShared:
+		return "shared"
+	case LockExclusive:
+		return "exclusive"
+	default:
+		return "none"
+	}
+}
+
+const (
+	// lockFileName is the file the advisory lock is taken on
+	lockFileName = ".lock"
+	// lockOwnerFileName records who holds the exclusive lock
+	lockOwnerFileName = ".lock.owner"
+	// lockPollInterval is how often a busy lock is retried while waiting
+	lockPollInterval = 100 * time.Millisecond
+)
+
+// ErrStoreLocked is returned when the data directory is locked by another
+// process for longer than the configured lock timeout
+var ErrStoreLocked = errors.New("vector store is locked by another process")
+
+// errWouldBlock is returned by the platform lock primitives when the lock is
+// held elsewhere
+var errWouldBlock = errors.New("lock is held by another process")
+
+// LockOwner describes the process holding, or last holding, the exclusive lock
+type LockOwner struct {
+	PID        int       `json:"pid"`
+	Hostname   string    `json:"hostname"`
+	Command    string    `json:"command"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+func (o *LockOwner) String() string {
+	return fmt.Sprintf("pid %d on %s (%s) since %s",
+		o.PID, o.Hostname, o.Command, o.AcquiredAt.Format(time.RFC3339))
+}
+
+// dirLock is an advisory lock on a data directory. The lock itself is an OS
+// file lock, which the kernel releases if the holder dies; exclusive holders
+// additionally write an owner record so waiting processes can report who is
+// busy and crashed writers can be detected afterwards.
+type dirLock struct {
+	dataDir string
+	mode    LockMode
+	file    *os.File
+	stale   *LockOwner
+}
+
+// acquireDirLock locks dataDir in the given mode, waiting up to timeout
+func acquireDirLock(dataDir string, mode LockMode, timeout time.Duration) (*dirLock, error) {
+	file, err := os.OpenFile(filepath.Join(dataDir, lockFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err = lockFile(file, mode == LockExclusive)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errWouldBlock) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", dataDir, err)
+		}
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			file.Close()
+			return nil, busyError(dataDir, mode, timeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	lock := &dirLock{dataDir: dataDir, mode: mode, file: file}
+
+	// Holding the lock means no live process holds it exclusively, so any
+	// owner record left behind belongs to a writer that died without
+	// releasing it
+	if owner, err := readLockOwner(dataDir); err == nil {
+		lock.stale = owner
+		os.Remove(filepath.Join(dataDir, lockOwnerFileName))
+	}
+
+	if mode == LockExclusive {
+		if err := writeLockOwner(dataDir); err != nil {
+			lock.release()
+			return nil, err
+		}
+	}
+
+	return lock, nil
+}
+
+// release drops the lock and, for exclusive holders, the owner record
+func (l *dirLock) release() error {
+	if l.file == nil {
+		return nil
+	}
+	if l.mode == LockExclusive {
+		os.Remove(filepath.Join(l.dataDir, lockOwnerFileName))
+	}
+	unlockFile(l.file)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// busyError builds a descriptive error for a lock that could not be acquired
+func busyError(dataDir string, mode LockMode, timeout time.Duration) error {
+	var detail strings.Builder
+	fmt.Fprintf(&detail, "%s (wanted %s access", dataDir, mode)
+	if timeout > 0 {
+		fmt.Fprintf(&detail, ", waited %s", timeout)
+	}
+	detail.WriteString(")")
+
+	owner, err := readLockOwner(dataDir)
+	switch {
+	case err == nil:
+		fmt.Fprintf(&detail, "; held by %s", owner)
+		if hostname, _ := os.Hostname(); owner.Hostname == hostname && !processAlive(owner.PID) {
+			detail.WriteString(" which is no longer running; the lock may be held by one of its child processes or a network filesystem")
+		}
+	case mode == LockExclusive:
+		detail.WriteString("; held by one or more readers")
+	}
+
+	return fmt.Errorf("%w: %s", ErrStoreLocked, detail.String())
+}
+
+func readLockOwner(dataDir string) (*LockOwner, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, lockOwnerFileName))
+	if err != nil {
+		return nil, err
+	}
+	var owner LockOwner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return nil, err
+	}
+	return &owner, nil
+}
+
+func writeLockOwner(dataDir string) error {
+	hostname, _ := os.Hostname()
+	owner := LockOwner{
+		PID:        os.Getpid(),
+		Hostname:   hostname,
+		Command:    strings.Join(os.Args, " "),
+		AcquiredAt: time.Now(),
+	}
+	data, err := json.Marshal(owner)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock owner: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, lockOwnerFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write lock owner: %w", err)
+	}
+	return nil
+}
@@ -2,11 +2,14 @@ package vectorstore
 
 import (
 	"fmt"
-	"math"
-	"sort"
+	"runtime"
 	"sync"
 )
 
+// parallelSearchThreshold is the store size above which searches are split
+// across goroutines; below it the scheduling overhead outweighs the gain
+const parallelSearchThreshold = 8192
+
 // Vector represents a stored vector with metadata
 type Vector struct {
 	ID        string                 `json:"id"`
@@ -18,22 +21,52 @@ type Vector struct {
 // SearchResult represents a search result with similarity score
 type SearchResult struct {
 	Vector
+	// Score is the similarity to the query under the store's metric; higher is
+	// always more similar and search thresholds are compared against it:
+	//   cosine:    cosine similarity in [-1, 1]
+	//   dot:       raw inner product (equals cosine for unit-length embeddings)
+	//   euclidean: 1 / (1 + L2 distance) in (0, 1], so 0.5 means distance 1
 	Score float32 `json:"score"`
 }
 
-// MemoryStore implements an in-memory vector store
+// MemoryStore implements an in-memory vector store. Embeddings live in one
+// contiguous row-major slab so a brute-force scan walks memory sequentially
+// instead of chasing a pointer per vector; row r of every parallel slice
+// describes the same vector.
 type MemoryStore struct {
-	vectors map[string]*Vector
-	mutex   sync.RWMutex
+	dim      int              // embedding dimension, fixed by the first vector
+	matrix   []float32        // len(ids) rows of dim floats
+	norms    []float32        // cached Euclidean length per row
+	ids      []string         // row -> vector ID
+	contents []string         // row -> content
+	metadata []map[string]interface{}
+	rows     map[string]int // vector ID -> row
+	options  Options
+	mutex    sync.RWMutex
 }
 
-// NewMemoryStore creates a new in-memory vector store
+// NewMemoryStore creates a new in-memory vector store using cosine similarity
 func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithOptions(DefaultOptions())
+}
+
+// NewMemoryStoreWithOptions creates a new in-memory vector store with the
+// given metric, normalization and search settings
+func NewMemoryStoreWithOptions(options Options) *MemoryStore {
+	if options.Metric == "" {
+		options.Metric = MetricCosine
+	}
 	return &MemoryStore{
-		vectors: make(map[string]*Vector),
+		rows:    make(map[string]int),
+		options: options,
 	}
 }
 
+// Metric returns the similarity metric used for search
+func (m *MemoryStore) Metric() Metric {
+	return m.options.Metric
+}
+
 // Add stores a vector in the memory store
 func (m *MemoryStore) Add(id string, embedding []float32, content string, metadata map[string]interface{}) error {
 	m.mutex.Lock()
@@ -43,27 +76,77 @@ func (m *MemoryStore) Add(id string, embedding []float32, content string, metada
 		metadata = make(map[string]interface{})
 	}
 
-	m.vectors[id] = &Vector{
+	return m.insertLocked(&Vector{
 		ID:        id,
 		Embedding: embedding,
 		Content:   content,
 		Metadata:  metadata,
+	})
+}
+
+// insertLocked stores a vector, normalizing it if configured and caching its
+// norm. An existing vector with the same ID is overwritten in place. The
+// caller must hold the write lock.
+func (m *MemoryStore) insertLocked(vector *Vector) error {
+	if len(vector.Embedding) == 0 {
+		return fmt.Errorf("vector %s has an empty embedding", vector.ID)
+	}
+	if len(m.ids) == 0 {
+		m.dim = len(vector.Embedding)
+	}
+	if len(vector.Embedding) != m.dim {
+		return fmt.Errorf("vector %s has dimension %d, store dimension is %d",
+			vector.ID, len(vector.Embedding), m.dim)
+	}
+
+	embedding := vector.Embedding
+	if m.options.Normalize {
+		embedding = normalized(embedding)
 	}
 
+	row, exists := m.rows[vector.ID]
+	if !exists {
+		row = len(m.ids)
+		m.rows[vector.ID] = row
+		m.ids = append(m.ids, vector.ID)
+		m.contents = append(m.contents, "")
+		m.metadata = append(m.metadata, nil)
+		m.norms = append(m.norms, 0)
+		m.matrix = append(m.matrix, make([]float32, m.dim)...)
+	}
+
+	copy(m.matrix[row*m.dim:(row+1)*m.dim], embedding)
+	m.norms[row] = vectorNorm(embedding)
+	m.contents[row] = vector.Content
+	m.metadata[row] = vector.Metadata
+
 	return nil
 }
 
+// vectorAt materializes the vector stored in row. The embedding is copied so
+// callers never observe rows moving when other vectors are deleted.
+func (m *MemoryStore) vectorAt(row int) *Vector {
+	embedding := make([]float32, m.dim)
+	copy(embedding, m.matrix[row*m.dim:(row+1)*m.dim])
+	return &Vector{
+		ID:        m.ids[row],
+		Embedding: embedding,
+		Content:   m.contents[row],
+		Metadata:  m.metadata[row],
+	}
+}
+
 // Get retrieves a vector by ID
 func (m *MemoryStore) Get(id string) (*Vector, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	vector, exists := m.vectors[id]
+	row, exists := m.rows[id]
 	if !exists {
 		return nil, fmt.Errorf("vector with ID %s not found", id)
 	}
 
-	return vector, nil
+	return m.vectorAt(row), nil
 }
 
 // Delete removes a vector by ID
@@ -71,11 +154,31 @@ func (m *MemoryStore) Delete(id string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if _, exists := m.vectors[id]; !exists {
+	row, exists := m.rows[id]
+	if !exists {
 		return fmt.Errorf("vector with ID %s not found", id)
 	}
 
-	delete(m.vectors, id)
+	// Move the last row into the freed slot to keep the slab dense
+	last := len(m.ids) - 1
+	if row != last {
+		copy(m.matrix[row*m.dim:(row+1)*m.dim], m.matrix[last*m.dim:(last+1)*m.dim])
+		m.norms[row] = m.norms[last]
+		m.ids[row] = m.ids[last]
+		m.contents[row] = m.contents[last]
+		m.metadata[row] = m.metadata[last]
+		m.rows[m.ids[row]] = row
+	}
+
+	m.matrix = m.matrix[:last*m.dim]
+	m.norms = m.norms[:last]
+	m.ids = m.ids[:last]
+	m.contents[last] = ""
+	m.contents = m.contents[:last]
+	m.metadata[last] = nil
+	m.metadata = m.metadata[:last]
+	delete(m.rows, id)
+
 	return nil
 }
 
@@ -84,42 +187,174 @@ func (m *MemoryStore) Search(queryEmbedding []float32, topK int, threshold float
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	if len(m.vectors) == 0 {
-		return []*SearchResult{}, nil
+	return m.searchLocked(queryEmbedding, topK, threshold, nil)
+}
+
+// SearchFiltered finds the most similar vectors whose metadata matches filter
+func (m *MemoryStore) SearchFiltered(queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if len(filter) == 0 {
+		return m.searchLocked(queryEmbedding, topK, threshold, nil)
 	}
+	return m.searchLocked(queryEmbedding, topK, threshold, func(row int) bool {
+		return filter.Matches(m.metadata[row])
+	})
+}
 
-	results := make([]*SearchResult, 0)
+// FindByMetadata returns the vectors whose metadata matches filter
+func (m *MemoryStore) FindByMetadata(filter Filter) ([]*Vector, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 
-	// Calculate similarity scores for all vectors
-	for _, vector := range m.vectors {
-		similarity := cosineSimilarity(queryEmbedding, vector.Embedding)
-		
-		if similarity >= threshold {
-			results = append(results, &SearchResult{
-				Vector: *vector,
-				Score:  similarity,
-			})
+	var vectors []*Vector
+	for row := range m.ids {
+		if filter.Matches(m.metadata[row]) {
+			vectors = append(vectors, m.vectorAt(row))
 		}
 	}
+	return vectors, nil
+}
 
-	// Sort by similarity score (descending)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
+// searchIDs ranks only the vectors with the given IDs
+func (m *MemoryStore) searchIDs(queryEmbedding []float32, topK int, threshold float32, ids []string) ([]*SearchResult, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	allowed := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if row, ok := m.rows[id]; ok {
+			allowed[row] = true
+		}
+	}
+	return m.searchLocked(queryEmbedding, topK, threshold, func(row int) bool {
+		return allowed[row]
 	})
+}
+
+// searchLocked ranks rows accepted by allow (all rows when nil). The caller
+// must hold the read lock.
+func (m *MemoryStore) searchLocked(queryEmbedding []float32, topK int, threshold float32, allow func(row int) bool) ([]*SearchResult, error) {
+	n := len(m.ids)
+	if n == 0 || topK <= 0 {
+		return []*SearchResult{}, nil
+	}
+	if len(queryEmbedding) != m.dim {
+		return nil, fmt.Errorf("query dimension %d does not match store dimension %d", len(queryEmbedding), m.dim)
+	}
 
-	// Limit to topK results
-	if len(results) > topK {
-		results = results[:topK]
+	workers := m.options.SearchWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if n < parallelSearchThreshold {
+		workers = 1
+	}
+
+	var top []scored[int]
+	if workers == 1 {
+		top = m.scanRows(queryEmbedding, 0, n, topK, threshold, allow).Sorted()
+	} else {
+		// Each worker keeps its own top-k over a contiguous block of rows;
+		// the partial results are merged at the end
+		per := (n + workers - 1) / workers
+		partials := make([]*topKHeap[int], workers)
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			start := w * per
+			end := start + per
+			if end > n {
+				end = n
+			}
+			if start >= end {
+				continue
+			}
+
+			wg.Add(1)
+			go func(w, start, end int) {
+				defer wg.Done()
+				partials[w] = m.scanRows(queryEmbedding, start, end, topK, threshold, allow)
+			}(w, start, end)
+		}
+		wg.Wait()
+
+		merged := newTopKHeap[int](topK)
+		for _, partial := range partials {
+			if partial == nil {
+				continue
+			}
+			for _, entry := range partial.entries {
+				merged.Offer(entry.Item, entry.Score)
+			}
+		}
+		top = merged.Sorted()
+	}
+
+	results := make([]*SearchResult, len(top))
+	for i, entry := range top {
+		results[i] = &SearchResult{
+			Vector: *m.vectorAt(entry.Item),
+			Score:  entry.Score,
+		}
 	}
 
 	return results, nil
 }
 
+// scanRows scores rows [start, end) accepted by allow against the query and
+// keeps the best topK at or above threshold. The metric switch sits outside
+// the loop so each case is a tight pass over the contiguous slab.
+func (m *MemoryStore) scanRows(query []float32, start, end, topK int, threshold float32, allow func(row int) bool) *topKHeap[int] {
+	top := newTopKHeap[int](topK)
+	dim := m.dim
+
+	switch m.options.Metric {
+	case MetricDot:
+		for row := start; row < end; row++ {
+			if allow != nil && !allow(row) {
+				continue
+			}
+			score := dotProduct(query, m.matrix[row*dim:(row+1)*dim])
+			if score >= threshold {
+				top.Offer(row, score)
+			}
+		}
+	case MetricEuclidean:
+		for row := start; row < end; row++ {
+			if allow != nil && !allow(row) {
+				continue
+			}
+			score := euclideanScore(squaredL2(query, m.matrix[row*dim:(row+1)*dim]))
+			if score >= threshold {
+				top.Offer(row, score)
+			}
+		}
+	default:
+		queryNorm := vectorNorm(query)
+		for row := start; row < end; row++ {
+			if allow != nil && !allow(row) {
+				continue
+			}
+			var score float32
+			if queryNorm != 0 && m.norms[row] != 0 {
+				score = dotProduct(query, m.matrix[row*dim:(row+1)*dim]) / (queryNorm * m.norms[row])
+			}
+			if score >= threshold {
+				top.Offer(row, score)
+			}
+		}
+	}
+
+	return top
+}
+
 // Count returns the number of vectors in the store
 func (m *MemoryStore) Count() int {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
-	return len(m.vectors)
+	return len(m.ids)
 }
 
 // List returns all vector IDs
@@ -127,10 +362,8 @@ func (m *MemoryStore) List() []string {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	ids := make([]string, 0, len(m.vectors))
-	for id := range m.vectors {
-		ids = append(ids, id)
-	}
+	ids := make([]string, len(m.ids))
+	copy(ids, m.ids)
 	return ids
 }
 
@@ -138,28 +371,19 @@ func (m *MemoryStore) List() []string {
 func (m *MemoryStore) Clear() {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	m.vectors = make(map[string]*Vector)
-}
-
-// cosineSimilarity calculates the cosine similarity between two vectors
-func cosineSimilarity(a, b []float32) float32 {
-	if len(a) != len(b) {
-		return 0.0
-	}
-
-	var dotProduct, normA, normB float32
 
-	for i := 0; i < len(a); i++ {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
-	}
-
-	if normA == 0.0 || normB == 0.0 {
-		return 0.0
-	}
+	m.dim = 0
+	m.matrix = nil
+	m.norms = nil
+	m.ids = nil
+	m.contents = nil
+	m.metadata = nil
+	m.rows = make(map[string]int)
+}
 
-	return dotProduct / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
+// Close is a no-op for in-memory stores
+func (m *MemoryStore) Close() error {
+	return nil
 }
 
 // GetStats returns statistics about the vector store
@@ -168,16 +392,15 @@ func (m *MemoryStore) GetStats() map[string]interface{} {
 	defer m.mutex.RUnlock()
 
 	stats := map[string]interface{}{
-		"total_vectors": len(m.vectors),
+		"total_vectors": len(m.ids),
+		"metric":        string(m.options.Metric),
+		"normalized":    m.options.Normalize,
 	}
 
-	if len(m.vectors) > 0 {
-		// Get dimension from first vector
-		for _, vector := range m.vectors {
-			stats["dimension"] = len(vector.Embedding)
-			break
-		}
+	if len(m.ids) > 0 {
+		stats["dimension"] = m.dim
+		stats["matrix_bytes"] = len(m.matrix) * 4
 	}
 
 	return stats
-} 
\ No newline at end of file
+}
@@ -0,0 +1,120 @@
+package vectorstore
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// randomEmbeddings returns count reproducible random embeddings
+func randomEmbeddings(count, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	embeddings := make([][]float32, count)
+	for i := range embeddings {
+		embeddings[i] = make([]float32, dim)
+		for j := range embeddings[i] {
+			embeddings[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	return embeddings
+}
+
+// filledStore returns a memory store holding count random embeddings
+func filledStore(tb testing.TB, options Options, count, dim int) *MemoryStore {
+	tb.Helper()
+	store := NewMemoryStoreWithOptions(options)
+	for i, embedding := range randomEmbeddings(count, dim, 1) {
+		if err := store.Add(fmt.Sprintf("vec_%d", i), embedding, "", nil); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestMemoryStoreSearchMatchesBruteForce(t *testing.T) {
+	const count, dim, topK = 2000, 32, 10
+	embeddings := randomEmbeddings(count, dim, 1)
+	queries := randomEmbeddings(20, dim, 2)
+
+	for _, metric := range []Metric{MetricCosine, MetricDot, MetricEuclidean} {
+		for _, workers := range []int{1, 0} {
+			t.Run(fmt.Sprintf("%s/workers=%d", metric, workers), func(t *testing.T) {
+				store := filledStore(t, Options{Metric: metric, SearchWorkers: workers}, count, dim)
+				for _, query := range queries {
+					results, err := store.Search(query, topK, -1)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					type hit struct {
+						id    string
+						score float32
+					}
+					hits := make([]hit, count)
+					for i, embedding := range embeddings {
+						hits[i] = hit{fmt.Sprintf("vec_%d", i), metric.Similarity(query, embedding)}
+					}
+					sort.Slice(hits, func(a, b int) bool { return hits[a].score > hits[b].score })
+
+					if len(results) != topK {
+						t.Fatalf("got %d results, want %d", len(results), topK)
+					}
+					for i, result := range results {
+						if result.ID != hits[i].id {
+							t.Fatalf("result %d: got %s (%.5f), want %s (%.5f)",
+								i, result.ID, result.Score, hits[i].id, hits[i].score)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkMemoryStoreSearch(b *testing.B) {
+	const dim, topK = 384, 5
+	queries := randomEmbeddings(64, dim, 2)
+
+	for _, count := range []int{10000, 100000} {
+		for _, workers := range []int{1, 0} {
+			b.Run(fmt.Sprintf("vectors=%d/workers=%d", count, workers), func(b *testing.B) {
+				store := filledStore(b, Options{SearchWorkers: workers}, count, dim)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := store.Search(queries[i%len(queries)], topK, -1); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkMapSearch times the search the flat matrix replaced: a map of
+// vectors, both norms recomputed per comparison and every result sorted. It
+// is the baseline BenchmarkMemoryStoreSearch is compared against.
+func BenchmarkMapSearch(b *testing.B) {
+	const dim, topK = 384, 5
+	queries := randomEmbeddings(64, dim, 2)
+
+	for _, count := range []int{10000, 100000} {
+		b.Run(fmt.Sprintf("vectors=%d", count), func(b *testing.B) {
+			vectors := make(map[string]*Vector, count)
+			for i, embedding := range randomEmbeddings(count, dim, 1) {
+				id := fmt.Sprintf("vec_%d", i)
+				vectors[id] = &Vector{ID: id, Embedding: embedding}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				query := queries[i%len(queries)]
+				results := make([]*SearchResult, 0, len(vectors))
+				for _, vector := range vectors {
+					results = append(results, &SearchResult{Vector: *vector, Score: MetricCosine.Similarity(query, vector.Embedding)})
+				}
+				sort.Slice(results, func(a, b int) bool { return results[a].Score > results[b].Score })
+				_ = results[:topK]
+			}
+		})
+	}
+}
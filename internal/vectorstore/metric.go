@@ -0,0 +1,111 @@
+package vectorstore
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Metric identifies the similarity function a store ranks vectors by. Every
+// metric produces a score where higher means more similar, so SearchResult.Score
+// and the search threshold always compare the same way.
+type Metric string
+
+const (
+	// MetricCosine scores by cosine similarity in [-1, 1]
+	MetricCosine Metric = "cosine"
+	// MetricDot scores by raw inner product; intended for models whose
+	// embeddings are already unit length, where it equals cosine similarity
+	MetricDot Metric = "dot"
+	// MetricEuclidean scores by 1 / (1 + L2 distance), in (0, 1]
+	MetricEuclidean Metric = "euclidean"
+)
+
+// ParseMetric converts a metric name (as used in config files) to a Metric
+func ParseMetric(name string) (Metric, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "cosine", "cos":
+		return MetricCosine, nil
+	case "dot", "dot_product", "inner_product", "ip":
+		return MetricDot, nil
+	case "euclidean", "l2":
+		return MetricEuclidean, nil
+	default:
+		return "", fmt.Errorf("unknown distance metric %q (expected cosine, dot or euclidean)", name)
+	}
+}
+
+// Similarity scores two embeddings under the metric. Embeddings of
+// different dimensions score 0.
+func (m Metric) Similarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	switch m {
+	case MetricDot:
+		return dotProduct(a, b)
+	case MetricEuclidean:
+		return euclideanScore(squaredL2(a, b))
+	default:
+		normA, normB := vectorNorm(a), vectorNorm(b)
+		if normA == 0 || normB == 0 {
+			return 0
+		}
+		return dotProduct(a, b) / (normA * normB)
+	}
+}
+
+// euclideanScore maps a squared L2 distance to a higher-is-better score in (0, 1]
+func euclideanScore(squaredDistance float32) float32 {
+	return float32(1 / (1 + math.Sqrt(float64(squaredDistance))))
+}
+
+// dotProduct returns the inner product of two equal-length vectors. The loop
+// is unrolled with independent accumulators so the CPU can overlap the
+// multiply-adds instead of serializing on a single sum.
+func dotProduct(a, b []float32) float32 {
+	var s0, s1, s2, s3 float32
+	n := len(a)
+	b = b[:n]
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < n; i++ {
+		s0 += a[i] * b[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// squaredL2 returns the squared Euclidean distance between two equal-length
+// vectors, unrolled like dotProduct
+func squaredL2(a, b []float32) float32 {
+	var s0, s1, s2, s3 float32
+	n := len(a)
+	b = b[:n]
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		d0 := a[i] - b[i]
+		d1 := a[i+1] - b[i+1]
+		d2 := a[i+2] - b[i+2]
+		d3 := a[i+3] - b[i+3]
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
+	}
+	for ; i < n; i++ {
+		d := a[i] - b[i]
+		s0 += d * d
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// vectorNorm returns the Euclidean length of v
+func vectorNorm(v []float32) float32 {
+	return float32(math.Sqrt(float64(dotProduct(v, v))))
+}
@@ -0,0 +1,40 @@
+package vectorstore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Backend identifies how a store persists its data
+type Backend string
+
+const (
+	// BackendJSON keeps one JSON file per vector (PersistentStore)
+	BackendJSON Backend = "json"
+	// BackendSQLite keeps everything in a single SQLite database (SQLiteStore)
+	BackendSQLite Backend = "sqlite"
+)
+
+// ParseBackend converts a backend name (as used in config files) to a Backend
+func ParseBackend(name string) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "json", "files":
+		return BackendJSON, nil
+	case "sqlite", "sqlite3":
+		return BackendSQLite, nil
+	default:
+		return "", fmt.Errorf("unknown store backend %q (expected json or sqlite)", name)
+	}
+}
+
+// Open opens the persistent store for backend in dataDir
+func Open(backend Backend, dataDir string, options Options) (VectorStore, error) {
+	switch backend {
+	case BackendSQLite:
+		return NewSQLiteStore(dataDir, options)
+	case BackendJSON, "":
+		return NewPersistentStoreWithOptions(dataDir, options)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", backend)
+	}
+}
@@ -0,0 +1,28 @@
+package vectorstore
+
+import "time"
+
+// Options configures a vector store
+type Options struct {
+	// Metric is the similarity function used for search. When empty,
+	// persistent stores use the metric recorded with their vectors, and
+	// other stores cosine.
+	Metric Metric
+	// Normalize scales embeddings to unit length on insert so cosine
+	// similarity reduces to a dot product
+	Normalize bool
+	// SearchWorkers caps the goroutines used to scan large stores
+	// (0 uses one per CPU)
+	SearchWorkers int
+	// Lock selects how a persistent store locks its data directory against
+	// other processes; in-memory stores ignore it
+	Lock LockMode
+	// LockTimeout is how long to wait for a busy data directory: 0 fails
+	// immediately and a negative value waits indefinitely
+	LockTimeout time.Duration
+}
+
+// DefaultOptions returns the options used by NewMemoryStore and NewPersistentStore
+func DefaultOptions() Options {
+	return Options{Metric: MetricCosine}
+}
@@ -11,30 +11,99 @@ import (
 type PersistentStore struct {
 	*MemoryStore
 	dataDir string
+	lock    *dirLock
 }
 
-// NewPersistentStore creates a new persistent vector store
+// NewPersistentStore creates a new persistent vector store using cosine similarity
 func NewPersistentStore(dataDir string) (*PersistentStore, error) {
+	return NewPersistentStoreWithOptions(dataDir, DefaultOptions())
+}
+
+// NewPersistentStoreWithOptions creates a new persistent vector store with the
+// given options. When options.Lock is set the data directory stays locked
+// until Close is called; a store opened with LockShared is read-only. The
+// metric and normalization are recorded in the data directory and reconciled
+// with options as described for resolveSettings.
+func NewPersistentStoreWithOptions(dataDir string, options Options) (*PersistentStore, error) {
 	// Ensure data directory exists
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
 	store := &PersistentStore{
-		MemoryStore: NewMemoryStore(),
-		dataDir:     dataDir,
+		dataDir: dataDir,
+	}
+
+	// Lock before reading so a concurrent writer can't be observed mid-update
+	if options.Lock != LockNone {
+		lock, err := acquireDirLock(dataDir, options.Lock, options.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		store.lock = lock
+	}
+
+	settingsPath := filepath.Join(dataDir, SettingsFileName)
+	stored, err := readSettingsFile(settingsPath)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	files, _ := filepath.Glob(filepath.Join(dataDir, "*.json"))
+	options, err = resolveSettings(options, stored, len(files) > 0)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("vector store %s: %w", dataDir, err)
+	}
+	store.MemoryStore = NewMemoryStoreWithOptions(options)
+	if options.Lock != LockShared && (stored == nil || *stored != options.settings()) {
+		if err := writeSettingsFile(settingsPath, options.settings()); err != nil {
+			store.Close()
+			return nil, err
+		}
 	}
 
 	// Load existing vectors from disk
 	if err := store.loadFromDisk(); err != nil {
+		store.Close()
 		return nil, fmt.Errorf("failed to load vectors from disk: %w", err)
 	}
 
 	return store, nil
 }
 
+// Close releases the data directory lock, if any
+func (p *PersistentStore) Close() error {
+	if p.lock == nil {
+		return nil
+	}
+	return p.lock.release()
+}
+
+// StaleLock returns the owner record left behind by a writer that exited
+// without releasing the lock, or nil. Such a writer may have stopped partway
+// through indexing a document.
+func (p *PersistentStore) StaleLock() *LockOwner {
+	if p.lock == nil {
+		return nil
+	}
+	return p.lock.stale
+}
+
+// checkWritable rejects writes to a store opened for shared (read-only) access
+func (p *PersistentStore) checkWritable() error {
+	if p.lock != nil && p.lock.mode == LockShared {
+		return fmt.Errorf("vector store %s is opened read-only", p.dataDir)
+	}
+	return nil
+}
+
 // Add stores a vector and persists it to disk
 func (p *PersistentStore) Add(id string, embedding []float32, content string, metadata map[string]interface{}) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
 	// Add to memory first
 	if err := p.MemoryStore.Add(id, embedding, content, metadata); err != nil {
 		return err
@@ -46,6 +115,10 @@ func (p *PersistentStore) Add(id string, embedding []float32, content string, me
 
 // Delete removes a vector from memory and disk
 func (p *PersistentStore) Delete(id string) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
 	// Remove from memory
 	if err := p.MemoryStore.Delete(id); err != nil {
 		return err
@@ -60,8 +133,12 @@ func (p *PersistentStore) Delete(id string) error {
 	return nil
 }
 
-// Clear removes all vectors from memory and disk
+// Clear removes all vectors from memory and disk. Read-only stores are left
+// untouched.
 func (p *PersistentStore) Clear() {
+	if p.checkWritable() != nil {
+		return
+	}
 	p.MemoryStore.Clear()
 
 	// Remove all files from disk
@@ -75,12 +152,9 @@ func (p *PersistentStore) Clear() {
 
 // saveToDisk saves a single vector to disk
 func (p *PersistentStore) saveToDisk(id string) error {
-	p.mutex.RLock()
-	vector, exists := p.vectors[id]
-	p.mutex.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("vector with ID %s not found", id)
+	vector, err := p.MemoryStore.Get(id)
+	if err != nil {
+		return err
 	}
 
 	filePath := filepath.Join(p.dataDir, id+".json")
@@ -111,8 +185,11 @@ func (p *PersistentStore) loadFromDisk() error {
 		}
 
 		p.mutex.Lock()
-		p.vectors[vector.ID] = &vector
+		err = p.insertLocked(&vector)
 		p.mutex.Unlock()
+		if err != nil {
+			continue // Skip vectors that don't fit the store's dimension
+		}
 	}
 
 	return nil
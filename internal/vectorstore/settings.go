@@ -0,0 +1,96 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// SettingsFileName is the file in a data directory where a PersistentStore
+// records its Settings. It is not named *.json so it is never mistaken for
+// a vector.
+const SettingsFileName = "store.conf"
+
+// ErrSettingsMismatch is returned when a store is opened with a metric or
+// normalization that conflicts with the one its vectors were indexed with
+var ErrSettingsMismatch = errors.New("store settings mismatch")
+
+// Settings are the options that decide how a store's vectors are stored and
+// compared. Persistent stores record them when vectors are first written, so
+// later searches use the same metric and normalization as indexing did.
+type Settings struct {
+	Metric    Metric `json:"metric"`
+	Normalize bool   `json:"normalize"`
+}
+
+// settings returns the Settings part of the options
+func (o Options) settings() Settings {
+	return Settings{Metric: o.Metric, Normalize: o.Normalize}
+}
+
+// resolveSettings reconciles options with the settings recorded for a
+// store. An empty Metric adopts the recorded metric, and a store indexed
+// with normalization stays normalized. A store without recorded settings,
+// or without vectors, takes the options as they are (cosine when Metric is
+// empty). An explicit metric other than the recorded one, or normalization
+// requested for vectors stored without it, fails with ErrSettingsMismatch,
+// since the existing vectors were not indexed that way.
+func resolveSettings(options Options, stored *Settings, populated bool) (Options, error) {
+	if stored == nil || !populated {
+		if options.Metric == "" {
+			options.Metric = MetricCosine
+		}
+		return options, nil
+	}
+
+	switch options.Metric {
+	case "":
+		options.Metric = stored.Metric
+	case stored.Metric:
+	default:
+		return options, fmt.Errorf("%w: the store was indexed with the %s metric, not %s",
+			ErrSettingsMismatch, stored.Metric, options.Metric)
+	}
+
+	if stored.Normalize {
+		options.Normalize = true
+	} else if options.Normalize {
+		return options, fmt.Errorf("%w: the store's vectors were indexed without normalization",
+			ErrSettingsMismatch)
+	}
+
+	return options, nil
+}
+
+// readSettingsFile reads recorded settings, returning nil if there are none
+func readSettingsFile(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store settings: %w", err)
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse store settings %s: %w", path, err)
+	}
+	if settings.Metric, err = ParseMetric(string(settings.Metric)); err != nil {
+		return nil, fmt.Errorf("invalid store settings %s: %w", path, err)
+	}
+	return &settings, nil
+}
+
+// writeSettingsFile records settings
+func writeSettingsFile(path string, settings Settings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal store settings: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write store settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package vectorstore
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStoreSettingsPersist(t *testing.T) {
+	for _, backend := range []Backend{BackendJSON, BackendSQLite} {
+		t.Run(string(backend), func(t *testing.T) {
+			dir := t.TempDir()
+
+			store, err := Open(backend, dir, Options{Metric: MetricEuclidean, Normalize: true, Lock: LockExclusive})
+			if err != nil {
+				t.Fatalf("open for indexing: %v", err)
+			}
+			if err := store.Add("a", []float32{3, 4}, "a", nil); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			store.Close()
+
+			// Opening without settings adopts the recorded ones
+			store, err = Open(backend, dir, Options{Lock: LockShared})
+			if err != nil {
+				t.Fatalf("open without settings: %v", err)
+			}
+			stats := store.GetStats()
+			store.Close()
+			if stats["metric"] != string(MetricEuclidean) || stats["normalized"] != true {
+				t.Errorf("got metric %v, normalized %v; want euclidean, true", stats["metric"], stats["normalized"])
+			}
+
+			// Conflicting settings are rejected
+			for _, options := range []Options{
+				{Metric: MetricCosine, Lock: LockShared},
+				{Metric: MetricDot, Normalize: true, Lock: LockExclusive},
+			} {
+				if store, err := Open(backend, dir, options); !errors.Is(err, ErrSettingsMismatch) {
+					if err == nil {
+						store.Close()
+					}
+					t.Errorf("open with %s: got error %v, want ErrSettingsMismatch", options.Metric, err)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveSettings(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   Options
+		stored    *Settings
+		populated bool
+		want      Settings
+		wantErr   bool
+	}{
+		{"new store defaults to cosine", Options{}, nil, false, Settings{Metric: MetricCosine}, false},
+		{"new store takes options", Options{Metric: MetricDot, Normalize: true}, nil, false, Settings{Metric: MetricDot, Normalize: true}, false},
+		{"empty store takes options", Options{Metric: MetricDot}, &Settings{Metric: MetricEuclidean}, false, Settings{Metric: MetricDot}, false},
+		{"adopts recorded metric", Options{}, &Settings{Metric: MetricEuclidean}, true, Settings{Metric: MetricEuclidean}, false},
+		{"matching metric", Options{Metric: MetricDot}, &Settings{Metric: MetricDot}, true, Settings{Metric: MetricDot}, false},
+		{"adopts normalization", Options{}, &Settings{Metric: MetricCosine, Normalize: true}, true, Settings{Metric: MetricCosine, Normalize: true}, false},
+		{"conflicting metric", Options{Metric: MetricCosine}, &Settings{Metric: MetricDot}, true, Settings{}, true},
+		{"normalize unnormalized vectors", Options{Normalize: true}, &Settings{Metric: MetricCosine}, true, Settings{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSettings(tt.options, tt.stored, tt.populated)
+			if tt.wantErr {
+				if !errors.Is(err, ErrSettingsMismatch) {
+					t.Fatalf("got error %v, want ErrSettingsMismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.settings() != tt.want {
+				t.Errorf("got %+v, want %+v", got.settings(), tt.want)
+			}
+		})
+	}
+}
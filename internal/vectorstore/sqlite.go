@@ -0,0 +1,535 @@
+package vectorstore
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, registered as "sqlite"
+)
+
+// SQLiteFileName is the database file inside a data directory
+const SQLiteFileName = "edgerag.db"
+
+// sqliteSchemaVersion is stored in PRAGMA user_version
+const sqliteSchemaVersion = 2
+
+// sqliteIndexedColumns are metadata keys exposed as indexed generated columns
+// (named meta_<key>) so filters on them don't scan the JSON of every row
+var sqliteIndexedColumns = []string{
+	"file",
+	"filename",
+	"extension",
+	"parent_id",
+	"chunk_index",
+	"chunk_type",
+}
+
+// SQLiteStore is a vector store kept in a single SQLite database. Chunks are
+// stored with their metadata as a JSON column and embeddings as little-endian
+// float32 blobs; an FTS5 table mirrors the content for lexical search.
+// Embeddings are also cached in memory for fast similarity search.
+type SQLiteStore struct {
+	*MemoryStore
+	db      *sql.DB
+	path    string
+	dataDir string
+	lock    *dirLock
+}
+
+// NewSQLiteStore opens (creating if needed) the SQLite store in dataDir. Lock
+// options and recorded settings behave as for NewPersistentStoreWithOptions,
+// with the settings kept in the database.
+func NewSQLiteStore(dataDir string, options Options) (*SQLiteStore, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	store := &SQLiteStore{
+		path:    filepath.Join(dataDir, SQLiteFileName),
+		dataDir: dataDir,
+	}
+
+	if options.Lock != LockNone {
+		lock, err := acquireDirLock(dataDir, options.Lock, options.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		store.lock = lock
+	}
+
+	busyTimeout := options.LockTimeout.Milliseconds()
+	if busyTimeout <= 0 {
+		busyTimeout = 5000
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)",
+		store.path, busyTimeout)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	store.db = db
+
+	if err := store.migrate(); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to initialize database schema: %w", err)
+	}
+
+	if err := store.applySettings(options); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	if err := store.loadFromDB(); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to load vectors from database: %w", err)
+	}
+
+	return store, nil
+}
+
+// migrate creates the schema and adds any indexed metadata columns missing
+// from databases created by older versions
+func (s *SQLiteStore) migrate() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS vectors (
+			id        TEXT PRIMARY KEY,
+			content   TEXT NOT NULL,
+			metadata  TEXT NOT NULL DEFAULT '{}',
+			embedding BLOB NOT NULL,
+			dimension INTEGER NOT NULL
+		)`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS vectors_fts USING fts5(
+			content,
+			id UNINDEXED,
+			tokenize = 'porter unicode61'
+		)`,
+		`CREATE TABLE IF NOT EXISTS settings (
+			key   TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		)`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	existing := make(map[string]bool)
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_xinfo('vectors')`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	for _, key := range sqliteIndexedColumns {
+		column := "meta_" + key
+		if !existing[column] {
+			statement := fmt.Sprintf(`ALTER TABLE vectors ADD COLUMN %s GENERATED ALWAYS AS (json_extract(metadata, '$.%s')) VIRTUAL`,
+				column, key)
+			if _, err := s.db.Exec(statement); err != nil {
+				return err
+			}
+		}
+		statement := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_vectors_%s ON vectors(%s)`, key, column)
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, sqliteSchemaVersion))
+	return err
+}
+
+// applySettings reconciles options with the settings recorded in the
+// database, records them unless the store is read-only, and creates the
+// in-memory search cache with the result
+func (s *SQLiteStore) applySettings(options Options) error {
+	values := make(map[string]string)
+	rows, err := s.db.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return fmt.Errorf("failed to read store settings: %w", err)
+	}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read store settings: %w", err)
+		}
+		values[key] = value
+	}
+	rows.Close()
+
+	var stored *Settings
+	if metricName, ok := values["metric"]; ok {
+		metric, err := ParseMetric(metricName)
+		if err != nil {
+			return fmt.Errorf("invalid store settings in %s: %w", s.path, err)
+		}
+		stored = &Settings{Metric: metric, Normalize: values["normalize"] == "true"}
+	}
+
+	var populated bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM vectors)`).Scan(&populated); err != nil {
+		return fmt.Errorf("failed to read store settings: %w", err)
+	}
+
+	options, err = resolveSettings(options, stored, populated)
+	if err != nil {
+		return fmt.Errorf("vector store %s: %w", s.path, err)
+	}
+	s.MemoryStore = NewMemoryStoreWithOptions(options)
+
+	settings := options.settings()
+	if options.Lock == LockShared || (stored != nil && *stored == settings) {
+		return nil
+	}
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES ('metric', ?), ('normalize', ?)`,
+		string(settings.Metric), fmt.Sprint(settings.Normalize))
+	if err != nil {
+		return fmt.Errorf("failed to write store settings: %w", err)
+	}
+	return nil
+}
+
+// loadFromDB reads every stored vector into the in-memory search cache
+func (s *SQLiteStore) loadFromDB() error {
+	rows, err := s.db.Query(`SELECT id, content, metadata, embedding FROM vectors`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for rows.Next() {
+		var id, content, metadataJSON string
+		var blob []byte
+		if err := rows.Scan(&id, &content, &metadataJSON, &blob); err != nil {
+			return err
+		}
+
+		metadata := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			continue // Skip rows with corrupted metadata
+		}
+
+		vector := &Vector{
+			ID:        id,
+			Embedding: decodeEmbedding(blob),
+			Content:   content,
+			Metadata:  metadata,
+		}
+		if err := s.insertLocked(vector); err != nil {
+			continue // Skip vectors that don't fit the store's dimension
+		}
+	}
+
+	return rows.Err()
+}
+
+// Close closes the database and releases the data directory lock
+func (s *SQLiteStore) Close() error {
+	var err error
+	if s.db != nil {
+		err = s.db.Close()
+		s.db = nil
+	}
+	if s.lock != nil {
+		if lockErr := s.lock.release(); err == nil {
+			err = lockErr
+		}
+	}
+	return err
+}
+
+// StaleLock returns the owner record left behind by a writer that exited
+// without releasing the lock, or nil
+func (s *SQLiteStore) StaleLock() *LockOwner {
+	if s.lock == nil {
+		return nil
+	}
+	return s.lock.stale
+}
+
+// Path returns the database file path
+func (s *SQLiteStore) Path() string {
+	return s.path
+}
+
+// checkWritable rejects writes to a store opened for shared (read-only) access
+func (s *SQLiteStore) checkWritable() error {
+	if s.lock != nil && s.lock.mode == LockShared {
+		return fmt.Errorf("vector store %s is opened read-only", s.path)
+	}
+	return nil
+}
+
+// Add stores a vector in the database and the search cache
+func (s *SQLiteStore) Add(id string, embedding []float32, content string, metadata map[string]interface{}) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.MemoryStore.Add(id, embedding, content, metadata); err != nil {
+		return err
+	}
+
+	// Persist the cached copy so normalization applies on disk as well
+	vector, err := s.MemoryStore.Get(id)
+	if err != nil {
+		return err
+	}
+	metadataJSON, err := json.Marshal(vector.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO vectors (id, content, metadata, embedding, dimension)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			content = excluded.content,
+			metadata = excluded.metadata,
+			embedding = excluded.embedding,
+			dimension = excluded.dimension`,
+		id, content, string(metadataJSON), encodeEmbedding(vector.Embedding), len(vector.Embedding))
+	if err != nil {
+		return fmt.Errorf("failed to store vector: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM vectors_fts WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to update full-text index: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO vectors_fts (content, id) VALUES (?, ?)`, content, id); err != nil {
+		return fmt.Errorf("failed to update full-text index: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a vector from the database and the search cache
+func (s *SQLiteStore) Delete(id string) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.MemoryStore.Delete(id); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM vectors WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete vector: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM vectors_fts WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to update full-text index: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Clear removes all vectors. Read-only stores are left untouched.
+func (s *SQLiteStore) Clear() {
+	if s.checkWritable() != nil {
+		return
+	}
+	s.MemoryStore.Clear()
+	s.db.Exec(`DELETE FROM vectors`)
+	s.db.Exec(`DELETE FROM vectors_fts`)
+}
+
+// SearchFiltered selects matching vectors in SQL, using the indexed metadata
+// columns where possible, and ranks only those by similarity
+func (s *SQLiteStore) SearchFiltered(queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error) {
+	if len(filter) == 0 {
+		return s.Search(queryEmbedding, topK, threshold)
+	}
+
+	where, args := sqliteFilterClause(filter)
+	rows, err := s.db.Query(`SELECT id FROM vectors WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply filter: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.searchIDs(queryEmbedding, topK, threshold, ids)
+}
+
+// LexicalSearch ranks the chunks matching filter by BM25 keyword relevance
+// to the query text, and returns up to topK of them in that order, scored by
+// their similarity to queryEmbedding so they can be mixed with Search results
+func (s *SQLiteStore) LexicalSearch(query string, queryEmbedding []float32, topK int, threshold float32, filter Filter) ([]*SearchResult, error) {
+	match := ftsMatchExpression(query)
+	if match == "" || topK <= 0 {
+		return []*SearchResult{}, nil
+	}
+
+	statement := `SELECT vectors_fts.id FROM vectors_fts
+		JOIN vectors ON vectors.id = vectors_fts.id
+		WHERE vectors_fts MATCH ?`
+	args := []interface{}{match}
+	if len(filter) > 0 {
+		where, filterArgs := sqliteFilterClause(filter)
+		statement += " AND " + where
+		args = append(args, filterArgs...)
+	}
+	statement += " ORDER BY bm25(vectors_fts) LIMIT ?"
+	rows, err := s.db.Query(statement, append(args, topK)...)
+	if err != nil {
+		return nil, fmt.Errorf("full-text search failed: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scored, err := s.searchIDs(queryEmbedding, len(ids), threshold, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*SearchResult, len(scored))
+	for _, result := range scored {
+		byID[result.ID] = result
+	}
+	results := make([]*SearchResult, 0, len(scored))
+	for _, id := range ids {
+		if result, ok := byID[id]; ok {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// GetStats returns statistics about the store
+func (s *SQLiteStore) GetStats() map[string]interface{} {
+	stats := s.MemoryStore.GetStats()
+	stats["backend"] = "sqlite"
+	stats["database"] = s.path
+	if info, err := os.Stat(s.path); err == nil {
+		stats["database_bytes"] = info.Size()
+	}
+	return stats
+}
+
+// sqliteFilterClause builds a WHERE clause matching every filter condition.
+// Indexed keys compare against their generated column; other keys go through
+// json_each so list-valued metadata matches any element. Not conditions also
+// match rows without the key.
+func sqliteFilterClause(filter Filter) (string, []interface{}) {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	indexed := make(map[string]bool, len(sqliteIndexedColumns))
+	for _, key := range sqliteIndexedColumns {
+		indexed[key] = true
+	}
+
+	conditions := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, len(keys)*2)
+	for _, key := range keys {
+		value := filter[key]
+		not, negated := value.(Not)
+		if negated {
+			value = not.Value
+		}
+		if indexed[key] {
+			if negated {
+				conditions = append(conditions, fmt.Sprintf("(meta_%s IS NULL OR meta_%s != ?)", key, key))
+			} else {
+				conditions = append(conditions, fmt.Sprintf("meta_%s = ?", key))
+			}
+			args = append(args, value)
+			continue
+		}
+		exists := "EXISTS (SELECT 1 FROM json_each(vectors.metadata, ?) WHERE json_each.value = ?)"
+		if negated {
+			exists = "NOT " + exists
+		}
+		conditions = append(conditions, exists)
+		args = append(args, `$."`+strings.ReplaceAll(key, `"`, `""`)+`"`, value)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// ftsMatchExpression turns free text into an FTS5 query that ORs the quoted
+// terms, so punctuation in the question is never parsed as FTS syntax
+func ftsMatchExpression(text string) string {
+	terms := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+term+`"`)
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// encodeEmbedding packs an embedding as little-endian float32s
+func encodeEmbedding(embedding []float32) []byte {
+	blob := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(v))
+	}
+	return blob
+}
+
+// decodeEmbedding unpacks a blob written by encodeEmbedding
+func decodeEmbedding(blob []byte) []float32 {
+	embedding := make([]float32, len(blob)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+	}
+	return embedding
+}
@@ -0,0 +1,64 @@
+package vectorstore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteLexicalSearch(t *testing.T) {
+	store, err := NewSQLiteStore(t.TempDir(), Options{Lock: LockExclusive})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	chunks := []struct {
+		id, content string
+		embedding   []float32
+		metadata    map[string]interface{}
+	}{
+		{"e1042", "Error E1042 means the token expired.", []float32{0, 1}, map[string]interface{}{"file": "errors.md"}},
+		{"e1042_fine", "Error E1042 means the token expired.", []float32{0, 1}, map[string]interface{}{"file": "errors.md", "chunk_level": "fine"}},
+		{"tokens", "Tokens expire after an hour; the error says so.", []float32{0.6, 0.8}, map[string]interface{}{"file": "auth.md"}},
+		{"other", "Deployments run nightly.", []float32{1, 0}, map[string]interface{}{"file": "deploy.md"}},
+	}
+	for _, c := range chunks {
+		if err := store.Add(c.id, c.embedding, c.content, c.metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(results []*SearchResult) []string {
+		var got []string
+		for _, result := range results {
+			got = append(got, result.ID)
+		}
+		return got
+	}
+	query := []float32{1, 0}
+
+	results, err := store.LexicalSearch("what is error E1042?", query, 10, -1, Filter{"chunk_level": Not{Value: "fine"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(results), []string{"e1042", "tokens"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v in keyword relevance order", got, want)
+	}
+	if results[0].Score != 0 || results[1].Score < 0.59 || results[1].Score > 0.61 {
+		t.Errorf("scores %v, %v are not the similarities 0 and 0.6", results[0].Score, results[1].Score)
+	}
+
+	// The threshold applies to the similarity
+	results, err = store.LexicalSearch("error E1042", query, 10, 0.5, Filter{"file": "errors.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("got %v below the threshold", ids(results))
+	}
+
+	if _, err := LexicalSearch(NewMemoryStore(), "error", query, 10, -1, nil); !errors.Is(err, ErrNoLexicalIndex) {
+		t.Errorf("memory store: got %v, want ErrNoLexicalIndex", err)
+	}
+}
@@ -0,0 +1,61 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// scored pairs an item (a vector ID or row number) with its similarity score
+type scored[T any] struct {
+	Item  T
+	Score float32
+}
+
+// topKHeap keeps the k highest-scoring entries seen so far. It is a min-heap
+// on score so the weakest retained entry can be evicted in O(log k).
+type topKHeap[T any] struct {
+	k       int
+	entries []scored[T]
+}
+
+func newTopKHeap[T any](k int) *topKHeap[T] {
+	if k < 0 {
+		k = 0
+	}
+	return &topKHeap[T]{k: k, entries: make([]scored[T], 0, k)}
+}
+
+func (h *topKHeap[T]) Len() int           { return len(h.entries) }
+func (h *topKHeap[T]) Less(i, j int) bool { return h.entries[i].Score < h.entries[j].Score }
+func (h *topKHeap[T]) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *topKHeap[T]) Push(x interface{}) { h.entries = append(h.entries, x.(scored[T])) }
+func (h *topKHeap[T]) Pop() interface{} {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+// Offer considers an entry for inclusion in the top k
+func (h *topKHeap[T]) Offer(item T, score float32) {
+	if h.k == 0 {
+		return
+	}
+	if len(h.entries) < h.k {
+		heap.Push(h, scored[T]{Item: item, Score: score})
+		return
+	}
+	if score > h.entries[0].Score {
+		h.entries[0] = scored[T]{Item: item, Score: score}
+		heap.Fix(h, 0)
+	}
+}
+
+// Sorted returns the retained entries ordered by descending score
+func (h *topKHeap[T]) Sorted() []scored[T] {
+	out := make([]scored[T], len(h.entries))
+	copy(out, h.entries)
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
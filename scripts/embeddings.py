@@ -3,6 +3,14 @@
 Embedding service using sentence-transformers.
 This script runs as a persistent server, reading JSON requests from stdin
 and outputting embeddings as JSON responses.
+
+Requests may set "op" to choose the operation:
+- "embed" (default): {"embedding": [...]} for "text"
+- "embed_batch": {"embeddings": [[...], ...]} for the list "texts", in order
+- "info": {"info": {...}} with the model's max_seq_length, dimension and
+  tokenizer; WordPiece tokenizers include their vocabulary and settings so
+  the caller can count tokens itself
+- "count_tokens": {"token_count": n} for "text", special tokens included
 """
 
 import json
@@ -59,6 +67,74 @@ def generate_embedding(model, text):
     except Exception as e:
         return None, str(e)
 
+def generate_embeddings(model, texts):
+    """Generate embeddings for a list of texts in small batches.
+
+    Returns the embeddings and None, or None and the error text.
+    """
+    try:
+        embeddings = model.encode(
+            texts,
+            convert_to_tensor=False,
+            show_progress_bar=False,
+            batch_size=16  # Small batches keep memory use bounded
+        )
+        
+        embeddings = np.asarray(embeddings, dtype=np.float32)
+        gc.collect()
+        
+        return embeddings.tolist(), None
+    except Exception as e:
+        return None, str(e)
+
+def tokenizer_info(model):
+    """Describe the model's tokenizer, in full for WordPiece."""
+    tokenizer = model.tokenizer
+    info = {
+        "type": "unknown",
+        "special_tokens": len(tokenizer("")["input_ids"]),
+    }
+
+    backend = getattr(tokenizer, "backend_tokenizer", None)
+    if backend is None:
+        return info
+
+    spec = json.loads(backend.to_str())
+    tokenizer_model = spec.get("model") or {}
+    info["type"] = str(tokenizer_model.get("type", "unknown")).lower()
+    if info["type"] != "wordpiece":
+        return info
+
+    normalizer = spec.get("normalizer") or {}
+    lowercase = bool(normalizer.get("lowercase", False))
+    strip_accents = normalizer.get("strip_accents")
+    if strip_accents is None:
+        # BERT strips accents whenever it lowercases unless told otherwise
+        strip_accents = lowercase
+
+    vocab = tokenizer_model.get("vocab") or {}
+    info.update({
+        "vocab": sorted(vocab, key=vocab.get),
+        "lowercase": lowercase,
+        "strip_accents": bool(strip_accents),
+        "unk_token": tokenizer_model.get("unk_token", "[UNK]"),
+        "continuing_subword_prefix": tokenizer_model.get("continuing_subword_prefix", "##"),
+        "max_input_chars_per_word": tokenizer_model.get("max_input_chars_per_word", 100),
+    })
+    return info
+
+def model_info(model):
+    """Report the model's sequence length, dimension and tokenizer."""
+    return {
+        "max_seq_length": int(model.max_seq_length or 0),
+        "dimension": int(model.get_sentence_embedding_dimension() or 0),
+        "tokenizer": tokenizer_info(model),
+    }
+
+def count_tokens(model, text):
+    """Count the tokens the model sees for text, without truncation."""
+    return len(model.tokenizer(text, add_special_tokens=True, truncation=False)["input_ids"])
+
 def handle_request(request_data):
     """Handle a single embedding request."""
     try:
@@ -68,14 +144,21 @@ def handle_request(request_data):
         except json.JSONDecodeError as e:
             return {"error": f"Invalid JSON input: {str(e)}"}
         
+        op = request.get("op") or "embed"
+        if op not in ("embed", "embed_batch", "info", "count_tokens"):
+            return {"error": f"Unknown op: {op}"}
+
         # Validate input
-        if "text" not in request:
+        if op == "embed_batch":
+            if not isinstance(request.get("texts"), list):
+                return {"error": "Missing 'texts' list in request"}
+        elif op != "info" and "text" not in request:
             return {"error": "Missing 'text' field in request"}
         
         if "model" not in request:
             return {"error": "Missing 'model' field in request"}
         
-        text = request["text"]
+        text = request.get("text", "")
         model_name = request["model"]
         
         # Load model
@@ -83,6 +166,20 @@ def handle_request(request_data):
         if model is None:
             return {"error": f"Failed to load model: {model_name}"}
         
+        if op == "info":
+            return {"info": model_info(model)}
+        
+        if op == "count_tokens":
+            return {"token_count": count_tokens(model, text)}
+        
+        if op == "embed_batch":
+            if not request["texts"]:
+                return {"embeddings": []}
+            embeddings, error = generate_embeddings(model, request["texts"])
+            if error is not None:
+                return {"error": f"Failed to generate embeddings: {error}"}
+            return {"embeddings": embeddings}
+        
         # Generate embedding
         embedding = generate_embedding(model, text)
         if embedding is None: